@@ -1,14 +1,28 @@
 package api // import "github.com/CenturyLinkLabs/dray/api"
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/CenturyLinkLabs/dray/auth"
 	"github.com/CenturyLinkLabs/dray/job"
+	"github.com/CenturyLinkLabs/dray/metrics"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 )
 
+// namespacePrefix is prepended to the job routes to address the jobs in a
+// particular namespace.
+const namespacePrefix = "/namespaces/{namespace:[a-z0-9][a-z0-9-]*}"
+
+var httpRequests = metrics.NewCounter(
+	"dray_http_requests_total",
+	"Number of API requests, by method, route and response status.",
+	"method", "route", "status")
+
 func init() {
 	log.SetLevel(log.DebugLevel)
 }
@@ -25,43 +39,98 @@ func (w *statusLoggingResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
-// A Server is the HTTP server which reponds to Dray API requests.
+// A Server is the HTTP server which reponds to Dray API requests. Start
+// blocks until the server fails or is stopped by Shutdown, which waits for
+// the requests in progress to complete.
 type Server interface {
-	Start(port int)
+	Start(addr string)
+	Shutdown(ctx context.Context) error
 }
 
 type jobServer struct {
-	jobManager job.JobManager
+	jobManager    job.JobManager
+	authenticator *auth.Authenticator
+	tls           TLSConfig
+	server        *http.Server
 }
 
 // NewServer returns a new Server instance which will handle Dray service
-// requests and defer work to the specified JobManager.
-func NewServer(jm job.JobManager) Server {
-	return &jobServer{jobManager: jm}
+// requests and defer work to the specified JobManager. If the Authenticator
+// is enabled, every API request must present a token granting the scope the
+// request requires.
+func NewServer(jm job.JobManager, a *auth.Authenticator) Server {
+	return NewTLSServer(jm, a, TLSConfig{})
 }
 
-func (s *jobServer) Start(port int) {
-	router := s.createRouter()
+// NewTLSServer returns a new Server instance like NewServer, which serves
+// HTTPS if the TLSConfig is enabled.
+func NewTLSServer(jm job.JobManager, a *auth.Authenticator, t TLSConfig) Server {
+	return &jobServer{jobManager: jm, authenticator: a, tls: t, server: &http.Server{}}
+}
+
+func (s *jobServer) Start(addr string) {
+	s.server.Addr = addr
+	s.server.Handler = s.createRouter()
+
+	var err error
+
+	if s.tls.Enabled() {
+		if s.server.TLSConfig, err = s.tls.serverConfig(); err != nil {
+			log.Errorf("Invalid TLS configuration: %s", err)
+			return
+		}
+
+		log.Infof("Server listening on %s (HTTPS)", addr)
+		err = s.server.ListenAndServeTLS(s.tls.CertFile, s.tls.KeyFile)
+	} else {
+		log.Infof("Server listening on %s", addr)
+		err = s.server.ListenAndServe()
+	}
+
+	if err != http.ErrServerClosed {
+		log.Error(err)
+	}
+}
 
-	log.Infof("Server running on port %d", port)
-	portString := fmt.Sprintf(":%d", port)
-	http.ListenAndServe(portString, router)
+func (s *jobServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
 }
 
 func (s *jobServer) createRouter() *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(notFound)
+	router.Path("/metrics").Methods("GET").Handler(metrics.Handler())
+	router.Path("/healthz").Methods("GET").HandlerFunc(healthz)
+	router.Path("/readyz").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readyz(s.jobManager, r, w)
+	})
 
 	m := map[string]map[string]handler{
 		"GET": {
-			"/jobs":             listJobs,
-			"/jobs/{jobid}":     getJob,
-			"/jobs/{jobid}/log": getJobLog,
+			"/jobs":                      listJobs,
+			"/jobs/{jobid}":              getJob,
+			"/jobs/{jobid}/log":          getJobLog,
+			"/jobs/{jobid}/services/log": getServiceLog,
+			"/templates":                 listTemplates,
+			"/templates/{name}":          getTemplate,
+			"/schedules":                 listSchedules,
+			"/schedules/{scheduleid}":    getSchedule,
 		},
 		"POST": {
-			"/jobs": createJob,
+			"/jobs":                  createJob,
+			"/jobs/{jobid}/rerun":    rerunJob,
+			"/jobs/{jobid}/resume":   resumeJob,
+			"/templates":             createTemplate,
+			"/templates/{name}/jobs": createTemplateJob,
+			"/schedules":             createSchedule,
+		},
+		"PUT": {
+			"/schedules/{scheduleid}": updateSchedule,
 		},
 		"DELETE": {
-			"/jobs/{jobid}": deleteJob,
+			"/jobs/{jobid}":           deleteJob,
+			"/templates/{name}":       deleteTemplate,
+			"/schedules/{scheduleid}": deleteSchedule,
 		},
 	}
 
@@ -80,14 +149,91 @@ func (s *jobServer) createRouter() *mux.Router {
 					w.Header().Set("Content-Type", "application/json")
 				}
 
-				localFct(s.jobManager, r, ww)
+				defer func() {
+					log.Infof("Completed %d", ww.statusCode)
+					httpRequests.Inc(localMethod, localRoute, strconv.Itoa(ww.statusCode))
+				}()
 
-				log.Infof("Completed %d", ww.statusCode)
+				if !s.authorize(ww, r, requiredScope(localMethod, localRoute)) {
+					return
+				}
+
+				localFct(s.jobManager, r, ww)
 			}
 			router.Path("/v{version:[0-9.]+}" + localRoute).Methods(localMethod).HandlerFunc(wrap)
 			router.Path(localRoute).Methods(localMethod).HandlerFunc(wrap)
+
+			// Job routes may also address a namespace other than the one
+			// implied by the request's principal
+			if namespaced(localRoute) {
+				router.Path("/v{version:[0-9.]+}" + namespacePrefix + localRoute).Methods(localMethod).HandlerFunc(wrap)
+				router.Path(namespacePrefix + localRoute).Methods(localMethod).HandlerFunc(wrap)
+			}
 		}
 	}
 
 	return router
 }
+
+// Authenticates the request and checks that the principal has been granted
+// the scope and (if the route names one) access to the namespace. If so, the
+// principal is added to the request's context; otherwise an error response is
+// written and false is returned. The request
+// is updated in place since mux keys the route variables on its address.
+func (s *jobServer) authorize(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if !s.authenticator.Enabled() {
+		return true
+	}
+
+	p, err := s.authenticator.Authenticate(r)
+	if err != nil {
+		log.Infof("Unauthenticated request: %s", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dray"`)
+		writeErrors(w, http.StatusUnauthorized, []job.FieldError{{Message: err.Error()}})
+		return false
+	}
+
+	if !p.HasScope(scope) {
+		log.Infof("Principal %s lacks the %s scope", p.Name, scope)
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="dray", error="insufficient_scope", scope=%q`, scope))
+		writeErrors(w, http.StatusForbidden, []job.FieldError{{Message: fmt.Sprintf("the %s scope is required", scope)}})
+		return false
+	}
+
+	if ns := mux.Vars(r)["namespace"]; len(p.Namespace) > 0 && len(ns) > 0 && ns != p.Namespace {
+		log.Infof("Principal %s cannot access namespace %s", p.Name, ns)
+		writeErrors(w, http.StatusForbidden, []job.FieldError{{Message: fmt.Sprintf("access to namespace %s is not permitted", ns)}})
+		return false
+	}
+
+	*r = *r.WithContext(auth.NewContext(r.Context(), p))
+	return true
+}
+
+// Returns the scope required for a route. Reading requires jobs:read and
+// submitting or changing jobs requires jobs:write, while changing templates
+// and schedules (which affect every job created from them) requires
+// jobs:admin.
+func requiredScope(method, route string) string {
+	switch {
+	case method == "GET":
+		return auth.ScopeRead
+	case route == "/templates/{name}/jobs":
+		return auth.ScopeWrite
+	case strings.HasPrefix(route, "/templates") || strings.HasPrefix(route, "/schedules"):
+		return auth.ScopeAdmin
+	default:
+		return auth.ScopeWrite
+	}
+}
+
+// Returns true if the route acts on jobs, and so is available within a
+// namespace.
+func namespaced(route string) bool {
+	return strings.HasPrefix(route, "/jobs") || route == "/templates/{name}/jobs"
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	log.Infof("No route for %s %s", r.Method, r.RequestURI)
+	writeErrors(w, http.StatusNotFound, []job.FieldError{{Message: "resource not found"}})
+}
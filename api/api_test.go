@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/CenturyLinkLabs/dray/auth"
 	"github.com/CenturyLinkLabs/dray/job"
 	log "github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/mock"
@@ -25,9 +27,9 @@ type mockJobManager struct {
 	mock.Mock
 }
 
-func (m *mockJobManager) ListAll() ([]job.Job, error) {
+func (m *mockJobManager) ListAll(namespace string) ([]job.Job, error) {
 	var jobs []job.Job
-	args := m.Mock.Called()
+	args := m.Mock.Called(namespace)
 
 	if jobsArg := args.Get(0); jobsArg != nil {
 		jobs = jobsArg.([]job.Job)
@@ -35,9 +37,9 @@ func (m *mockJobManager) ListAll() ([]job.Job, error) {
 	return jobs, args.Error(1)
 }
 
-func (m *mockJobManager) GetByID(jobID string) (*job.Job, error) {
+func (m *mockJobManager) GetByID(namespace, jobID string) (*job.Job, error) {
 	var j *job.Job
-	args := m.Mock.Called(jobID)
+	args := m.Mock.Called(namespace, jobID)
 
 	if jobArg := args.Get(0); jobArg != nil {
 		j = jobArg.(*job.Job)
@@ -56,9 +58,9 @@ func (m *mockJobManager) Execute(j *job.Job) error {
 	return args.Error(0)
 }
 
-func (m *mockJobManager) GetLog(j *job.Job, index int) (*job.JobLog, error) {
+func (m *mockJobManager) GetLog(j *job.Job, q job.LogQuery) (*job.JobLog, error) {
 	var jl *job.JobLog
-	args := m.Mock.Called(j, index)
+	args := m.Mock.Called(j, q)
 
 	if logArg := args.Get(0); logArg != nil {
 		jl = logArg.(*job.JobLog)
@@ -67,15 +69,131 @@ func (m *mockJobManager) GetLog(j *job.Job, index int) (*job.JobLog, error) {
 	return jl, args.Error(1)
 }
 
+func (m *mockJobManager) GetServiceLog(j *job.Job, q job.LogQuery) (*job.JobLog, error) {
+	var jl *job.JobLog
+	args := m.Mock.Called(j, q)
+
+	if logArg := args.Get(0); logArg != nil {
+		jl = logArg.(*job.JobLog)
+	}
+
+	return jl, args.Error(1)
+}
+
+func (m *mockJobManager) Shutdown(ctx context.Context) error {
+	args := m.Mock.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) Readiness() job.Readiness {
+	args := m.Mock.Called()
+	return args.Get(0).(job.Readiness)
+}
+
 func (m *mockJobManager) Delete(job *job.Job) error {
 	args := m.Mock.Called(job)
 	return args.Error(0)
 }
 
+func (m *mockJobManager) ListTemplates() ([]job.JobTemplate, error) {
+	var templates []job.JobTemplate
+	args := m.Mock.Called()
+
+	if templatesArg := args.Get(0); templatesArg != nil {
+		templates = templatesArg.([]job.JobTemplate)
+	}
+	return templates, args.Error(1)
+}
+
+func (m *mockJobManager) GetTemplate(name string) (*job.JobTemplate, error) {
+	var t *job.JobTemplate
+	args := m.Mock.Called(name)
+
+	if templateArg := args.Get(0); templateArg != nil {
+		t = templateArg.(*job.JobTemplate)
+	}
+
+	return t, args.Error(1)
+}
+
+func (m *mockJobManager) CreateTemplate(t *job.JobTemplate) error {
+	args := m.Mock.Called(t)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) DeleteTemplate(t *job.JobTemplate) error {
+	args := m.Mock.Called(t)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) ListSchedules() ([]job.Schedule, error) {
+	var schedules []job.Schedule
+	args := m.Mock.Called()
+
+	if schedulesArg := args.Get(0); schedulesArg != nil {
+		schedules = schedulesArg.([]job.Schedule)
+	}
+	return schedules, args.Error(1)
+}
+
+func (m *mockJobManager) GetSchedule(scheduleID string) (*job.Schedule, error) {
+	var s *job.Schedule
+	args := m.Mock.Called(scheduleID)
+
+	if scheduleArg := args.Get(0); scheduleArg != nil {
+		s = scheduleArg.(*job.Schedule)
+	}
+
+	return s, args.Error(1)
+}
+
+func (m *mockJobManager) CreateSchedule(s *job.Schedule) error {
+	args := m.Mock.Called(s)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) UpdateSchedule(s *job.Schedule) error {
+	args := m.Mock.Called(s)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) DeleteSchedule(s *job.Schedule) error {
+	args := m.Mock.Called(s)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) Cancel(j *job.Job) error {
+	args := m.Mock.Called(j)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) Rerun(j *job.Job) (*job.Job, error) {
+	var rerun *job.Job
+	args := m.Mock.Called(j)
+
+	if jobArg := args.Get(0); jobArg != nil {
+		rerun = jobArg.(*job.Job)
+	}
+
+	return rerun, args.Error(1)
+}
+
+func (m *mockJobManager) Resume(j *job.Job) (*job.Job, error) {
+	var resumed *job.Job
+	args := m.Mock.Called(j)
+
+	if jobArg := args.Get(0); jobArg != nil {
+		resumed = jobArg.(*job.Job)
+	}
+
+	return resumed, args.Error(1)
+}
+
 type APITestSuite struct {
 	suite.Suite
 
 	j           *job.Job
+	t           *job.JobTemplate
 	jm          *mockJobManager
 	svr         *httptest.Server
 	client      *http.Client
@@ -85,9 +203,14 @@ type APITestSuite struct {
 
 func (suite *APITestSuite) SetupTest() {
 	suite.j = &job.Job{ID: "123"}
+	suite.t = &job.JobTemplate{
+		Name:       "foo",
+		Version:    2,
+		Parameters: []job.TemplateParameter{{Name: "COUNT", Type: "number", Required: true}},
+	}
 	suite.jm = &mockJobManager{}
 
-	server, _ := NewServer(suite.jm).(*jobServer)
+	server, _ := NewServer(suite.jm, nil).(*jobServer)
 	suite.svr = httptest.NewServer(server.createRouter())
 	suite.client = &http.Client{}
 
@@ -97,7 +220,7 @@ func (suite *APITestSuite) SetupTest() {
 
 func (suite *APITestSuite) TestListJobsSuccess() {
 	jobs := []job.Job{*suite.j}
-	suite.jm.On("ListAll").Return(jobs, nil)
+	suite.jm.On("ListAll", "").Return(jobs, nil)
 
 	res, _ := http.Get(suite.url("jobs"))
 	body, _ := ioutil.ReadAll(res.Body)
@@ -109,17 +232,78 @@ func (suite *APITestSuite) TestListJobsSuccess() {
 }
 
 func (suite *APITestSuite) TestListJobsError() {
-	suite.jm.On("ListAll").Return(nil, suite.serverErr)
+	suite.jm.On("ListAll", "").Return(nil, suite.serverErr)
 
 	res, _ := http.Get(suite.url("jobs"))
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusInternalServerError, res.StatusCode)
-	suite.Equal("text/plain; charset=utf-8", res.Header["Content-Type"][0])
-	suite.Equal("", string(body))
+	suite.Equal("application/json", res.Header["Content-Type"][0])
+	suite.Equal("{\"errors\":[{\"message\":\"oops\"}]}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestListJobsNamespace() {
+	suite.jm.On("ListAll", "team-a").Return([]job.Job{*suite.j}, nil)
+
+	res, _ := http.Get(suite.url("namespaces", "team-a", "jobs"))
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetJobNamespaceVersioned() {
+	suite.jm.On("GetByID", "team-a", suite.j.ID).Return(suite.j, nil)
+
+	res, _ := http.Get(suite.url("v1", "namespaces", "team-a", "jobs", suite.j.ID))
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetJobInvalidNamespace() {
+	res, _ := http.Get(suite.url("namespaces", "Team_A", "jobs", suite.j.ID))
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (suite *APITestSuite) TestCreateJobNamespace() {
+	expected := &job.Job{Name: "foo", Namespace: "team-a"}
+
+	suite.jm.On("Create", expected).Return(nil)
+	suite.jm.On("Execute", expected).Return(nil)
+
+	res, _ := http.Post(suite.url("namespaces", "team-a", "jobs"), "application/json",
+		bytes.NewBufferString(`{"name":"foo","namespace":"team-b"}`))
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
+func (suite *APITestSuite) TestCreateJobPolicyViolation() {
+	violation := job.PolicyViolationError{{Field: "steps[0].source", Message: "evil/miner is not an allowed image"}}
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(violation)
+
+	res, _ := http.Post(suite.url("jobs"), "application/json", bytes.NewBufferString(`{"steps":[{"source":"evil/miner"}]}`))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusForbidden, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"field\":\"steps[0].source\",\"message\":\"evil/miner is not an allowed image\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
+func (suite *APITestSuite) TestCreateJobQuotaExceeded() {
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(job.QuotaExceededError{Namespace: "team-a", Limit: 2})
+
+	res, _ := http.Post(suite.url("namespaces", "team-a", "jobs"), "application/json", bytes.NewBufferString(`{"name":"foo"}`))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusTooManyRequests, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"Namespace team-a has reached its limit of 2 active jobs\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "Execute", mock.Anything)
+}
+
 func (suite *APITestSuite) TestCreateJobSuccess() {
 	payload := "{\"name\":\"foo\"}\n"
 
@@ -135,15 +319,82 @@ func (suite *APITestSuite) TestCreateJobSuccess() {
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
+func (suite *APITestSuite) TestCreateJobYAML() {
+	payload := "name: foo\nsteps:\n- source: bar\n  beginDelimiter: \"---\"\n  endDelimiter: \"===\"\n"
+	expected := &job.Job{
+		Name:  "foo",
+		Steps: []job.JobStep{{Source: "bar", BeginDelimiter: "---", EndDelimiter: "==="}},
+	}
+
+	suite.jm.On("Create", expected).Return(nil)
+	suite.jm.On("Execute", expected).Return(nil)
+
+	res, _ := http.Post(suite.url("jobs"), "application/x-yaml; charset=utf-8", bytes.NewBufferString(payload))
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateJobMalformedYAML() {
+	res, _ := http.Post(suite.url("jobs"), "text/yaml", bytes.NewBufferString("steps: foo"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Contains(string(body), "invalid request body")
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateJobEmptyYAML() {
+	res, _ := http.Post(suite.url("jobs"), "application/x-yaml", bytes.NewBufferString("\n"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"request body is required\"}]}\n", string(body))
+}
+
 func (suite *APITestSuite) TestCreateJobJSONError() {
 	res, _ := http.Post(suite.url("jobs"), "application/json", bytes.NewBufferString(""))
 	body, _ := ioutil.ReadAll(res.Body)
 
-	suite.Equal(http.StatusInternalServerError, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"request body is required\"}]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
+func (suite *APITestSuite) TestCreateJobMalformedJSON() {
+	res, _ := http.Post(suite.url("jobs"), "application/json", bytes.NewBufferString("{\"steps\":1}"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Contains(string(body), "invalid request body")
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateJobInvalid() {
+	err := job.ValidationError{
+		{Field: "steps[1].source", Message: "required"},
+		{Field: "steps[2].output", Message: "must be an absolute file path"},
+	}
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(err)
+
+	res, _ := http.Post(suite.url("jobs"), "application/json", bytes.NewBufferString("{}"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Equal("application/json", res.Header["Content-Type"][0])
+	suite.Equal("{\"errors\":[{\"field\":\"steps[1].source\",\"message\":\"required\"},{\"field\":\"steps[2].output\",\"message\":\"must be an absolute file path\"}]}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestUnknownRoute() {
+	res, _ := http.Get(suite.url("foo"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"resource not found\"}]}\n", string(body))
+}
+
 func (suite *APITestSuite) TestCreateJobError() {
 	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(suite.serverErr)
 
@@ -151,12 +402,12 @@ func (suite *APITestSuite) TestCreateJobError() {
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusInternalServerError, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"oops\"}]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
 func (suite *APITestSuite) TestGetJobSuccess() {
-	suite.jm.On("GetByID", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
 
 	res, _ := http.Get(suite.url("jobs", suite.j.ID))
 	body, _ := ioutil.ReadAll(res.Body)
@@ -166,70 +417,148 @@ func (suite *APITestSuite) TestGetJobSuccess() {
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
+func (suite *APITestSuite) TestGetJobYAML() {
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+
+	req, _ := http.NewRequest("GET", suite.url("jobs", suite.j.ID), nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/x-yaml")
+	res, _ := http.DefaultClient.Do(req)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("application/x-yaml", res.Header["Content-Type"][0])
+	suite.Equal("id: \"123\"\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetJobPrefersJSON() {
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+
+	req, _ := http.NewRequest("GET", suite.url("jobs", suite.j.ID), nil)
+	req.Header.Set("Accept", "application/json, text/yaml")
+	res, _ := http.DefaultClient.Do(req)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal("application/json", res.Header["Content-Type"][0])
+	suite.Equal("{\"id\":\"123\"}\n", string(body))
+}
+
 func (suite *APITestSuite) TestGetJobNotFound() {
-	suite.jm.On("GetByID", suite.j.ID).Return(nil, suite.notFoundErr)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(nil, suite.notFoundErr)
 
 	res, _ := http.Get(suite.url("jobs", suite.j.ID))
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusNotFound, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"Cannot find job with ID 123\"}]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
 func (suite *APITestSuite) TestGetJobServerError() {
-	suite.jm.On("GetByID", suite.j.ID).Return(nil, suite.serverErr)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(nil, suite.serverErr)
 
 	res, _ := http.Get(suite.url("jobs", suite.j.ID))
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusInternalServerError, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"oops\"}]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
 func (suite *APITestSuite) TestGetJobLogSuccess() {
 	index := 99
-	jobLog := &job.JobLog{Lines: []string{"foo", "bar"}}
+	jobLog := &job.JobLog{
+		Index: 101,
+		Entries: []job.LogEntry{
+			{Step: 1, Stream: "system", Text: "foo"},
+			{Step: 1, Stream: "stdout", Text: "bar"},
+		},
+	}
 
-	suite.jm.On("GetByID", suite.j.ID).Return(suite.j, nil)
-	suite.jm.On("GetLog", suite.j, index).Return(jobLog, nil)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetLog", suite.j, job.LogQuery{Index: index}).Return(jobLog, nil)
 
 	res, _ := http.Get(suite.url("jobs", suite.j.ID, "log") + "?index=" + strconv.Itoa(index))
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusOK, res.StatusCode)
-	suite.Equal("{\"lines\":[\"foo\",\"bar\"]}\n", string(body))
+	suite.Equal("{\"index\":101,\"lines\":[\"bar\"]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
+func (suite *APITestSuite) TestGetJobLogEntries() {
+	ts := time.Date(2015, 3, 19, 10, 0, 0, 0, time.UTC)
+	jobLog := &job.JobLog{Index: 5, Entries: []job.LogEntry{{Time: ts, Step: 2, Stream: "stderr", Text: "oops"}}}
+
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetLog", suite.j, job.LogQuery{Index: 3, Step: 2, Stream: "stderr"}).Return(jobLog, nil)
+
+	res, _ := http.Get(suite.url("jobs", suite.j.ID, "log") + "?index=3&step=2&stream=stderr&format=entries")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("{\"index\":5,\"entries\":[{\"ts\":\"2015-03-19T10:00:00Z\",\"step\":2,\"stream\":\"stderr\",\"text\":\"oops\"}]}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetJobLogInvalidStep() {
+	res, _ := http.Get(suite.url("jobs", suite.j.ID, "log") + "?step=two")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"field\":\"step\",\"message\":\"must be a step number\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "GetByID", "", suite.j.ID)
+}
+
+func (suite *APITestSuite) TestGetJobLogInvalidFormat() {
+	res, _ := http.Get(suite.url("jobs", suite.j.ID, "log") + "?format=xml")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"field\":\"format\",\"message\":\"must be \\\"lines\\\" or \\\"entries\\\"\"}]}\n", string(body))
+}
+
 func (suite *APITestSuite) TestGetJobLogNotFound() {
-	suite.jm.On("GetByID", suite.j.ID).Return(nil, suite.notFoundErr)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(nil, suite.notFoundErr)
 
 	res, _ := http.Get(suite.url("jobs", suite.j.ID, "log"))
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusNotFound, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"Cannot find job with ID 123\"}]}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetServiceLogSuccess() {
+	jobLog := &job.JobLog{Index: 3, Entries: []job.LogEntry{{Stream: "service", Text: "db: ready"}}}
+
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetServiceLog", suite.j, job.LogQuery{Index: 2}).Return(jobLog, nil)
+
+	res, _ := http.Get(suite.url("jobs", suite.j.ID, "services", "log") + "?index=2")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("{\"index\":3,\"lines\":[\"db: ready\"]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
 func (suite *APITestSuite) TestGetJobLogError() {
 	index := 99
 
-	suite.jm.On("GetByID", suite.j.ID).Return(suite.j, nil)
-	suite.jm.On("GetLog", suite.j, index).Return(nil, suite.serverErr)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetLog", suite.j, job.LogQuery{Index: index}).Return(nil, suite.serverErr)
 
 	res, _ := http.Get(suite.url("jobs", suite.j.ID, "log") + "?index=" + strconv.Itoa(index))
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusInternalServerError, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"oops\"}]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
 func (suite *APITestSuite) TestDeleteJobSuccess() {
-	suite.jm.On("GetByID", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
 	suite.jm.On("Delete", suite.j).Return(nil)
 
 	req, _ := http.NewRequest("DELETE", suite.url("jobs", suite.j.ID), nil)
@@ -242,19 +571,19 @@ func (suite *APITestSuite) TestDeleteJobSuccess() {
 }
 
 func (suite *APITestSuite) TestDeleteJobNotFound() {
-	suite.jm.On("GetByID", suite.j.ID).Return(nil, suite.notFoundErr)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(nil, suite.notFoundErr)
 
 	req, _ := http.NewRequest("DELETE", suite.url("jobs", suite.j.ID), nil)
 	res, _ := suite.client.Do(req)
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusNotFound, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"Cannot find job with ID 123\"}]}\n", string(body))
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
 func (suite *APITestSuite) TestDeleteJobError() {
-	suite.jm.On("GetByID", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
 	suite.jm.On("Delete", suite.j).Return(suite.serverErr)
 
 	req, _ := http.NewRequest("DELETE", suite.url("jobs", suite.j.ID), nil)
@@ -262,10 +591,504 @@ func (suite *APITestSuite) TestDeleteJobError() {
 	body, _ := ioutil.ReadAll(res.Body)
 
 	suite.Equal(http.StatusInternalServerError, res.StatusCode)
-	suite.Equal("", string(body))
+	suite.Equal("{\"errors\":[{\"message\":\"oops\"}]}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestRerunJobSuccess() {
+	rerun := &job.Job{ID: "456", ParentID: suite.j.ID}
+
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("Rerun", suite.j).Return(rerun, nil)
+	suite.jm.On("Execute", rerun).Return(nil)
+
+	res, _ := http.Post(suite.url("jobs", suite.j.ID, "rerun"), "application/json", nil)
+	body, _ := ioutil.ReadAll(res.Body)
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.Equal("{\"id\":\"456\",\"parentId\":\"123\"}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestRerunJobNotFound() {
+	suite.jm.On("GetByID", "", suite.j.ID).Return(nil, suite.notFoundErr)
+
+	res, _ := http.Post(suite.url("jobs", suite.j.ID, "rerun"), "application/json", nil)
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestResumeJobSuccess() {
+	resumed := &job.Job{ID: "456", ParentID: suite.j.ID, StepsCompleted: 2}
+
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("Resume", suite.j).Return(resumed, nil)
+	suite.jm.On("Execute", resumed).Return(nil)
+
+	res, _ := http.Post(suite.url("jobs", suite.j.ID, "resume"), "application/json", nil)
+	body, _ := ioutil.ReadAll(res.Body)
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.Equal("{\"id\":\"456\",\"stepsCompleted\":2,\"parentId\":\"123\"}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestResumeJobInvalid() {
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("Resume", suite.j).Return(nil, job.NewValidationError("name", "required"))
+
+	res, _ := http.Post(suite.url("jobs", suite.j.ID, "resume"), "application/json", nil)
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestListTemplatesSuccess() {
+	templates := []job.JobTemplate{{Name: "foo", Version: 1}}
+	suite.jm.On("ListTemplates").Return(templates, nil)
+
+	res, _ := http.Get(suite.url("templates"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("[{\"name\":\"foo\",\"version\":1,\"job\":{}}]\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateTemplateSuccess() {
+	payload := "{\"name\":\"foo\",\"job\":{\"name\":\"bar\"}}\n"
+
+	suite.jm.On("CreateTemplate", mock.AnythingOfType("*job.JobTemplate")).Return(nil)
+
+	res, _ := http.Post(suite.url("templates"), "application/json", bytes.NewBufferString(payload))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.Equal(payload, string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateTemplateInvalid() {
+	suite.jm.On("CreateTemplate", mock.AnythingOfType("*job.JobTemplate")).Return(job.NewValidationError("name", "required"))
+
+	res, _ := http.Post(suite.url("templates"), "application/json", bytes.NewBufferString("{}"))
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetTemplateNotFound() {
+	suite.jm.On("GetTemplate", "foo").Return(nil, job.TemplateNotFoundError("foo"))
+
+	res, _ := http.Get(suite.url("templates", "foo"))
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestDeleteTemplateSuccess() {
+	suite.jm.On("GetTemplate", "foo").Return(suite.t, nil)
+	suite.jm.On("DeleteTemplate", suite.t).Return(nil)
+
+	req, _ := http.NewRequest("DELETE", suite.url("templates", "foo"), nil)
+	res, _ := suite.client.Do(req)
+
+	suite.Equal(http.StatusNoContent, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateTemplateJobSuccess() {
+	payload := "{\"parameters\":{\"COUNT\":10}}"
+
+	suite.jm.On("GetTemplate", "foo").Return(suite.t, nil)
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.jm.On("Execute", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	res, _ := http.Post(suite.url("templates", "foo", "jobs"), "application/json", bytes.NewBufferString(payload))
+	body, _ := ioutil.ReadAll(res.Body)
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.Equal("{\"environment\":[{\"variable\":\"COUNT\",\"value\":\"10\"}],\"template\":\"foo\",\"templateVersion\":2}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateTemplateJobMissingParameter() {
+	suite.jm.On("GetTemplate", "foo").Return(suite.t, nil)
+
+	res, _ := http.Post(suite.url("templates", "foo", "jobs"), "application/json", bytes.NewBufferString(""))
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestListSchedulesSuccess() {
+	schedules := []job.Schedule{{ID: "abc", Cron: "@daily"}}
+	suite.jm.On("ListSchedules").Return(schedules, nil)
+
+	res, _ := http.Get(suite.url("schedules"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("[{\"id\":\"abc\",\"cron\":\"@daily\"}]\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateScheduleSuccess() {
+	payload := "{\"cron\":\"@daily\",\"template\":\"foo\"}\n"
+
+	suite.jm.On("CreateSchedule", mock.AnythingOfType("*job.Schedule")).Return(nil)
+
+	res, _ := http.Post(suite.url("schedules"), "application/json", bytes.NewBufferString(payload))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.Equal(payload, string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestCreateScheduleInvalid() {
+	suite.jm.On("CreateSchedule", mock.AnythingOfType("*job.Schedule")).Return(job.NewValidationError("name", "required"))
+
+	res, _ := http.Post(suite.url("schedules"), "application/json", bytes.NewBufferString("{}"))
+
+	suite.Equal(http.StatusBadRequest, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestGetScheduleNotFound() {
+	suite.jm.On("GetSchedule", "abc").Return(nil, job.ScheduleNotFoundError("abc"))
+
+	res, _ := http.Get(suite.url("schedules", "abc"))
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestUpdateScheduleSuccess() {
+	payload := "{\"id\":\"abc\",\"cron\":\"@hourly\",\"template\":\"foo\"}\n"
+
+	suite.jm.On("GetSchedule", "abc").Return(&job.Schedule{ID: "abc", Cron: "@daily"}, nil)
+	suite.jm.On("UpdateSchedule", &job.Schedule{ID: "abc", Cron: "@hourly", Template: "foo"}).Return(nil)
+
+	req, _ := http.NewRequest("PUT", suite.url("schedules", "abc"), bytes.NewBufferString("{\"cron\":\"@hourly\",\"template\":\"foo\"}"))
+	res, _ := suite.client.Do(req)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal(payload, string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestDeleteScheduleSuccess() {
+	s := &job.Schedule{ID: "abc"}
+	suite.jm.On("GetSchedule", "abc").Return(s, nil)
+	suite.jm.On("DeleteSchedule", s).Return(nil)
+
+	req, _ := http.NewRequest("DELETE", suite.url("schedules", "abc"), nil)
+	res, _ := suite.client.Do(req)
+
+	suite.Equal(http.StatusNoContent, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestMetrics() {
+	suite.jm.On("GetByID", "", suite.j.ID).Return(nil, suite.notFoundErr)
+	http.Get(suite.url("v1", "jobs", suite.j.ID))
+
+	res, _ := http.Get(suite.url("metrics"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("text/plain; version=0.0.4", res.Header.Get("Content-Type"))
+	suite.Contains(string(body), "# TYPE dray_http_requests_total counter\n")
+	suite.Contains(string(body), `dray_http_requests_total{method="GET",route="/jobs/{jobid}",status="404"} `)
+	suite.Contains(string(body), "# TYPE dray_jobs_running gauge\n")
+}
+
+func (suite *APITestSuite) TestCreateJobShuttingDown() {
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(job.ShuttingDownError{})
+
+	res, _ := http.Post(suite.url("jobs"), "application/json", strings.NewReader(`{"steps":[{"source":"foo"}]}`))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusServiceUnavailable, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"Dray is shutting down and is not accepting new jobs\"}]}\n", string(body))
+}
+
+func (suite *APITestSuite) TestHealthz() {
+	res, _ := http.Get(suite.url("healthz"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("application/json", res.Header.Get("Content-Type"))
+	suite.Equal("{\"status\":\"ok\"}\n", string(body))
+}
+
+func (suite *APITestSuite) TestReadyz() {
+	suite.jm.On("Readiness").Return(job.Readiness{
+		Status:  "ok",
+		Redis:   job.DependencyHealth{Status: "ok"},
+		Docker:  job.DependencyHealth{Status: "ok"},
+		Workers: job.WorkerHealth{Status: "ok", Running: 1, Capacity: 4},
+	})
+
+	res, _ := http.Get(suite.url("readyz"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("{\"status\":\"ok\",\"redis\":{\"status\":\"ok\"},\"docker\":{\"status\":\"ok\"},\"workers\":{\"status\":\"ok\",\"running\":1,\"capacity\":4}}\n", string(body))
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestReadyzUnavailable() {
+	suite.jm.On("Readiness").Return(job.Readiness{
+		Status:  "unavailable",
+		Redis:   job.DependencyHealth{Status: "ok"},
+		Docker:  job.DependencyHealth{Status: "unavailable"},
+		Workers: job.WorkerHealth{Status: "ok"},
+	})
+
+	res, _ := http.Get(suite.url("readyz"))
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusServiceUnavailable, res.StatusCode)
+	suite.Equal("{\"status\":\"unavailable\",\"redis\":{\"status\":\"ok\"},\"docker\":{\"status\":\"unavailable\"},\"workers\":{\"status\":\"ok\",\"running\":0}}\n", string(body))
+}
+
+// Replaces the test server with one which requires authentication.
+func (suite *APITestSuite) requireAuth() {
+	suite.svr.Close()
+
+	a := &auth.Authenticator{Tokens: []auth.Token{
+		{Token: "reader", Name: "ro", Scopes: []string{auth.ScopeRead}},
+		{Token: "writer", Name: "ci", Scopes: []string{auth.ScopeWrite}},
+		{Token: "admin", Name: "ops", Scopes: []string{auth.ScopeAdmin}},
+		{Token: "team-a", Name: "team-a-ci", Scopes: []string{auth.ScopeWrite}, Namespace: "team-a"},
+		{Token: "team-a-admin", Name: "team-a-ops", Scopes: []string{auth.ScopeAdmin}, Namespace: "team-a"},
+	}}
+	server, _ := NewServer(suite.jm, a).(*jobServer)
+	suite.svr = httptest.NewServer(server.createRouter())
+}
+
+func (suite *APITestSuite) request(method, token string, body string, parts ...string) *http.Response {
+	req, _ := http.NewRequest(method, suite.url(parts...), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, _ := suite.client.Do(req)
+	return res
+}
+
+func (suite *APITestSuite) TestAuthMissingToken() {
+	suite.requireAuth()
+
+	res := suite.request("GET", "", "", "jobs")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusUnauthorized, res.StatusCode)
+	suite.Equal(`Bearer realm="dray"`, res.Header.Get("WWW-Authenticate"))
+	suite.Equal("{\"errors\":[{\"message\":\"authentication required\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "ListAll", "")
+}
+
+func (suite *APITestSuite) TestAuthInvalidToken() {
+	suite.requireAuth()
+
+	res := suite.request("DELETE", "wrong", "", "jobs", suite.j.ID)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusUnauthorized, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"invalid or expired token\"}]}\n", string(body))
+}
+
+func (suite *APITestSuite) TestAuthInsufficientScope() {
+	suite.requireAuth()
+
+	res := suite.request("POST", "reader", `{"steps":[{"source":"foo"}]}`, "jobs")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusForbidden, res.StatusCode)
+	suite.Equal(`Bearer realm="dray", error="insufficient_scope", scope="jobs:write"`, res.Header.Get("WWW-Authenticate"))
+	suite.Equal("{\"errors\":[{\"message\":\"the jobs:write scope is required\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "Create", mock.Anything)
+}
+
+func (suite *APITestSuite) TestAuthAdminScopeForTemplates() {
+	suite.requireAuth()
+	suite.jm.On("DeleteTemplate", &job.JobTemplate{Name: "foo"}).Return(nil)
+	suite.jm.On("GetTemplate", "foo").Return(&job.JobTemplate{Name: "foo"}, nil)
+
+	res := suite.request("DELETE", "writer", "", "templates", "foo")
+	suite.Equal(http.StatusForbidden, res.StatusCode)
+
+	res = suite.request("DELETE", "admin", "", "templates", "foo")
+	suite.Equal(http.StatusNoContent, res.StatusCode)
+}
+
+func (suite *APITestSuite) TestAuthReadScope() {
+	suite.requireAuth()
+	suite.jm.On("ListAll", "").Return([]job.Job{}, nil)
+
+	res := suite.request("GET", "reader", "", "v1", "jobs")
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+}
+
+func (suite *APITestSuite) TestAuthRecordsSubmitter() {
+	suite.requireAuth()
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.jm.On("Execute", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	res := suite.request("POST", "writer", `{"name":"foo","submittedBy":"someone-else"}`, "jobs")
+	body, _ := ioutil.ReadAll(res.Body)
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.Equal("{\"name\":\"foo\",\"submittedBy\":\"ci\"}\n", string(body))
+}
+
+func (suite *APITestSuite) TestAuthRecordsRerunSubmitter() {
+	suite.requireAuth()
+	rerun := &job.Job{ID: "456", ParentID: suite.j.ID, SubmittedBy: "ops"}
+
+	suite.jm.On("GetByID", "", suite.j.ID).Return(suite.j, nil)
+	suite.jm.On("Rerun", &job.Job{ID: suite.j.ID, SubmittedBy: "ops"}).Return(rerun, nil)
+	suite.jm.On("Execute", rerun).Return(nil)
+
+	res := suite.request("POST", "admin", "", "jobs", suite.j.ID, "rerun")
+	time.Sleep(time.Millisecond)
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
 	suite.jm.Mock.AssertExpectations(suite.T())
 }
 
+func (suite *APITestSuite) TestAuthPrincipalNamespace() {
+	suite.requireAuth()
+	suite.jm.On("ListAll", "team-a").Return([]job.Job{}, nil)
+	suite.jm.On("GetByID", "team-a", suite.j.ID).Return(suite.j, nil)
+
+	res := suite.request("GET", "team-a", "", "jobs")
+	suite.Equal(http.StatusOK, res.StatusCode)
+
+	res = suite.request("GET", "team-a", "", "namespaces", "team-a", "jobs", suite.j.ID)
+	suite.Equal(http.StatusOK, res.StatusCode)
+
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestAuthOtherNamespace() {
+	suite.requireAuth()
+
+	res := suite.request("GET", "team-a", "", "namespaces", "team-b", "jobs")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusForbidden, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"message\":\"access to namespace team-b is not permitted\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "ListAll", "team-b")
+}
+
+func (suite *APITestSuite) TestAuthCreateScheduleNamespace() {
+	suite.requireAuth()
+	expected := &job.Schedule{Cron: "@daily", Template: "foo", Namespace: "team-a"}
+	suite.jm.On("CreateSchedule", expected).Return(nil)
+
+	res := suite.request("POST", "team-a-admin", `{"cron":"@daily","template":"foo"}`, "schedules")
+
+	suite.Equal(http.StatusCreated, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestAuthCreateScheduleOtherNamespace() {
+	suite.requireAuth()
+
+	res := suite.request("POST", "team-a-admin", `{"cron":"@daily","template":"foo","namespace":"team-b"}`, "schedules")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusForbidden, res.StatusCode)
+	suite.Equal("{\"errors\":[{\"field\":\"namespace\",\"message\":\"access to namespace team-b is not permitted\"}]}\n", string(body))
+	suite.jm.Mock.AssertNotCalled(suite.T(), "CreateSchedule", mock.Anything)
+}
+
+func (suite *APITestSuite) TestAuthListSchedulesNamespace() {
+	suite.requireAuth()
+	suite.jm.On("ListSchedules").Return([]job.Schedule{
+		{ID: "abc", Namespace: "team-a"},
+		{ID: "def", Namespace: "team-b"},
+		{ID: "ghi"},
+	}, nil)
+
+	res := suite.request("GET", "team-a", "", "schedules")
+	body, _ := ioutil.ReadAll(res.Body)
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("[{\"id\":\"abc\",\"cron\":\"\",\"namespace\":\"team-a\"}]\n", string(body))
+}
+
+func (suite *APITestSuite) TestAuthGetScheduleOtherNamespace() {
+	suite.requireAuth()
+	suite.jm.On("GetSchedule", "abc").Return(&job.Schedule{ID: "abc", Namespace: "team-b"}, nil)
+
+	res := suite.request("GET", "team-a", "", "schedules", "abc")
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (suite *APITestSuite) TestAuthDeleteScheduleOtherNamespace() {
+	suite.requireAuth()
+	suite.jm.On("GetSchedule", "abc").Return(&job.Schedule{ID: "abc"}, nil)
+
+	res := suite.request("DELETE", "team-a-admin", "", "schedules", "abc")
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+	suite.jm.Mock.AssertNotCalled(suite.T(), "DeleteSchedule", mock.Anything)
+}
+
+func (suite *APITestSuite) TestAuthUpdateScheduleNamespace() {
+	suite.requireAuth()
+	suite.jm.On("GetSchedule", "abc").Return(&job.Schedule{ID: "abc", Namespace: "team-a"}, nil)
+	expected := &job.Schedule{ID: "abc", Cron: "@hourly", Template: "foo", Namespace: "team-a"}
+	suite.jm.On("UpdateSchedule", expected).Return(nil)
+
+	res := suite.request("PUT", "team-a-admin", `{"cron":"@hourly","template":"foo","namespace":"team-a"}`, "schedules", "abc")
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *APITestSuite) TestAuthUpdateScheduleOtherNamespace() {
+	suite.requireAuth()
+	suite.jm.On("GetSchedule", "abc").Return(&job.Schedule{ID: "abc", Namespace: "team-a"}, nil)
+
+	res := suite.request("PUT", "team-a-admin", `{"cron":"@hourly","template":"foo","namespace":"team-b"}`, "schedules", "abc")
+
+	suite.Equal(http.StatusForbidden, res.StatusCode)
+	suite.jm.Mock.AssertNotCalled(suite.T(), "UpdateSchedule", mock.Anything)
+}
+
+func (suite *APITestSuite) TestAuthUpdateScheduleInOtherNamespace() {
+	suite.requireAuth()
+	suite.jm.On("GetSchedule", "abc").Return(&job.Schedule{ID: "abc", Namespace: "team-b"}, nil)
+
+	res := suite.request("PUT", "team-a-admin", `{"cron":"@hourly","template":"foo"}`, "schedules", "abc")
+
+	suite.Equal(http.StatusNotFound, res.StatusCode)
+	suite.jm.Mock.AssertNotCalled(suite.T(), "UpdateSchedule", mock.Anything)
+}
+
+func (suite *APITestSuite) TestAuthNotRequiredForHealth() {
+	suite.requireAuth()
+
+	res := suite.request("GET", "", "", "healthz")
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+}
+
 func (suite *APITestSuite) url(parts ...string) string {
 	parts = append([]string{suite.svr.URL}, parts...)
 	return strings.Join(parts, "/")
@@ -2,16 +2,37 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/CenturyLinkLabs/dray/auth"
 	"github.com/CenturyLinkLabs/dray/job"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
 )
 
+const (
+	contentTypeYAML = "application/x-yaml"
+
+	logFormatLines   = "lines"
+	logFormatEntries = "entries"
+)
+
+// logLines is the plain-text representation of a job log, as returned by
+// the original log API.
+type logLines struct {
+	Index int      `json:"index"`
+	Lines []string `json:"lines"`
+}
+
 func listJobs(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
-	jobs, err := jm.ListAll()
+	jobs, err := jm.ListAll(namespace(r))
 	if err != nil {
 		handleErr(err, w)
 		return
@@ -21,19 +42,37 @@ func listJobs(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
 }
 
 func createJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	var err error
 	j := &job.Job{}
-	err := json.NewDecoder(r.Body).Decode(j)
+
+	if isYAML(r.Header.Get("Content-Type")) {
+		err = decodeYAMLBody(r, j)
+	} else {
+		err = decodeBody(r, j)
+	}
+
 	if err != nil {
 		handleErr(err, w)
 		return
 	}
 
-	err = jm.Create(j)
+	submitJob(jm, j, r, w)
+}
+
+func submitJob(jm job.JobManager, j *job.Job, r *http.Request, w http.ResponseWriter) {
+	j.SubmittedBy = submitter(r)
+	j.Namespace = namespace(r)
+
+	err := jm.Create(j)
 	if err != nil {
 		handleErr(err, w)
 		return
 	}
 
+	executeJob(jm, j, w)
+}
+
+func executeJob(jm job.JobManager, j *job.Job, w http.ResponseWriter) {
 	go func() {
 		if err := jm.Execute(j); err != nil {
 			log.Error(err)
@@ -46,44 +85,100 @@ func createJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
 
 func getJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
 	jobID := mux.Vars(r)["jobid"]
-	j, err := jm.GetByID(jobID)
+	j, err := jm.GetByID(namespace(r), jobID)
 
 	if err != nil {
 		handleErr(err, w)
 		return
 	}
 
+	if acceptsYAML(r) {
+		b, err := yaml.Marshal(j)
+		if err != nil {
+			handleErr(err, w)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypeYAML)
+		w.Write(b)
+		return
+	}
+
 	json.NewEncoder(w).Encode(j)
 }
 
 func getJobLog(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	writeLog(jm, jm.GetLog, r, w)
+}
+
+func getServiceLog(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	writeLog(jm, jm.GetServiceLog, r, w)
+}
+
+// Writes the entries from one of the job's logs which are selected by the
+// index, step and stream query parameters. Unless the "entries" format is
+// requested the log is written as plain lines of text.
+func writeLog(jm job.JobManager, getLog func(*job.Job, job.LogQuery) (*job.JobLog, error), r *http.Request, w http.ResponseWriter) {
 	jobID := mux.Vars(r)["jobid"]
 
-	indexQuery := querystringValue(r, "index")
-	index, err := strconv.Atoi(indexQuery)
+	format := querystringValue(r, "format")
+	if len(format) == 0 {
+		format = logFormatLines
+	} else if format != logFormatLines && format != logFormatEntries {
+		handleErr(job.NewValidationError("format", fmt.Sprintf("must be %q or %q", logFormatLines, logFormatEntries)), w)
+		return
+	}
+
+	q, err := logQuery(r)
 	if err != nil {
-		index = 0
+		handleErr(err, w)
+		return
 	}
 
-	j, err := jm.GetByID(jobID)
+	j, err := jm.GetByID(namespace(r), jobID)
 	if err != nil {
 		handleErr(err, w)
 		return
 	}
 
-	log, err := jm.GetLog(j, index)
+	log, err := getLog(j, q)
 	if err != nil {
 		handleErr(err, w)
 		return
 	}
 
-	json.NewEncoder(w).Encode(log)
+	if format == logFormatEntries {
+		json.NewEncoder(w).Encode(log)
+		return
+	}
+
+	json.NewEncoder(w).Encode(logLines{Index: log.Index, Lines: log.Lines()})
+}
+
+// Returns the LogQuery described by the request's query parameters. A
+// missing or malformed index starts the log from the beginning.
+func logQuery(r *http.Request) (job.LogQuery, error) {
+	q := job.LogQuery{Stream: querystringValue(r, "stream")}
+
+	if index, err := strconv.Atoi(querystringValue(r, "index")); err == nil {
+		q.Index = index
+	}
+
+	if step := querystringValue(r, "step"); len(step) > 0 {
+		n, err := strconv.Atoi(step)
+		if err != nil {
+			return q, job.NewValidationError("step", "must be a step number")
+		}
+		q.Step = n
+	}
+
+	return q, nil
 }
 
 func deleteJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
 	jobID := mux.Vars(r)["jobid"]
 
-	j, err := jm.GetByID(jobID)
+	j, err := jm.GetByID(namespace(r), jobID)
 	if err != nil {
 		handleErr(err, w)
 		return
@@ -98,6 +193,332 @@ func deleteJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func rerunJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	jobID := mux.Vars(r)["jobid"]
+
+	j, err := jm.GetByID(namespace(r), jobID)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	// The new job is attributed to the principal which requested it
+	j.SubmittedBy = submitter(r)
+
+	rerun, err := jm.Rerun(j)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	executeJob(jm, rerun, w)
+}
+
+func resumeJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	jobID := mux.Vars(r)["jobid"]
+
+	j, err := jm.GetByID(namespace(r), jobID)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	j.SubmittedBy = submitter(r)
+
+	resumed, err := jm.Resume(j)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	executeJob(jm, resumed, w)
+}
+
+func listTemplates(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	templates, err := jm.ListTemplates()
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	json.NewEncoder(w).Encode(templates)
+}
+
+func createTemplate(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	t := &job.JobTemplate{}
+	err := decodeBody(r, t)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	err = jm.CreateTemplate(t)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+func getTemplate(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	name := mux.Vars(r)["name"]
+	t, err := jm.GetTemplate(name)
+
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	json.NewEncoder(w).Encode(t)
+}
+
+func deleteTemplate(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	name := mux.Vars(r)["name"]
+
+	t, err := jm.GetTemplate(name)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	err = jm.DeleteTemplate(t)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func createTemplateJob(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	name := mux.Vars(r)["name"]
+
+	t, err := jm.GetTemplate(name)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	req := struct {
+		Parameters map[string]interface{} `json:"parameters"`
+	}{}
+
+	// The request body is optional when no parameters are supplied
+	if r.ContentLength != 0 {
+		if err := decodeBody(r, &req); err != nil {
+			handleErr(err, w)
+			return
+		}
+	}
+
+	// Parameter values may be submitted as JSON strings, numbers or booleans
+	values := map[string]string{}
+	for name, value := range req.Parameters {
+		values[name] = fmt.Sprint(value)
+	}
+
+	j, err := t.Instantiate(values)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	submitJob(jm, j, r, w)
+}
+
+func listSchedules(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	schedules, err := jm.ListSchedules()
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	visible := []job.Schedule{}
+	for _, s := range schedules {
+		if scheduleVisible(&s, r) {
+			visible = append(visible, s)
+		}
+	}
+
+	json.NewEncoder(w).Encode(visible)
+}
+
+func createSchedule(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	s := &job.Schedule{}
+	err := decodeBody(r, s)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	if !setScheduleNamespace(s, r, w) {
+		return
+	}
+
+	err = jm.CreateSchedule(s)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+func getSchedule(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	s, err := findSchedule(jm, r)
+
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	json.NewEncoder(w).Encode(s)
+}
+
+func updateSchedule(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	s := &job.Schedule{}
+	err := decodeBody(r, s)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	if _, err := findSchedule(jm, r); err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	s.ID = mux.Vars(r)["scheduleid"]
+	if !setScheduleNamespace(s, r, w) {
+		return
+	}
+
+	err = jm.UpdateSchedule(s)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	json.NewEncoder(w).Encode(s)
+}
+
+// Returns the schedule named in the request path. A schedule in a namespace
+// the request's principal cannot access is reported as not found, as jobs
+// in other namespaces are.
+func findSchedule(jm job.JobManager, r *http.Request) (*job.Schedule, error) {
+	scheduleID := mux.Vars(r)["scheduleid"]
+
+	s, err := jm.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !scheduleVisible(s, r) {
+		return nil, job.ScheduleNotFoundError(scheduleID)
+	}
+
+	return s, nil
+}
+
+// Returns true if the request's principal may access the schedule: either
+// the principal is not restricted to a namespace or the schedule creates its
+// jobs in the principal's namespace.
+func scheduleVisible(s *job.Schedule, r *http.Request) bool {
+	ns := namespace(r)
+	if len(ns) == 0 {
+		return true
+	}
+
+	scheduleNS := s.Namespace
+	if len(scheduleNS) == 0 {
+		scheduleNS = job.DefaultNamespace
+	}
+
+	return scheduleNS == ns
+}
+
+// Places a schedule in the namespace of the request's principal, if it is
+// restricted to one. A schedule naming any other namespace is rejected with a
+// 403 and false is returned.
+func setScheduleNamespace(s *job.Schedule, r *http.Request, w http.ResponseWriter) bool {
+	ns := namespace(r)
+	if len(ns) == 0 {
+		return true
+	}
+
+	if len(s.Namespace) > 0 && s.Namespace != ns {
+		writeErrors(w, http.StatusForbidden, []job.FieldError{{Field: "namespace", Message: fmt.Sprintf("access to namespace %s is not permitted", s.Namespace)}})
+		return false
+	}
+
+	s.Namespace = ns
+	return true
+}
+
+func deleteSchedule(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	s, err := findSchedule(jm, r)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	err = jm.DeleteSchedule(s)
+	if err != nil {
+		handleErr(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reports that the server process is running, without checking any of its
+// dependencies.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, `{"status":"ok"}`)
+}
+
+// Reports whether the server's dependencies can be reached and whether it has
+// capacity to run another job, responding with a 503 if it does not.
+func readyz(jm job.JobManager, r *http.Request, w http.ResponseWriter) {
+	readiness := jm.Readiness()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(readiness)
+}
+
+// Returns the name of the authenticated principal making the request, or an
+// empty string if authentication is not enabled.
+func submitter(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Name
+	}
+
+	return ""
+}
+
+// Returns the namespace of the jobs the request acts on: the namespace in the
+// request path if there is one, otherwise the namespace of the authenticated
+// principal. An empty string selects the default namespace.
+func namespace(r *http.Request) string {
+	if ns, ok := mux.Vars(r)["namespace"]; ok {
+		return ns
+	}
+
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Namespace
+	}
+
+	return ""
+}
+
 func querystringValue(r *http.Request, key string) string {
 	v := r.URL.Query()[key]
 
@@ -108,13 +529,113 @@ func querystringValue(r *http.Request, key string) string {
 	return v[0]
 }
 
+// Decodes the JSON request body into v. A missing or malformed body is
+// reported as a ValidationError so that the client receives a 400 response.
+func decodeBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+
+	err := decoder.Decode(v)
+	if err == io.EOF {
+		return job.NewValidationError("", "request body is required")
+	} else if err != nil {
+		return job.NewValidationError("", fmt.Sprintf("invalid request body: %s", err))
+	}
+
+	return nil
+}
+
+// Decodes the YAML request body into v. As with decodeBody, a missing or
+// malformed body is reported as a ValidationError.
+func decodeYAMLBody(r *http.Request, v interface{}) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return job.NewValidationError("", "request body is required")
+	}
+
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return job.NewValidationError("", fmt.Sprintf("invalid request body: %s", err))
+	}
+
+	return nil
+}
+
+// Returns true if the media type is one of the commonly used names for YAML.
+func isYAML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch mediaType {
+	case contentTypeYAML, "application/yaml", "text/yaml", "text/x-yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns true if the Accept header of the request prefers YAML over JSON.
+// Media types are ranked by their quality value with ties going to the type
+// listed first. JSON is assumed when the header is missing.
+func acceptsYAML(r *http.Request) bool {
+	best, bestQ := "", 0.0
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+
+	return isYAML(best)
+}
+
+type errorResponse struct {
+	Errors []job.FieldError `json:"errors"`
+}
+
 func handleErr(err error, w http.ResponseWriter) {
 	log.Error(err)
-	w.Header().Del("Content-Type")
 
-	if _, ok := err.(job.NotFoundError); ok {
-		w.WriteHeader(http.StatusNotFound)
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+	status := http.StatusInternalServerError
+	errs := []job.FieldError{{Message: err.Error()}}
+
+	switch e := err.(type) {
+	case job.NotFoundError, job.TemplateNotFoundError, job.ScheduleNotFoundError:
+		status = http.StatusNotFound
+	case job.ValidationError:
+		status = http.StatusBadRequest
+		errs = e
+	case job.PolicyViolationError:
+		status = http.StatusForbidden
+		errs = e
+	case job.ShuttingDownError:
+		status = http.StatusServiceUnavailable
+	case job.QuotaExceededError:
+		status = http.StatusTooManyRequests
 	}
+
+	writeErrors(w, status, errs)
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs []job.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Errors: errs})
 }
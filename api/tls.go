@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig holds the files used to serve the API over HTTPS. CertFile and
+// KeyFile hold the server's PEM-encoded certificate and key. If ClientCAFile
+// is set, every client must present a certificate signed by one of the CAs
+// it holds.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Enabled returns true if the server's certificate and key have been set.
+func (c TLSConfig) Enabled() bool {
+	return len(c.CertFile) > 0 && len(c.KeyFile) > 0
+}
+
+// Returns the TLS configuration for the http.Server. The server's own
+// certificate is loaded by ListenAndServeTLS.
+func (c TLSConfig) serverConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(c.ClientCAFile) == 0 {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates found in %s", c.ClientCAFile)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
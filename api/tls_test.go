@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes a self-signed certificate and its key to the directory, returning
+// their paths.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestTLSConfigEnabled(t *testing.T) {
+	assert.False(t, TLSConfig{}.Enabled())
+	assert.False(t, TLSConfig{CertFile: "cert.pem"}.Enabled())
+	assert.True(t, TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}.Enabled())
+}
+
+func TestTLSServerConfig(t *testing.T) {
+	c, err := TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}.serverConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, c.ClientAuth)
+	assert.Nil(t, c.ClientCAs)
+
+	_, err = TLSConfig{ClientCAFile: "/does/not/exist"}.serverConfig()
+	assert.Error(t, err)
+}
+
+func TestTLSServerConfigInvalidClientCA(t *testing.T) {
+	f, _ := ioutil.TempFile("", "ca.pem")
+	f.WriteString("not a certificate")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	_, err := TLSConfig{ClientCAFile: f.Name()}.serverConfig()
+	assert.EqualError(t, err, "No certificates found in "+f.Name())
+}
+
+func TestTLSServerRequiresClientCert(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dray-tls")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	c, err := TLSConfig{ClientCAFile: certFile}.serverConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, c.ClientAuth)
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(healthz))
+	svr.TLS = c
+	svr.StartTLS()
+	defer svr.Close()
+
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+	_, err = client.Get(svr.URL + "/healthz")
+	assert.Error(t, err)
+
+	pair, _ := tls.LoadX509KeyPair(certFile, keyFile)
+	clientConfig.Certificates = []tls.Certificate{pair}
+
+	resp, err := client.Get(svr.URL + "/healthz")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
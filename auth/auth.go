@@ -0,0 +1,181 @@
+/*
+Package auth authenticates requests to the Dray API. Requests carry either a
+static API token or an HMAC-signed JWT in a bearer Authorization header:
+
+	Authorization: Bearer <token>
+
+Each token identifies a Principal which is granted one or more scopes. The
+scopes are ordered so that "jobs:admin" implies "jobs:write", which in turn
+implies "jobs:read".
+*/
+package auth // import "github.com/CenturyLinkLabs/dray/auth"
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+// The scopes which may be granted to a Principal.
+const (
+	ScopeRead  = "jobs:read"
+	ScopeWrite = "jobs:write"
+	ScopeAdmin = "jobs:admin"
+)
+
+var scopeLevels = map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+var (
+	// ErrNoCredentials is returned when a request has no bearer token.
+	ErrNoCredentials = errors.New("authentication required")
+
+	// ErrInvalidToken is returned when a bearer token is neither a known API
+	// token nor a valid JWT.
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+type contextKey struct{}
+
+// Principal is the identity on whose behalf a request is made. A principal
+// with a Namespace may only access the jobs in that namespace.
+type Principal struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Namespace string   `json:"namespace,omitempty"`
+}
+
+// HasScope returns true if the principal has been granted the scope, either
+// directly or through a scope which implies it.
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if scopeLevels[s] >= scopeLevels[scope] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewContext returns a copy of the context which carries the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal carried by the context, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
+
+// Token is a static API token and the principal it identifies.
+type Token struct {
+	Token     string   `json:"token"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Namespace string   `json:"namespace,omitempty"`
+}
+
+// Authenticator verifies the bearer tokens presented with API requests
+// against a list of static Tokens and, if a JWTSecret is set, as JWTs signed
+// with HS256. When Issuer is set, JWTs must have been issued by it.
+type Authenticator struct {
+	Tokens    []Token
+	JWTSecret []byte
+	Issuer    string
+
+	now func() time.Time
+}
+
+// Enabled returns true if any means of authentication has been configured.
+// Requests need not be authenticated if it returns false.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (len(a.Tokens) > 0 || len(a.JWTSecret) > 0)
+}
+
+// Authenticate returns the principal identified by the request's bearer
+// token.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	token := strings.TrimSpace(parts[1])
+
+	for _, t := range a.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &Principal{Name: t.Name, Scopes: t.Scopes, Namespace: t.Namespace}, nil
+		}
+	}
+
+	if len(a.JWTSecret) > 0 && strings.Count(token, ".") == 2 {
+		return a.verifyJWT(token)
+	}
+
+	return nil, ErrInvalidToken
+}
+
+func (a *Authenticator) timeNow() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+
+	return time.Now()
+}
+
+// LoadTokens reads a list of static API tokens from a JSON file of the form:
+//
+//	{"tokens": [{"token": "s3cr3t", "name": "ci", "scopes": ["jobs:write"], "namespace": "team-a"}]}
+func LoadTokens(path string) ([]Token, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := struct {
+		Tokens []Token `json:"tokens"`
+	}{}
+
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("Invalid token file %s: %s", path, err)
+	}
+
+	for i, t := range file.Tokens {
+		if err := validateToken(t); err != nil {
+			return nil, fmt.Errorf("Invalid token %d in %s: %s", i+1, path, err)
+		}
+	}
+
+	return file.Tokens, nil
+}
+
+func validateToken(t Token) error {
+	if len(t.Token) == 0 {
+		return errors.New("token is required")
+	} else if len(t.Name) == 0 {
+		return errors.New("name is required")
+	} else if len(t.Namespace) > 0 && !job.ValidNamespace(t.Namespace) {
+		return fmt.Errorf("invalid namespace %q", t.Namespace)
+	}
+
+	for _, s := range t.Scopes {
+		if _, ok := scopeLevels[s]; !ok {
+			return fmt.Errorf("unknown scope %q", s)
+		}
+	}
+
+	return nil
+}
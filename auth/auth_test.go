@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func request(authorization string) *http.Request {
+	r, _ := http.NewRequest("GET", "/jobs", nil)
+	if len(authorization) > 0 {
+		r.Header.Set("Authorization", authorization)
+	}
+
+	return r
+}
+
+func TestPrincipalHasScope(t *testing.T) {
+	reader := Principal{Scopes: []string{ScopeRead}}
+	writer := Principal{Scopes: []string{ScopeWrite}}
+	admin := Principal{Scopes: []string{"other", ScopeAdmin}}
+
+	assert.True(t, reader.HasScope(ScopeRead))
+	assert.False(t, reader.HasScope(ScopeWrite))
+	assert.True(t, writer.HasScope(ScopeRead))
+	assert.True(t, writer.HasScope(ScopeWrite))
+	assert.False(t, writer.HasScope(ScopeAdmin))
+	assert.True(t, admin.HasScope(ScopeAdmin))
+	assert.False(t, Principal{}.HasScope(ScopeRead))
+}
+
+func TestAuthenticatorEnabled(t *testing.T) {
+	var nilAuth *Authenticator
+
+	assert.False(t, nilAuth.Enabled())
+	assert.False(t, (&Authenticator{}).Enabled())
+	assert.True(t, (&Authenticator{Tokens: []Token{{Token: "abc"}}}).Enabled())
+	assert.True(t, (&Authenticator{JWTSecret: []byte("secret")}).Enabled())
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	a := &Authenticator{Tokens: []Token{{Token: "s3cr3t", Name: "ci", Scopes: []string{ScopeWrite}, Namespace: "team-a"}}}
+
+	p, err := a.Authenticate(request("Bearer s3cr3t"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "ci", Scopes: []string{ScopeWrite}, Namespace: "team-a"}, p)
+}
+
+func TestAuthenticateMissing(t *testing.T) {
+	a := &Authenticator{Tokens: []Token{{Token: "s3cr3t", Name: "ci"}}}
+
+	_, err := a.Authenticate(request(""))
+	assert.Equal(t, ErrNoCredentials, err)
+
+	_, err = a.Authenticate(request("Basic Y2k6czNjcjN0"))
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestAuthenticateUnknownToken(t *testing.T) {
+	a := &Authenticator{Tokens: []Token{{Token: "s3cr3t", Name: "ci"}}}
+
+	_, err := a.Authenticate(request("Bearer wrong"))
+
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestContext(t *testing.T) {
+	p := &Principal{Name: "ci"}
+
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	result, ok := FromContext(NewContext(context.Background(), p))
+	assert.True(t, ok)
+	assert.Equal(t, p, result)
+}
+
+func TestLoadTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	ioutil.WriteFile(path, []byte(`{"tokens":[{"token":"s3cr3t","name":"ci","scopes":["jobs:read"]}]}`), 0600)
+
+	tokens, err := LoadTokens(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{{Token: "s3cr3t", Name: "ci", Scopes: []string{"jobs:read"}}}, tokens)
+}
+
+func TestLoadTokensInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	ioutil.WriteFile(path, []byte(`{"tokens":[{"token":"s3cr3t","name":"ci","scopes":["jobs:all"]}]}`), 0600)
+
+	_, err := LoadTokens(path)
+
+	assert.EqualError(t, err, `Invalid token 1 in `+path+`: unknown scope "jobs:all"`)
+
+	ioutil.WriteFile(path, []byte(`{"tokens":[{"token":"s3cr3t","name":"ci","namespace":"Team A"}]}`), 0600)
+	_, err = LoadTokens(path)
+	assert.EqualError(t, err, `Invalid token 1 in `+path+`: invalid namespace "Team A"`)
+}
+
+func TestLoadTokensMissingFile(t *testing.T) {
+	_, err := LoadTokens(filepath.Join(os.TempDir(), "missing-dray-tokens.json"))
+
+	assert.Error(t, err)
+}
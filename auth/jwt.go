@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+// jwtHeader is the JOSE header of a JWT. Only the HS256 algorithm is
+// accepted.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+// jwtClaims are the registered claims which Dray uses, along with the
+// scopes granted by the token and the namespace to which it is restricted.
+// Scopes may be given as a space-separated "scope" string (as in OAuth 2.0)
+// or as a "scopes" list. Every token must expire.
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	Scope     string   `json:"scope"`
+	Scopes    []string `json:"scopes"`
+	Namespace string   `json:"namespace"`
+}
+
+func (a *Authenticator) verifyJWT(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+
+	mac := hmac.New(sha256.New, a.JWTSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	header := jwtHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	claims := jwtClaims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := a.timeNow()
+	switch {
+	case len(claims.Subject) == 0:
+		return nil, ErrInvalidToken
+	case len(a.Issuer) > 0 && claims.Issuer != a.Issuer:
+		return nil, ErrInvalidToken
+	case claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0)):
+		return nil, ErrInvalidToken
+	case claims.NotBefore > 0 && now.Before(time.Unix(claims.NotBefore, 0)):
+		return nil, ErrInvalidToken
+	case len(claims.Namespace) > 0 && !job.ValidNamespace(claims.Namespace):
+		return nil, ErrInvalidToken
+	}
+
+	scopes := append(strings.Fields(claims.Scope), claims.Scopes...)
+	return &Principal{Name: claims.Subject, Scopes: scopes, Namespace: claims.Namespace}, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2015, 3, 19, 10, 0, 0, 0, time.UTC)
+
+func sign(secret, header, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func jwtAuthenticator() *Authenticator {
+	return &Authenticator{
+		JWTSecret: []byte("secret"),
+		Issuer:    "https://auth.example.com",
+		now:       func() time.Time { return now },
+	}
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	token := sign("secret", `{"alg":"HS256","typ":"JWT"}`,
+		`{"sub":"alice","iss":"https://auth.example.com","exp":1426760000,"scope":"jobs:read jobs:write"}`)
+
+	p, err := jwtAuthenticator().Authenticate(request("Bearer " + token))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "alice", Scopes: []string{ScopeRead, ScopeWrite}}, p)
+}
+
+func TestAuthenticateJWTScopesList(t *testing.T) {
+	token := sign("secret", `{"alg":"HS256"}`, `{"sub":"bob","iss":"https://auth.example.com","exp":1426760000,"scopes":["jobs:admin"],"namespace":"team-a"}`)
+
+	p, err := jwtAuthenticator().Authenticate(request("Bearer " + token))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ScopeAdmin}, p.Scopes)
+	assert.Equal(t, "team-a", p.Namespace)
+}
+
+func TestAuthenticateJWTInvalid(t *testing.T) {
+	tokens := map[string]string{
+		"bad signature": sign("other", `{"alg":"HS256"}`, `{"sub":"alice","iss":"https://auth.example.com","exp":1426760000}`),
+		"algorithm":     sign("secret", `{"alg":"none"}`, `{"sub":"alice","iss":"https://auth.example.com","exp":1426760000}`),
+		"expired":       sign("secret", `{"alg":"HS256"}`, `{"sub":"alice","iss":"https://auth.example.com","exp":1426750000}`),
+		"no expiry":     sign("secret", `{"alg":"HS256"}`, `{"sub":"alice","iss":"https://auth.example.com"}`),
+		"not yet valid": sign("secret", `{"alg":"HS256"}`, `{"sub":"alice","iss":"https://auth.example.com","exp":1426780000,"nbf":1426770000}`),
+		"issuer":        sign("secret", `{"alg":"HS256"}`, `{"sub":"alice","iss":"https://evil.example.com","exp":1426760000}`),
+		"no subject":    sign("secret", `{"alg":"HS256"}`, `{"iss":"https://auth.example.com","exp":1426760000}`),
+		"namespace":     sign("secret", `{"alg":"HS256"}`, `{"sub":"alice","iss":"https://auth.example.com","exp":1426760000,"namespace":"Team A"}`),
+		"malformed":     "abc.def.ghi",
+	}
+
+	for name, token := range tokens {
+		_, err := jwtAuthenticator().Authenticate(request("Bearer " + token))
+		assert.Equal(t, ErrInvalidToken, err, name)
+	}
+}
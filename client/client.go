@@ -0,0 +1,299 @@
+/*
+Package client provides a Go client for the Dray API.
+
+		c := client.New("http://localhost:3000")
+
+		j, err := c.CreateJob(ctx, &job.Job{
+			Name:  "Word Job",
+			Steps: []job.JobStep{{Source: "centurylink/randword"}},
+		})
+
+		j, err = c.FollowLog(ctx, j.ID, job.LogQuery{}, func(e job.LogEntry) error {
+			fmt.Println(e)
+			return nil
+		})
+
+All methods accept a context which can be used to cancel the underlying HTTP
+requests. Errors returned by the API are reported as one of the NotFoundError,
+ValidationError, AuthError or ServerError types.
+*/
+package client // import "github.com/CenturyLinkLabs/dray/client"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+const defaultPollInterval = time.Second
+
+// Client is a client for the Dray API.
+type Client struct {
+	// BaseURL is the root URL of the Dray API (e.g. "http://localhost:3000").
+	BaseURL string
+
+	// HTTPClient is the client used to make requests. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Namespace selects the namespace in which jobs are created, listed and
+	// retrieved. Defaults to the namespace of the API token, if it has one,
+	// or the server's default namespace.
+	Namespace string
+
+	// Token is sent as a bearer token with every request when the API
+	// requires authentication.
+	Token string
+
+	// PollInterval is the time to wait between log requests while following
+	// the log of a running job. Defaults to one second.
+	PollInterval time.Duration
+}
+
+// New returns a Client for the Dray API at the specified URL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTPClient:   http.DefaultClient,
+		PollInterval: defaultPollInterval,
+	}
+}
+
+// ListJobs returns all of the jobs known to Dray. Only the ID of each job is
+// populated.
+func (c *Client) ListJobs(ctx context.Context) ([]job.Job, error) {
+	jobs := []job.Job{}
+	err := c.do(ctx, "GET", c.jobsPath(), nil, &jobs)
+	return jobs, err
+}
+
+// CreateJob submits a job for execution and returns the job with its
+// assigned ID.
+func (c *Client) CreateJob(ctx context.Context, j *job.Job) (*job.Job, error) {
+	created := &job.Job{}
+	err := c.do(ctx, "POST", c.jobsPath(), j, created)
+	return created, err
+}
+
+// GetJob returns the definition and current state of the specified job.
+func (c *Client) GetJob(ctx context.Context, jobID string) (*job.Job, error) {
+	j := &job.Job{}
+	err := c.do(ctx, "GET", c.jobPath(jobID), nil, j)
+	return j, err
+}
+
+// GetJobLog returns the log entries of the specified job which are selected
+// by the query. The Index field of the returned JobLog contains the index
+// which should be used by the next query in order to retrieve only the new
+// entries.
+func (c *Client) GetJobLog(ctx context.Context, jobID string, q job.LogQuery) (*job.JobLog, error) {
+	return c.getLog(ctx, c.jobPath(jobID)+"/log", q)
+}
+
+// GetServiceLog returns the log entries written by the services of the
+// specified job which are selected by the query. The text of each entry is
+// prefixed with the name of the service which wrote it.
+func (c *Client) GetServiceLog(ctx context.Context, jobID string, q job.LogQuery) (*job.JobLog, error) {
+	return c.getLog(ctx, c.jobPath(jobID)+"/services/log", q)
+}
+
+func (c *Client) getLog(ctx context.Context, path string, q job.LogQuery) (*job.JobLog, error) {
+	jl := &job.JobLog{}
+	params := url.Values{"format": {"entries"}, "index": {strconv.Itoa(q.Index)}}
+
+	if q.Step > 0 {
+		params.Set("step", strconv.Itoa(q.Step))
+	}
+
+	if len(q.Stream) > 0 {
+		params.Set("stream", q.Stream)
+	}
+
+	if err := c.do(ctx, "GET", path+"?"+params.Encode(), nil, jl); err != nil {
+		return nil, err
+	}
+
+	return jl, nil
+}
+
+// DeleteJob removes all of the information persisted for the specified job.
+func (c *Client) DeleteJob(ctx context.Context, jobID string) error {
+	return c.do(ctx, "DELETE", c.jobPath(jobID), nil, nil)
+}
+
+// RerunJob creates and starts a new job from the definition of the specified
+// job.
+func (c *Client) RerunJob(ctx context.Context, jobID string) (*job.Job, error) {
+	j := &job.Job{}
+	err := c.do(ctx, "POST", c.jobPath(jobID)+"/rerun", nil, j)
+	return j, err
+}
+
+// ResumeJob creates and starts a new job which continues from the first
+// incomplete step of the specified job.
+func (c *Client) ResumeJob(ctx context.Context, jobID string) (*job.Job, error) {
+	j := &job.Job{}
+	err := c.do(ctx, "POST", c.jobPath(jobID)+"/resume", nil, j)
+	return j, err
+}
+
+// ListTemplates returns the latest version of all saved templates.
+func (c *Client) ListTemplates(ctx context.Context) ([]job.JobTemplate, error) {
+	templates := []job.JobTemplate{}
+	err := c.do(ctx, "GET", "/templates", nil, &templates)
+	return templates, err
+}
+
+// CreateTemplate saves a template and returns it with its assigned version.
+func (c *Client) CreateTemplate(ctx context.Context, t *job.JobTemplate) (*job.JobTemplate, error) {
+	created := &job.JobTemplate{}
+	err := c.do(ctx, "POST", "/templates", t, created)
+	return created, err
+}
+
+// GetTemplate returns the latest version of the specified template.
+func (c *Client) GetTemplate(ctx context.Context, name string) (*job.JobTemplate, error) {
+	t := &job.JobTemplate{}
+	err := c.do(ctx, "GET", templatePath(name), nil, t)
+	return t, err
+}
+
+// DeleteTemplate deletes the specified template.
+func (c *Client) DeleteTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", templatePath(name), nil, nil)
+}
+
+// CreateTemplateJob creates and starts a new job from the specified template
+// using the supplied parameter values.
+func (c *Client) CreateTemplateJob(ctx context.Context, name string, params map[string]string) (*job.Job, error) {
+	j := &job.Job{}
+	req := struct {
+		Parameters map[string]string `json:"parameters,omitempty"`
+	}{params}
+
+	err := c.do(ctx, "POST", c.namespacePath()+templatePath(name)+"/jobs", req, j)
+	return j, err
+}
+
+// ListSchedules returns all registered schedules.
+func (c *Client) ListSchedules(ctx context.Context) ([]job.Schedule, error) {
+	schedules := []job.Schedule{}
+	err := c.do(ctx, "GET", "/schedules", nil, &schedules)
+	return schedules, err
+}
+
+// CreateSchedule registers a schedule and returns it with its assigned ID
+// and next run time.
+func (c *Client) CreateSchedule(ctx context.Context, s *job.Schedule) (*job.Schedule, error) {
+	created := &job.Schedule{}
+	err := c.do(ctx, "POST", "/schedules", s, created)
+	return created, err
+}
+
+// GetSchedule returns the specified schedule.
+func (c *Client) GetSchedule(ctx context.Context, scheduleID string) (*job.Schedule, error) {
+	s := &job.Schedule{}
+	err := c.do(ctx, "GET", schedulePath(scheduleID), nil, s)
+	return s, err
+}
+
+// UpdateSchedule replaces the definition of the schedule identified by the
+// ID field of s.
+func (c *Client) UpdateSchedule(ctx context.Context, s *job.Schedule) (*job.Schedule, error) {
+	updated := &job.Schedule{}
+	err := c.do(ctx, "PUT", schedulePath(s.ID), s, updated)
+	return updated, err
+}
+
+// DeleteSchedule deletes the specified schedule.
+func (c *Client) DeleteSchedule(ctx context.Context, scheduleID string) error {
+	return c.do(ctx, "DELETE", schedulePath(scheduleID), nil, nil)
+}
+
+// Sends a request with the JSON encoded body (if not nil) and decodes the
+// JSON response into out (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(c.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return newError(res)
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("Error decoding Dray response: %s", err)
+	}
+
+	return nil
+}
+
+// Returns the prefix which addresses the client's namespace, if one is set.
+func (c *Client) namespacePath() string {
+	if len(c.Namespace) > 0 {
+		return "/namespaces/" + url.QueryEscape(c.Namespace)
+	}
+
+	return ""
+}
+
+func (c *Client) jobsPath() string {
+	return c.namespacePath() + "/jobs"
+}
+
+func (c *Client) jobPath(jobID string) string {
+	return c.jobsPath() + "/" + url.QueryEscape(jobID)
+}
+
+func templatePath(name string) string {
+	return "/templates/" + url.QueryEscape(name)
+}
+
+func schedulePath(scheduleID string) string {
+	return "/schedules/" + url.QueryEscape(scheduleID)
+}
@@ -0,0 +1,364 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CenturyLinkLabs/dray/job"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	mux    *http.ServeMux
+	server *httptest.Server
+	client *Client
+	ctx    context.Context
+}
+
+func (suite *ClientTestSuite) SetupTest() {
+	suite.mux = http.NewServeMux()
+	suite.server = httptest.NewServer(suite.mux)
+	suite.client = New(suite.server.URL + "/")
+	suite.client.PollInterval = 1
+	suite.ctx = context.Background()
+}
+
+func (suite *ClientTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *ClientTestSuite) handle(method, path string, status int, body string) {
+	suite.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal(method, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (suite *ClientTestSuite) TestNew() {
+	c := New("http://localhost:3000/")
+
+	suite.Equal("http://localhost:3000", c.BaseURL)
+	suite.Equal(http.DefaultClient, c.HTTPClient)
+	suite.Equal(defaultPollInterval, c.PollInterval)
+}
+
+func (suite *ClientTestSuite) TestListJobs() {
+	suite.handle("GET", "/jobs", http.StatusOK, `[{"id":"123"},{"id":"456"}]`)
+
+	jobs, err := suite.client.ListJobs(suite.ctx)
+
+	suite.NoError(err)
+	suite.Equal([]job.Job{{ID: "123"}, {ID: "456"}}, jobs)
+}
+
+func (suite *ClientTestSuite) TestListJobsNamespace() {
+	suite.handle("GET", "/namespaces/team-a/jobs", http.StatusOK, `[{"id":"123"}]`)
+	suite.client.Namespace = "team-a"
+
+	jobs, err := suite.client.ListJobs(suite.ctx)
+
+	suite.NoError(err)
+	suite.Equal([]job.Job{{ID: "123"}}, jobs)
+}
+
+func (suite *ClientTestSuite) TestCreateJob() {
+	var received job.Job
+	suite.mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("POST", r.Method)
+		suite.Equal("application/json", r.Header.Get("Content-Type"))
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"123","name":"foo","steps":[{"source":"bar"}]}`)
+	})
+
+	j := &job.Job{Name: "foo", Steps: []job.JobStep{{Source: "bar"}}}
+	created, err := suite.client.CreateJob(suite.ctx, j)
+
+	suite.NoError(err)
+	suite.Equal(*j, received)
+	suite.Equal("123", created.ID)
+	suite.Equal("foo", created.Name)
+}
+
+func (suite *ClientTestSuite) TestCreateJobValidationError() {
+	suite.handle("POST", "/jobs", http.StatusBadRequest,
+		`{"errors":[{"field":"steps","message":"required"}]}`)
+
+	_, err := suite.client.CreateJob(suite.ctx, &job.Job{})
+
+	suite.IsType(&ValidationError{}, err)
+	suite.Equal(http.StatusBadRequest, err.(*ValidationError).StatusCode)
+	suite.Equal([]job.FieldError{{Field: "steps", Message: "required"}}, err.(*ValidationError).Errors)
+	suite.EqualError(err, "Dray API error (400): steps: required")
+}
+
+func (suite *ClientTestSuite) TestGetJob() {
+	suite.handle("GET", "/jobs/123", http.StatusOK, `{"id":"123","status":"running","stepsCompleted":1}`)
+
+	j, err := suite.client.GetJob(suite.ctx, "123")
+
+	suite.NoError(err)
+	suite.Equal(&job.Job{ID: "123", Status: "running", StepsCompleted: 1}, j)
+}
+
+func (suite *ClientTestSuite) TestGetJobNotFound() {
+	suite.handle("GET", "/jobs/123", http.StatusNotFound,
+		`{"errors":[{"message":"Cannot find job with ID 123"}]}`)
+
+	_, err := suite.client.GetJob(suite.ctx, "123")
+
+	suite.IsType(&NotFoundError{}, err)
+	suite.EqualError(err, "Dray API error (404): Cannot find job with ID 123")
+}
+
+func (suite *ClientTestSuite) TestGetJobServerError() {
+	suite.mux.HandleFunc("/jobs/123", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := suite.client.GetJob(suite.ctx, "123")
+
+	suite.IsType(&ServerError{}, err)
+	suite.EqualError(err, "Dray API error (500): Internal Server Error")
+}
+
+func (suite *ClientTestSuite) TestGetJobToken() {
+	var authorization string
+	suite.mux.HandleFunc("/jobs/123", func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"id":"123"}`))
+	})
+	suite.client.Token = "s3cr3t"
+
+	_, err := suite.client.GetJob(suite.ctx, "123")
+
+	suite.NoError(err)
+	suite.Equal("Bearer s3cr3t", authorization)
+}
+
+func (suite *ClientTestSuite) TestGetJobUnauthorized() {
+	suite.handle("GET", "/jobs/123", http.StatusUnauthorized,
+		`{"errors":[{"message":"authentication required"}]}`)
+
+	_, err := suite.client.GetJob(suite.ctx, "123")
+
+	suite.IsType(&AuthError{}, err)
+	suite.EqualError(err, "Dray API error (401): authentication required")
+}
+
+func (suite *ClientTestSuite) TestGetJobBadResponse() {
+	suite.handle("GET", "/jobs/123", http.StatusOK, `{`)
+
+	_, err := suite.client.GetJob(suite.ctx, "123")
+
+	suite.EqualError(err, "Error decoding Dray response: unexpected EOF")
+}
+
+func (suite *ClientTestSuite) TestGetJobCancelled() {
+	ctx, cancel := context.WithCancel(suite.ctx)
+	cancel()
+
+	_, err := suite.client.GetJob(ctx, "123")
+
+	suite.True(errors.Is(err, context.Canceled))
+}
+
+func (suite *ClientTestSuite) TestGetJobLog() {
+	suite.mux.HandleFunc("/jobs/123/log", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("2", r.URL.Query().Get("index"))
+		suite.Equal("entries", r.URL.Query().Get("format"))
+		suite.Equal("", r.URL.Query().Get("step"))
+		suite.Equal("", r.URL.Query().Get("stream"))
+		fmt.Fprint(w, `{"index":4,"entries":[{"step":1,"stream":"stdout","text":"foo"},{"step":1,"stream":"stderr","text":"bar"}]}`)
+	})
+
+	jl, err := suite.client.GetJobLog(suite.ctx, "123", job.LogQuery{Index: 2})
+
+	suite.NoError(err)
+	suite.Equal(&job.JobLog{Index: 4, Entries: []job.LogEntry{
+		{Step: 1, Stream: "stdout", Text: "foo"},
+		{Step: 1, Stream: "stderr", Text: "bar"},
+	}}, jl)
+}
+
+func (suite *ClientTestSuite) TestGetJobLogFiltered() {
+	suite.mux.HandleFunc("/jobs/123/log", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("2", r.URL.Query().Get("step"))
+		suite.Equal("stderr", r.URL.Query().Get("stream"))
+		fmt.Fprint(w, `{"index":7,"entries":[]}`)
+	})
+
+	jl, err := suite.client.GetJobLog(suite.ctx, "123", job.LogQuery{Step: 2, Stream: "stderr"})
+
+	suite.NoError(err)
+	suite.Equal(&job.JobLog{Index: 7, Entries: []job.LogEntry{}}, jl)
+}
+
+func (suite *ClientTestSuite) TestGetServiceLog() {
+	suite.mux.HandleFunc("/jobs/123/services/log", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("0", r.URL.Query().Get("index"))
+		fmt.Fprint(w, `{"index":1,"entries":[{"stream":"service","text":"db: ready"}]}`)
+	})
+
+	jl, err := suite.client.GetServiceLog(suite.ctx, "123", job.LogQuery{})
+
+	suite.NoError(err)
+	suite.Equal(&job.JobLog{Index: 1, Entries: []job.LogEntry{{Stream: "service", Text: "db: ready"}}}, jl)
+}
+
+func (suite *ClientTestSuite) TestDeleteJob() {
+	suite.handle("DELETE", "/jobs/123", http.StatusNoContent, "")
+
+	err := suite.client.DeleteJob(suite.ctx, "123")
+
+	suite.NoError(err)
+}
+
+func (suite *ClientTestSuite) TestRerunJob() {
+	suite.handle("POST", "/jobs/123/rerun", http.StatusCreated, `{"id":"456","parentId":"123"}`)
+
+	j, err := suite.client.RerunJob(suite.ctx, "123")
+
+	suite.NoError(err)
+	suite.Equal(&job.Job{ID: "456", ParentID: "123"}, j)
+}
+
+func (suite *ClientTestSuite) TestResumeJob() {
+	suite.handle("POST", "/jobs/123/resume", http.StatusCreated, `{"id":"456","parentId":"123"}`)
+
+	j, err := suite.client.ResumeJob(suite.ctx, "123")
+
+	suite.NoError(err)
+	suite.Equal(&job.Job{ID: "456", ParentID: "123"}, j)
+}
+
+func (suite *ClientTestSuite) TestTemplates() {
+	suite.handle("GET", "/templates", http.StatusOK, `[{"name":"foo","version":2}]`)
+	suite.handle("GET", "/templates/foo", http.StatusOK, `{"name":"foo","version":2}`)
+
+	templates, err := suite.client.ListTemplates(suite.ctx)
+	suite.NoError(err)
+	suite.Equal([]job.JobTemplate{{Name: "foo", Version: 2}}, templates)
+
+	t, err := suite.client.GetTemplate(suite.ctx, "foo")
+	suite.NoError(err)
+	suite.Equal(&job.JobTemplate{Name: "foo", Version: 2}, t)
+}
+
+func (suite *ClientTestSuite) TestCreateTemplateJob() {
+	suite.mux.HandleFunc("/templates/foo/jobs", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		suite.Equal(`{"parameters":{"count":"3"}}`, string(body))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"123","template":"foo","templateVersion":2}`)
+	})
+
+	j, err := suite.client.CreateTemplateJob(suite.ctx, "foo", map[string]string{"count": "3"})
+
+	suite.NoError(err)
+	suite.Equal(&job.Job{ID: "123", Template: "foo", TemplateVersion: 2}, j)
+}
+
+func (suite *ClientTestSuite) TestCreateTemplateJobNamespace() {
+	suite.handle("POST", "/namespaces/team-a/templates/foo/jobs", http.StatusCreated, `{"id":"123","namespace":"team-a"}`)
+	suite.client.Namespace = "team-a"
+
+	j, err := suite.client.CreateTemplateJob(suite.ctx, "foo", nil)
+
+	suite.NoError(err)
+	suite.Equal(&job.Job{ID: "123", Namespace: "team-a"}, j)
+}
+
+func (suite *ClientTestSuite) TestUpdateSchedule() {
+	suite.mux.HandleFunc("/schedules/123", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("PUT", r.Method)
+		fmt.Fprint(w, `{"id":"123","cron":"@daily","template":"foo"}`)
+	})
+
+	s, err := suite.client.UpdateSchedule(suite.ctx, &job.Schedule{ID: "123", Cron: "@daily", Template: "foo"})
+
+	suite.NoError(err)
+	suite.Equal(&job.Schedule{ID: "123", Cron: "@daily", Template: "foo"}, s)
+}
+
+func (suite *ClientTestSuite) TestFollowLog() {
+	polls := 0
+	suite.mux.HandleFunc("/jobs/123", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 2 {
+			fmt.Fprint(w, `{"id":"123","status":"running"}`)
+		} else {
+			fmt.Fprint(w, `{"id":"123","status":"complete"}`)
+		}
+	})
+	suite.mux.HandleFunc("/jobs/123/log", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("stdout", r.URL.Query().Get("stream"))
+
+		if r.URL.Query().Get("index") == "0" {
+			fmt.Fprint(w, `{"index":3,"entries":[{"text":"foo"},{"text":"bar"}]}`)
+		} else {
+			suite.Equal("3", r.URL.Query().Get("index"))
+			fmt.Fprint(w, `{"index":4,"entries":[{"text":"baz"}]}`)
+		}
+	})
+
+	lines := []string{}
+	j, err := suite.client.FollowLog(suite.ctx, "123", job.LogQuery{Stream: "stdout"}, func(e job.LogEntry) error {
+		lines = append(lines, e.Text)
+		return nil
+	})
+
+	suite.NoError(err)
+	suite.Equal("complete", j.Status)
+	suite.Equal([]string{"foo", "bar", "baz"}, lines)
+}
+
+func (suite *ClientTestSuite) TestFollowLogCallbackError() {
+	suite.handle("GET", "/jobs/123", http.StatusOK, `{"id":"123","status":"running"}`)
+	suite.handle("GET", "/jobs/123/log", http.StatusOK, `{"index":1,"entries":[{"text":"foo"}]}`)
+
+	_, err := suite.client.FollowLog(suite.ctx, "123", job.LogQuery{}, func(e job.LogEntry) error {
+		return errors.New("oops")
+	})
+
+	suite.EqualError(err, "oops")
+}
+
+func (suite *ClientTestSuite) TestWait() {
+	polls := 0
+	suite.mux.HandleFunc("/jobs/123", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 3 {
+			fmt.Fprint(w, `{"id":"123","status":"running"}`)
+		} else {
+			fmt.Fprint(w, `{"id":"123","status":"error"}`)
+		}
+	})
+
+	j, err := suite.client.Wait(suite.ctx, "123")
+
+	suite.NoError(err)
+	suite.Equal("error", j.Status)
+	suite.Equal(3, polls)
+}
+
+func (suite *ClientTestSuite) TestFinished() {
+	suite.False(Finished(&job.Job{Status: ""}))
+	suite.False(Finished(&job.Job{Status: "running"}))
+	suite.True(Finished(&job.Job{Status: "complete"}))
+	suite.True(Finished(&job.Job{Status: "error"}))
+	suite.True(Finished(&job.Job{Status: "cancelled"}))
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+// APIError describes an error response returned by the Dray API. The Errors
+// field contains the problems reported in the response body.
+type APIError struct {
+	StatusCode int
+	Errors     []job.FieldError
+}
+
+// Error returns the error string for the APIError
+func (e APIError) Error() string {
+	msgs := make([]string, len(e.Errors))
+
+	for i, fe := range e.Errors {
+		msgs[i] = fe.String()
+	}
+
+	if len(msgs) == 0 {
+		msgs = append(msgs, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("Dray API error (%d): %s", e.StatusCode, strings.Join(msgs, ", "))
+}
+
+// NotFoundError is returned when the referenced job, template or schedule
+// does not exist (HTTP 404).
+type NotFoundError struct {
+	APIError
+}
+
+// ValidationError is returned when the submitted request is invalid (HTTP
+// 400). The Errors field identifies each of the offending fields.
+type ValidationError struct {
+	APIError
+}
+
+// AuthError is returned when the request lacks a valid API token (HTTP 401)
+// or is not permitted (HTTP 403), either because the token has not been
+// granted the scope the request requires or because the job breaks the
+// server's security policy. Policy violations identify the offending fields.
+type AuthError struct {
+	APIError
+}
+
+// ServerError is returned for any other error response, typically an HTTP 500
+// indicating that Dray was unable to reach Redis or Docker.
+type ServerError struct {
+	APIError
+}
+
+func newError(res *http.Response) error {
+	body := struct {
+		Errors []job.FieldError `json:"errors"`
+	}{}
+
+	// The body is informational only, so decoding errors are ignored
+	json.NewDecoder(res.Body).Decode(&body)
+	apiErr := APIError{StatusCode: res.StatusCode, Errors: body.Errors}
+
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return &NotFoundError{apiErr}
+	case http.StatusBadRequest:
+		return &ValidationError{apiErr}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &AuthError{apiErr}
+	default:
+		return &ServerError{apiErr}
+	}
+}
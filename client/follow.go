@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+// FollowLog calls fn for every log entry produced by the specified job which
+// is selected by the query, until the job finishes executing. The log is
+// polled at the client's PollInterval and the query's index is advanced
+// after every request so that each entry is delivered exactly once.
+//
+// The final state of the job is returned once all of its log entries have
+// been delivered. Following stops early if the context is cancelled or if fn
+// returns an error.
+func (c *Client) FollowLog(ctx context.Context, jobID string, q job.LogQuery, fn func(e job.LogEntry) error) (*job.Job, error) {
+	for {
+		// The state must be checked before reading the log so that any entries
+		// written just before the job finished are not missed.
+		j, err := c.GetJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		jl, err := c.GetJobLog(ctx, jobID, q)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range jl.Entries {
+			if err := fn(e); err != nil {
+				return nil, err
+			}
+		}
+		q.Index = jl.Index
+
+		if Finished(j) {
+			return j, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+// Wait polls the specified job at the client's PollInterval until it finishes
+// executing and returns its final state.
+func (c *Client) Wait(ctx context.Context, jobID string) (*job.Job, error) {
+	for {
+		j, err := c.GetJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		if Finished(j) {
+			return j, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+func (c *Client) pollInterval() time.Duration {
+	if c.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+
+	return c.PollInterval
+}
+
+// Finished returns true if the job has stopped executing, whether or not it
+// completed successfully.
+func Finished(j *job.Job) bool {
+	switch j.Status {
+	case "", "running":
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/client"
+	"github.com/CenturyLinkLabs/dray/job"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultHost = "http://localhost:3000"
+
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// Exit codes returned by the dray command. The wait command exits with
+// exitJobFailed when the job finishes with any status other than "complete".
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitJobFailed = 3
+)
+
+const usage = `Usage: dray [-H host] [-t token] [-n namespace] [-o table|json] <command> [arguments]
+
+Commands:
+  submit <file>             submit a job described by a JSON or YAML file ("-" for stdin)
+  ls [-status <statuses>]   list jobs, optionally filtered by a comma-separated list of statuses
+  status <id>               show the state of a job
+  logs [-f] [-step <n>] [-stream <stream>] <id>
+                            print the log of a job, optionally limited to one step or stream,
+                            following it until the job finishes with -f
+  wait <id>                 wait for a job to finish, exiting non-zero unless it completed
+  rm <id>                   delete a job
+`
+
+var errUsage = errors.New("invalid arguments")
+
+// The time to wait between requests when following a log or waiting for a
+// job to finish.
+var pollInterval = time.Second
+
+type cli struct {
+	client *client.Client
+	format string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command func(c *cli, ctx context.Context, args []string) (int, error)
+
+var commands = map[string]command{
+	"submit": submit,
+	"ls":     list,
+	"status": status,
+	"logs":   logs,
+	"wait":   wait,
+	"rm":     remove,
+}
+
+// Parses the global flags, dispatches to the named command and returns the
+// process exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	host := os.Getenv("DRAY_HOST")
+	if len(host) == 0 {
+		host = defaultHost
+	}
+
+	flags := newFlagSet("dray", stderr)
+	flags.StringVar(&host, "H", host, "Dray API host")
+	token := flags.String("t", os.Getenv("DRAY_TOKEN"), "Dray API token")
+	namespace := flags.String("n", os.Getenv("DRAY_NAMESPACE"), "namespace of the jobs")
+	format := flags.String("o", formatTable, "output format (table or json)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *format != formatTable && *format != formatJSON {
+		fmt.Fprintf(stderr, "Unknown output format %q\n\n%s", *format, usage)
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", flags.Arg(0), usage)
+		return exitUsage
+	}
+
+	dc := client.New(host)
+	dc.PollInterval = pollInterval
+	dc.Token = *token
+	dc.Namespace = *namespace
+
+	c := &cli{
+		client: dc,
+		format: *format,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	code, err := cmd(c, ctx, flags.Args()[1:])
+	if err == errUsage {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return code
+}
+
+func submit(c *cli, ctx context.Context, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	j, err := c.readJob(args[0])
+	if err != nil {
+		return exitError, err
+	}
+
+	created, err := c.client.CreateJob(ctx, j)
+	if err != nil {
+		return exitError, err
+	}
+
+	return exitOK, c.printJob(created)
+}
+
+func list(c *cli, ctx context.Context, args []string) (int, error) {
+	flags := newFlagSet("ls", c.stderr)
+	statuses := flags.String("status", "", "comma-separated list of statuses to show")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return exitUsage, errUsage
+	}
+
+	ids, err := c.client.ListJobs(ctx)
+	if err != nil {
+		return exitError, err
+	}
+
+	jobs := []job.Job{}
+	for _, id := range ids {
+		j, err := c.client.GetJob(ctx, id.ID)
+		if _, ok := err.(*client.NotFoundError); ok {
+			// Deleted after the list was retrieved
+			continue
+		} else if err != nil {
+			return exitError, err
+		}
+
+		if matchesStatus(j, *statuses) {
+			jobs = append(jobs, *j)
+		}
+	}
+
+	return exitOK, c.printJobs(jobs)
+}
+
+func status(c *cli, ctx context.Context, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	j, err := c.client.GetJob(ctx, args[0])
+	if err != nil {
+		return exitError, err
+	}
+
+	return exitOK, c.printJob(j)
+}
+
+func logs(c *cli, ctx context.Context, args []string) (int, error) {
+	flags := newFlagSet("logs", c.stderr)
+	follow := flags.Bool("f", false, "follow the log until the job finishes")
+	step := flags.Int("step", 0, "only print the log of the numbered step")
+	stream := flags.String("stream", "", "only print the stdout, stderr, system or service stream")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return exitUsage, errUsage
+	}
+
+	jobID := flags.Arg(0)
+	q := job.LogQuery{Step: *step, Stream: *stream}
+	printEntry := func(e job.LogEntry) error {
+		_, err := fmt.Fprintln(c.stdout, e)
+		return err
+	}
+
+	if *follow {
+		_, err := c.client.FollowLog(ctx, jobID, q, printEntry)
+		return exitOK, err
+	}
+
+	jl, err := c.client.GetJobLog(ctx, jobID, q)
+	if err != nil {
+		return exitError, err
+	}
+
+	for _, e := range jl.Entries {
+		if err := printEntry(e); err != nil {
+			return exitError, err
+		}
+	}
+
+	return exitOK, nil
+}
+
+func wait(c *cli, ctx context.Context, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	j, err := c.client.Wait(ctx, args[0])
+	if err != nil {
+		return exitError, err
+	}
+
+	if err := c.printJob(j); err != nil {
+		return exitError, err
+	}
+
+	if j.Status != "complete" {
+		return exitJobFailed, nil
+	}
+
+	return exitOK, nil
+}
+
+func remove(c *cli, ctx context.Context, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	return exitOK, c.client.DeleteJob(ctx, args[0])
+}
+
+// Reads a job description from the named file (or stdin if the name is "-").
+// Files with a .json extension are decoded as JSON, anything else as YAML.
+func (c *cli) readJob(name string) (*job.Job, error) {
+	var data []byte
+	var err error
+
+	if name == "-" {
+		data, err = ioutil.ReadAll(c.stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	j := &job.Job{}
+	if strings.ToLower(filepath.Ext(name)) == ".json" {
+		err = json.Unmarshal(data, j)
+	} else {
+		err = yaml.Unmarshal(data, j)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error reading job from %s: %s", name, err)
+	}
+
+	return j, nil
+}
+
+func matchesStatus(j *job.Job, statuses string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+
+	for _, s := range strings.Split(statuses, ",") {
+		if strings.TrimSpace(s) == displayStatus(*j) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newFlagSet(name string, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	return flags
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CenturyLinkLabs/dray/job"
+	"github.com/stretchr/testify/suite"
+)
+
+type CommandsTestSuite struct {
+	suite.Suite
+	mux    *http.ServeMux
+	server *httptest.Server
+	stdin  *bytes.Buffer
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+func (suite *CommandsTestSuite) SetupTest() {
+	pollInterval = 1
+	suite.mux = http.NewServeMux()
+	suite.server = httptest.NewServer(suite.mux)
+	suite.stdin = &bytes.Buffer{}
+	suite.stdout = &bytes.Buffer{}
+	suite.stderr = &bytes.Buffer{}
+}
+
+func (suite *CommandsTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *CommandsTestSuite) run(args ...string) int {
+	args = append([]string{"-H", suite.server.URL}, args...)
+	return run(context.Background(), args, suite.stdin, suite.stdout, suite.stderr)
+}
+
+func (suite *CommandsTestSuite) handle(path, body string) {
+	suite.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})
+}
+
+func TestCommandsTestSuite(t *testing.T) {
+	suite.Run(t, new(CommandsTestSuite))
+}
+
+func (suite *CommandsTestSuite) TestNoCommand() {
+	code := suite.run()
+
+	suite.Equal(exitUsage, code)
+	suite.Contains(suite.stderr.String(), "Usage: dray")
+}
+
+func (suite *CommandsTestSuite) TestUnknownCommand() {
+	code := suite.run("foo")
+
+	suite.Equal(exitUsage, code)
+	suite.Contains(suite.stderr.String(), "Unknown command \"foo\"")
+}
+
+func (suite *CommandsTestSuite) TestUnknownFormat() {
+	code := suite.run("-o", "xml", "ls")
+
+	suite.Equal(exitUsage, code)
+	suite.Contains(suite.stderr.String(), "Unknown output format \"xml\"")
+}
+
+func (suite *CommandsTestSuite) TestMissingArgument() {
+	code := suite.run("status")
+
+	suite.Equal(exitUsage, code)
+	suite.Contains(suite.stderr.String(), "Usage: dray")
+}
+
+func (suite *CommandsTestSuite) TestSubmitYAML() {
+	dir, _ := ioutil.TempDir("", "dray")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "job.yaml")
+	ioutil.WriteFile(path, []byte("name: foo\nsteps:\n- source: bar\n  beginDelimiter: '---'\n"), 0644)
+
+	var received job.Job
+	suite.mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("POST", r.Method)
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"123","name":"foo","steps":[{"source":"bar"}]}`)
+	})
+
+	code := suite.run("submit", path)
+
+	suite.Equal(exitOK, code)
+	suite.Equal(job.Job{Name: "foo", Steps: []job.JobStep{{Source: "bar", BeginDelimiter: "---"}}}, received)
+	suite.Equal("ID   NAME  STATUS   STEPS\n123  foo   pending  0/1\n", suite.stdout.String())
+}
+
+func (suite *CommandsTestSuite) TestSubmitJSONStdin() {
+	suite.stdin.WriteString(`{"name":"foo","steps":[{"source":"bar"}]}`)
+	suite.handle("/jobs", `{"id":"123","name":"foo"}`)
+
+	code := suite.run("-o", "json", "submit", "-")
+
+	suite.Equal(exitOK, code)
+	suite.Equal("{\n  \"id\": \"123\",\n  \"name\": \"foo\"\n}\n", suite.stdout.String())
+}
+
+func (suite *CommandsTestSuite) TestSubmitBadFile() {
+	suite.stdin.WriteString("steps: foo")
+
+	code := suite.run("submit", "-")
+
+	suite.Equal(exitError, code)
+	suite.Contains(suite.stderr.String(), "Error reading job from -")
+}
+
+func (suite *CommandsTestSuite) TestSubmitInvalid() {
+	suite.stdin.WriteString("name: foo")
+	suite.mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errors":[{"field":"steps","message":"required"}]}`)
+	})
+
+	code := suite.run("submit", "-")
+
+	suite.Equal(exitError, code)
+	suite.Equal("Dray API error (400): steps: required\n", suite.stderr.String())
+}
+
+func (suite *CommandsTestSuite) TestList() {
+	suite.handle("/jobs", `[{"id":"1"},{"id":"2"},{"id":"3"}]`)
+	suite.handle("/jobs/1", `{"id":"1","name":"a","status":"running","steps":[{},{}],"stepsCompleted":1}`)
+	suite.handle("/jobs/2", `{"id":"2","name":"b","status":"complete","steps":[{}],"stepsCompleted":1}`)
+	suite.handle("/jobs/3", `{"id":"3","name":"c","status":"error","steps":[{}]}`)
+
+	code := suite.run("ls", "-status", "running,error")
+
+	suite.Equal(exitOK, code)
+	suite.Equal(strings.Join([]string{
+		"ID  NAME  STATUS   STEPS",
+		"1   a     running  1/2",
+		"3   c     error    0/1",
+		"",
+	}, "\n"), suite.stdout.String())
+}
+
+func (suite *CommandsTestSuite) TestListJSON() {
+	suite.handle("/jobs", `[{"id":"1"}]`)
+	suite.handle("/jobs/1", `{"id":"1","status":"running"}`)
+
+	code := suite.run("-o", "json", "ls")
+
+	suite.Equal(exitOK, code)
+
+	jobs := []job.Job{}
+	json.Unmarshal(suite.stdout.Bytes(), &jobs)
+	suite.Equal([]job.Job{{ID: "1", Status: "running"}}, jobs)
+}
+
+func (suite *CommandsTestSuite) TestStatusNotFound() {
+	suite.mux.HandleFunc("/jobs/1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[{"message":"Cannot find job with ID 1"}]}`)
+	})
+
+	code := suite.run("status", "1")
+
+	suite.Equal(exitError, code)
+	suite.Equal("Dray API error (404): Cannot find job with ID 1\n", suite.stderr.String())
+}
+
+func (suite *CommandsTestSuite) TestToken() {
+	var authorization string
+	suite.mux.HandleFunc("/jobs/1", func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"id":"1","status":"running"}`)
+	})
+
+	code := suite.run("-t", "s3cr3t", "status", "1")
+
+	suite.Equal(exitOK, code)
+	suite.Equal("Bearer s3cr3t", authorization)
+}
+
+func (suite *CommandsTestSuite) TestNamespace() {
+	suite.handle("/namespaces/team-a/jobs/1", `{"id":"1","status":"running"}`)
+
+	code := suite.run("-n", "team-a", "status", "1")
+
+	suite.Equal(exitOK, code)
+}
+
+func (suite *CommandsTestSuite) TestLogs() {
+	suite.handle("/jobs/1/log", `{"index":2,"entries":[{"stream":"system","text":"foo"},{"stream":"stdout","text":"bar"}]}`)
+
+	code := suite.run("logs", "1")
+
+	suite.Equal(exitOK, code)
+	suite.Equal("[system] foo\nbar\n", suite.stdout.String())
+}
+
+func (suite *CommandsTestSuite) TestLogsFiltered() {
+	suite.mux.HandleFunc("/jobs/1/log", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("2", r.URL.Query().Get("step"))
+		suite.Equal("stderr", r.URL.Query().Get("stream"))
+		fmt.Fprint(w, `{"index":5,"entries":[{"step":2,"stream":"stderr","text":"oops"}]}`)
+	})
+
+	code := suite.run("logs", "-step", "2", "-stream", "stderr", "1")
+
+	suite.Equal(exitOK, code)
+	suite.Equal("oops\n", suite.stdout.String())
+}
+
+func (suite *CommandsTestSuite) TestLogsFollow() {
+	polls := 0
+	suite.mux.HandleFunc("/jobs/1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 2 {
+			fmt.Fprint(w, `{"id":"1","status":"running"}`)
+		} else {
+			fmt.Fprint(w, `{"id":"1","status":"complete"}`)
+		}
+	})
+	suite.mux.HandleFunc("/jobs/1/log", func(w http.ResponseWriter, r *http.Request) {
+		index, _ := strconv.Atoi(r.URL.Query().Get("index"))
+		fmt.Fprintf(w, `{"index":%d,"entries":[{"text":"line %d"}]}`, index+1, index)
+	})
+
+	code := suite.run("logs", "-f", "1")
+
+	suite.Equal(exitOK, code)
+	suite.Equal("line 0\nline 1\n", suite.stdout.String())
+}
+
+func (suite *CommandsTestSuite) TestWaitComplete() {
+	suite.handle("/jobs/1", `{"id":"1","status":"complete"}`)
+
+	code := suite.run("wait", "1")
+
+	suite.Equal(exitOK, code)
+}
+
+func (suite *CommandsTestSuite) TestWaitFailed() {
+	suite.handle("/jobs/1", `{"id":"1","status":"error"}`)
+
+	code := suite.run("wait", "1")
+
+	suite.Equal(exitJobFailed, code)
+	suite.Contains(suite.stdout.String(), "error")
+}
+
+func (suite *CommandsTestSuite) TestRemove() {
+	suite.mux.HandleFunc("/jobs/1", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("DELETE", r.Method)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	code := suite.run("rm", "1")
+
+	suite.Equal(exitOK, code)
+	suite.Empty(suite.stdout.String())
+}
@@ -0,0 +1,24 @@
+// The dray command is a command-line client for the Dray API.
+//
+//	dray [-H host] [-t token] [-n namespace] [-o table|json] <command> [arguments]
+//
+// The API host defaults to the value of the DRAY_HOST environment variable or
+// http://localhost:3000 if it is not set. The API token and namespace default
+// to the values of the DRAY_TOKEN and DRAY_NAMESPACE environment variables.
+package main // import "github.com/CenturyLinkLabs/dray/cmd/dray"
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+
+	stop()
+	os.Exit(code)
+}
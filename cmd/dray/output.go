@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+// Writes the job to stdout in the selected output format.
+func (c *cli) printJob(j *job.Job) error {
+	if c.format == formatJSON {
+		return c.printJSON(j)
+	}
+
+	return c.printJobs([]job.Job{*j})
+}
+
+// Writes the list of jobs to stdout in the selected output format.
+func (c *cli) printJobs(jobs []job.Job) error {
+	if c.format == formatJSON {
+		return c.printJSON(jobs)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tSTEPS")
+
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\n", j.ID, j.Name, displayStatus(j), j.StepsCompleted, len(j.Steps))
+	}
+
+	return w.Flush()
+}
+
+// Jobs which have been created but have not yet started executing have no
+// status.
+func displayStatus(j job.Job) string {
+	if len(j.Status) == 0 {
+		return "pending"
+	}
+
+	return j.Status
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.stdout, "%s\n", b)
+	return err
+}
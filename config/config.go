@@ -0,0 +1,512 @@
+// Package config gathers the settings of the Dray server from a YAML file, the
+// environment and the command line.
+package config // import "github.com/CenturyLinkLabs/dray/config"
+
+import (
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/api"
+	"github.com/CenturyLinkLabs/dray/auth"
+	"github.com/CenturyLinkLabs/dray/job"
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/units"
+	"gopkg.in/yaml.v2"
+)
+
+// redacted replaces secrets when a Config is printed.
+const redacted = "********"
+
+// Config holds every setting of the Dray server. The YAML keys of the
+// configuration file match the field names (e.g. "logLevel").
+type Config struct {
+	Listen              string       `yaml:"listen"`
+	LogLevel            string       `yaml:"logLevel"`
+	ShutdownGracePeriod string       `yaml:"shutdownGracePeriod"`
+	Store               StoreConfig  `yaml:"store"`
+	Docker              DockerConfig `yaml:"docker"`
+	TLS                 TLSConfig    `yaml:"tls"`
+	Auth                AuthConfig   `yaml:"auth"`
+	Jobs                JobsConfig   `yaml:"jobs"`
+
+	// PrintConfig is set by the -print-config flag, which asks for the
+	// configuration to be printed instead of starting the server.
+	PrintConfig bool `yaml:"-"`
+
+	// home is the user's home directory, in which the Docker certificates
+	// are found by default.
+	home string
+}
+
+// StoreConfig describes the backend in which jobs are stored. Redis is the
+// only backend. URL is the address of the Redis server in any of the forms
+// accepted by job.ParseRedisURL, and a password or database given in the URL
+// overrides Password and DB. TLSCAFile names the PEM file of the CAs which
+// sign the certificates of Redis servers reached over TLS, which defaults to
+// the system's CAs.
+type StoreConfig struct {
+	Backend   string `yaml:"backend"`
+	URL       string `yaml:"url"`
+	Password  string `yaml:"password"`
+	DB        int    `yaml:"db"`
+	PoolSize  int    `yaml:"poolSize"`
+	TLSCAFile string `yaml:"tlsCAFile"`
+}
+
+// DockerConfig describes the connection to the Docker daemon which runs the
+// jobs' containers. TLS is used if TLSVerify or CertPath is set.
+type DockerConfig struct {
+	Host      string `yaml:"host"`
+	TLSVerify bool   `yaml:"tlsVerify"`
+	CertPath  string `yaml:"certPath"`
+}
+
+// TLSConfig names the files used to serve the API over HTTPS.
+type TLSConfig struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// AuthConfig holds the settings for API authentication.
+type AuthConfig struct {
+	TokensFile string `yaml:"tokensFile"`
+	JWTSecret  string `yaml:"jwtSecret"`
+	JWTIssuer  string `yaml:"jwtIssuer"`
+}
+
+// JobsConfig holds the limits and defaults applied to jobs. Workers is the
+// number of running jobs at which the server reports that it is not ready
+// (set by MAX_RUNNING_JOBS); it does not limit the jobs which may run.
+type JobsConfig struct {
+	Workers          int            `yaml:"workers"`
+	StopTimeout      string         `yaml:"stopTimeout"`
+	WorkspacePath    string         `yaml:"workspacePath"`
+	RegistryAuthFile string         `yaml:"registryAuthFile"`
+	Defaults         LimitsConfig   `yaml:"defaults"`
+	Maximums         LimitsConfig   `yaml:"maximums"`
+	AllowedVolumes   []string       `yaml:"allowedVolumes"`
+	AllowedNetworks  []string       `yaml:"allowedNetworks"`
+	NamespaceQuota   int            `yaml:"namespaceQuota"`
+	NamespaceQuotas  map[string]int `yaml:"namespaceQuotas"`
+	Policy           PolicyConfig   `yaml:"policy"`
+}
+
+// LimitsConfig holds a set of resource limits for job steps. Memory sizes
+// may use a unit suffix (e.g. "512m") or be -1 for unlimited, and Ulimits
+// uses the Docker CLI format (e.g. "nofile=1024:2048").
+type LimitsConfig struct {
+	Memory     string `yaml:"memory"`
+	MemorySwap string `yaml:"memorySwap"`
+	CPUShares  int64  `yaml:"cpuShares"`
+	CPUQuota   int64  `yaml:"cpuQuota"`
+	PidsLimit  int64  `yaml:"pidsLimit"`
+	Ulimits    string `yaml:"ulimits"`
+}
+
+// PolicyConfig holds the security policy applied to every job.
+type PolicyConfig struct {
+	AllowedRegistries []string `yaml:"allowedRegistries"`
+	AllowedImages     []string `yaml:"allowedImages"`
+	Forbidden         []string `yaml:"forbidden"`
+	RequiredResources []string `yaml:"requiredResources"`
+	MaxSteps          int      `yaml:"maxSteps"`
+}
+
+// Default returns the configuration used when no setting is overridden.
+func Default() *Config {
+	return &Config{
+		Listen:              ":3000",
+		LogLevel:            "info",
+		ShutdownGracePeriod: "30s",
+		Store: StoreConfig{
+			Backend:  "redis",
+			URL:      "localhost:6379",
+			PoolSize: 4,
+		},
+		Docker: DockerConfig{Host: "unix:///var/run/docker.sock"},
+		Jobs:   JobsConfig{StopTimeout: "10s"},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file named by the
+// -config flag (or the CONFIG_FILE environment variable), the environment and
+// the remaining command-line flags, each overriding the settings before it.
+// The result is validated before it is returned. An unknown log level is
+// replaced by "info" with a warning, rather than preventing the server from
+// starting.
+func Load(name string, args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	// The flags are parsed once to find the file, and again once the file
+	// and environment have been applied so that they take precedence
+	path := getenv("CONFIG_FILE")
+	fs := c.flagSet(name, &path)
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(args); err != nil && err != flag.ErrHelp {
+		return nil, err
+	}
+
+	c = Default()
+
+	if len(path) > 0 {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	if err := c.flagSet(name, &path).Parse(args); err != nil {
+		return nil, err
+	}
+
+	if _, err := log.ParseLevel(strings.ToLower(c.LogLevel)); err != nil {
+		log.Warnf("Invalid log level %q, using %q", c.LogLevel, Default().LogLevel)
+		c.LogLevel = Default().LogLevel
+	}
+
+	c.home = getenv("HOME")
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return fmt.Errorf("Invalid config file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	for _, s := range c.settings() {
+		if len(s.env) == 0 {
+			continue
+		}
+
+		if v := getenv(s.env); len(v) > 0 {
+			if err := s.value.Set(v); err != nil {
+				return fmt.Errorf("Invalid %s: %s", s.env, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) flagSet(name string, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "path to a YAML configuration file")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the configuration and exit")
+
+	for _, s := range c.settings() {
+		if len(s.flag) > 0 {
+			fs.Var(s.value, s.flag, s.usage)
+		}
+	}
+
+	return fs
+}
+
+// Validate checks that every setting is well-formed.
+func (c *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("Invalid listen address %q", c.Listen)
+	}
+
+	if _, err := log.ParseLevel(strings.ToLower(c.LogLevel)); err != nil {
+		return fmt.Errorf("Invalid log level %q", c.LogLevel)
+	}
+
+	if _, err := parseDuration("shutdownGracePeriod", c.ShutdownGracePeriod); err != nil {
+		return err
+	}
+
+	if c.Store.Backend != "redis" {
+		return fmt.Errorf("Unsupported store backend %q, must be \"redis\"", c.Store.Backend)
+	}
+
+	if _, err := job.ParseRedisURL(c.Store.URL); err != nil {
+		return err
+	}
+
+	if c.Store.DB < 0 {
+		return fmt.Errorf("Invalid Redis database %d", c.Store.DB)
+	}
+
+	if c.Store.PoolSize < 1 {
+		return fmt.Errorf("Invalid Redis pool size %d, must be at least 1", c.Store.PoolSize)
+	}
+
+	if !c.ServerTLS().Enabled() && (len(c.TLS.CertFile) > 0 || len(c.TLS.KeyFile) > 0 || len(c.TLS.ClientCAFile) > 0) {
+		return errors.New("Invalid TLS configuration: the certificate and key files must both be set")
+	}
+
+	if c.Jobs.Workers < 0 {
+		return fmt.Errorf("Invalid number of workers %d", c.Jobs.Workers)
+	}
+
+	jc, err := c.jobConfig()
+	if err != nil {
+		return err
+	}
+
+	for ns := range c.Jobs.NamespaceQuotas {
+		if !job.ValidNamespace(ns) {
+			return fmt.Errorf("Invalid namespace %q in namespace quotas", ns)
+		}
+	}
+
+	if err := jc.Policy.Validate(); err != nil {
+		return fmt.Errorf("Invalid security policy: %s", err)
+	}
+
+	return nil
+}
+
+// Redis returns the settings for the connection to the Redis store, loading
+// the CA certificates if TLS is used.
+func (c *Config) Redis() (job.RedisConfig, error) {
+	rc, err := job.ParseRedisURL(c.Store.URL)
+	if err != nil {
+		return rc, err
+	}
+
+	if len(rc.Password) == 0 {
+		rc.Password = c.Store.Password
+	}
+
+	if rc.DB == 0 {
+		rc.DB = c.Store.DB
+	}
+
+	rc.PoolSize = c.Store.PoolSize
+
+	if rc.TLS != nil && len(c.Store.TLSCAFile) > 0 {
+		pem, err := ioutil.ReadFile(c.Store.TLSCAFile)
+		if err != nil {
+			return rc, fmt.Errorf("Invalid Redis TLS CA file: %s", err)
+		}
+
+		rc.TLS.RootCAs = x509.NewCertPool()
+		if !rc.TLS.RootCAs.AppendCertsFromPEM(pem) {
+			return rc, fmt.Errorf("Invalid Redis TLS CA file: no certificates found in %s", c.Store.TLSCAFile)
+		}
+	}
+
+	return rc, nil
+}
+
+// Job returns the configuration of the job manager and executor.
+func (c *Config) Job() job.Config {
+	jc, _ := c.jobConfig()
+	return jc
+}
+
+func (c *Config) jobConfig() (job.Config, error) {
+	var err error
+
+	jc := job.Config{
+		Volumes:        job.VolumePolicy{Allowed: c.Jobs.AllowedVolumes},
+		Networks:       job.NetworkPolicy{Allowed: c.Jobs.AllowedNetworks},
+		WorkspacePath:  c.Jobs.WorkspacePath,
+		MaxRunningJobs: c.Jobs.Workers,
+		Quotas: job.NamespaceQuotas{
+			Default: c.Jobs.NamespaceQuota,
+			Limits:  c.Jobs.NamespaceQuotas,
+		},
+		Policy: job.SecurityPolicy{
+			AllowedRegistries: c.Jobs.Policy.AllowedRegistries,
+			AllowedImages:     c.Jobs.Policy.AllowedImages,
+			Forbidden:         c.Jobs.Policy.Forbidden,
+			RequiredResources: c.Jobs.Policy.RequiredResources,
+			MaxSteps:          c.Jobs.Policy.MaxSteps,
+		},
+		DockerTLS: c.dockerTLS(),
+	}
+
+	if jc.Resources.Defaults, err = c.Jobs.Defaults.resources("defaults"); err != nil {
+		return jc, err
+	}
+
+	if jc.Resources.Maximums, err = c.Jobs.Maximums.resources("maximums"); err != nil {
+		return jc, err
+	}
+
+	if jc.StopTimeout, err = parseDuration("stopTimeout", c.Jobs.StopTimeout); err != nil {
+		return jc, err
+	}
+
+	return jc, nil
+}
+
+// Returns the TLS settings for the Docker connection, with the certificates
+// in ~/.docker unless CertPath is set (as for the Docker CLI). The home
+// directory is taken from the HOME variable of the environment given to Load.
+func (c *Config) dockerTLS() job.DockerTLS {
+	t := job.DockerTLS{
+		Enabled:  c.Docker.TLSVerify || len(c.Docker.CertPath) > 0,
+		Verify:   c.Docker.TLSVerify,
+		CertPath: c.Docker.CertPath,
+	}
+
+	if t.Enabled && len(t.CertPath) == 0 {
+		t.CertPath = filepath.Join(c.home, ".docker")
+	}
+
+	return t
+}
+
+func (l LimitsConfig) resources(name string) (job.Resources, error) {
+	var err error
+
+	r := job.Resources{
+		CPUShares: l.CPUShares,
+		CPUQuota:  l.CPUQuota,
+		PidsLimit: l.PidsLimit,
+	}
+
+	if r.Memory, err = parseMemory(name+".memory", l.Memory); err != nil {
+		return r, err
+	}
+
+	if r.MemorySwap, err = parseMemory(name+".memorySwap", l.MemorySwap); err != nil {
+		return r, err
+	}
+
+	if len(l.Ulimits) > 0 {
+		if r.Ulimits, err = job.ParseUlimits(l.Ulimits); err != nil {
+			return r, fmt.Errorf("Invalid %s.ulimits: %s", name, err)
+		}
+	}
+
+	return r, nil
+}
+
+// ServerTLS returns the files used to serve the API over HTTPS.
+func (c *Config) ServerTLS() api.TLSConfig {
+	return api.TLSConfig{
+		CertFile:     c.TLS.CertFile,
+		KeyFile:      c.TLS.KeyFile,
+		ClientCAFile: c.TLS.ClientCAFile,
+	}
+}
+
+// Authenticator returns the authenticator for API requests, loading the
+// static tokens from the tokens file.
+func (c *Config) Authenticator() (*auth.Authenticator, error) {
+	a := &auth.Authenticator{
+		JWTSecret: []byte(c.Auth.JWTSecret),
+		Issuer:    c.Auth.JWTIssuer,
+	}
+
+	if len(c.Auth.TokensFile) > 0 {
+		tokens, err := auth.LoadTokens(c.Auth.TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tokens file: %s", err)
+		}
+		a.Tokens = tokens
+	}
+
+	return a, nil
+}
+
+// RegistryAuth returns the credentials for private registries, loaded from
+// the registry auth file.
+func (c *Config) RegistryAuth() (job.RegistryAuth, error) {
+	if len(c.Jobs.RegistryAuthFile) == 0 {
+		return job.RegistryAuth{}, nil
+	}
+
+	ra, err := job.LoadRegistryAuth(c.Jobs.RegistryAuthFile)
+	if err != nil {
+		return ra, fmt.Errorf("Invalid registry auth file: %s", err)
+	}
+
+	return ra, nil
+}
+
+// Level returns the log level.
+func (c *Config) Level() log.Level {
+	level, _ := log.ParseLevel(strings.ToLower(c.LogLevel))
+	return level
+}
+
+// GracePeriod returns the time to wait for running jobs to finish on
+// shutdown.
+func (c *Config) GracePeriod() time.Duration {
+	d, _ := parseDuration("shutdownGracePeriod", c.ShutdownGracePeriod)
+	return d
+}
+
+// YAML returns the configuration as a YAML document in the format of the
+// configuration file, with any secrets redacted.
+func (c *Config) YAML() ([]byte, error) {
+	printed := *c
+
+	if len(printed.Store.Password) > 0 {
+		printed.Store.Password = redacted
+	}
+
+	if u, err := url.Parse(printed.Store.URL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			userinfo := u.User.Username() + ":" + redacted + "@"
+			printed.Store.URL = strings.Replace(printed.Store.URL, u.User.String()+"@", userinfo, 1)
+		}
+	}
+
+	if len(printed.Auth.JWTSecret) > 0 {
+		printed.Auth.JWTSecret = redacted
+	}
+
+	return yaml.Marshal(&printed)
+}
+
+func parseDuration(name, s string) (time.Duration, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid %s %q", name, s)
+	}
+
+	return d, nil
+}
+
+// Parses a memory size such as "512m" (or -1 for unlimited).
+func parseMemory(name, s string) (int64, error) {
+	if len(s) == 0 {
+		return 0, nil
+	} else if s == "-1" {
+		return -1, nil
+	}
+
+	n, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %s", name, err)
+	}
+
+	return n, nil
+}
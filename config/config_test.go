@@ -0,0 +1,279 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/CenturyLinkLabs/dray/job"
+	log "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "dray-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.WriteString(content)
+	return f.Name()
+}
+
+func redis(t *testing.T, c *Config) job.RedisConfig {
+	rc, err := c.Redis()
+	assert.NoError(t, err)
+	return rc
+}
+
+func TestDefault(t *testing.T) {
+	c := Default()
+
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, ":3000", c.Listen)
+	assert.Equal(t, log.InfoLevel, c.Level())
+	assert.Equal(t, 30*time.Second, c.GracePeriod())
+	assert.Equal(t, job.RedisConfig{Address: "localhost:6379", PoolSize: 4}, redis(t, c))
+	assert.Equal(t, 10*time.Second, c.Job().StopTimeout)
+	assert.False(t, c.Job().DockerTLS.Enabled)
+	assert.False(t, c.ServerTLS().Enabled())
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfigFile(t, `
+listen: 127.0.0.1:4000
+logLevel: debug
+store:
+  url: tcp://redis:6379
+  password: secret
+  db: 2
+  poolSize: 8
+jobs:
+  workers: 5
+  stopTimeout: 1m
+  defaults:
+    memory: 512m
+    ulimits: nofile=1024:2048
+  allowedNetworks: [ci, host]
+  namespaceQuotas:
+    team-a: 10
+  policy:
+    forbidden: [root]
+`)
+	defer os.Remove(path)
+
+	c, err := Load("dray", []string{"-config", path}, env(nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:4000", c.Listen)
+	assert.Equal(t, log.DebugLevel, c.Level())
+	assert.Equal(t, job.RedisConfig{Address: "redis:6379", Password: "secret", DB: 2, PoolSize: 8}, redis(t, c))
+
+	jc := c.Job()
+	assert.Equal(t, 5, jc.MaxRunningJobs)
+	assert.Equal(t, time.Minute, jc.StopTimeout)
+	assert.Equal(t, int64(512*1024*1024), jc.Resources.Defaults.Memory)
+	assert.Len(t, jc.Resources.Defaults.Ulimits, 1)
+	assert.Equal(t, []string{"ci", "host"}, jc.Networks.Allowed)
+	assert.Equal(t, map[string]int{"team-a": 10}, jc.Quotas.Limits)
+	assert.Equal(t, []string{"root"}, jc.Policy.Forbidden)
+}
+
+func TestLoadFileFromEnv(t *testing.T) {
+	path := writeConfigFile(t, "listen: :4000\n")
+	defer os.Remove(path)
+
+	c, err := Load("dray", nil, env(map[string]string{"CONFIG_FILE": path}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":4000", c.Listen)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "listen: :4000\nlogLevel: debug\nstore:\n  db: 1\n")
+	defer os.Remove(path)
+
+	c, err := Load("dray", []string{"-config", path, "-listen", ":5000"}, env(map[string]string{
+		"LISTEN_ADDRESS": ":4500",
+		"LOG_LEVEL":      "warn",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":5000", c.Listen)
+	assert.Equal(t, log.WarnLevel, c.Level())
+	assert.Equal(t, 1, c.Store.DB)
+}
+
+func TestLoadEnv(t *testing.T) {
+	c, err := Load("dray", nil, env(map[string]string{
+		"REDIS_PORT":         "tcp://172.17.0.2:6379",
+		"REDIS_PASSWORD":     "secret",
+		"REDIS_POOL_SIZE":    "10",
+		"DOCKER_HOST":        "tcp://10.0.0.1:2376",
+		"DOCKER_TLS_VERIFY":  "1",
+		"DOCKER_CERT_PATH":   "/certs",
+		"MAX_RUNNING_JOBS":   "3",
+		"MAX_MEMORY":         "1g",
+		"DEFAULT_CPU_SHARES": "512",
+		"ALLOWED_VOLUMES":    "cache, /srv/shared",
+		"NAMESPACE_QUOTAS":   "team-a=10,team-b=2",
+		"MAX_STEPS":          "4",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, job.RedisConfig{Address: "172.17.0.2:6379", Password: "secret", PoolSize: 10}, redis(t, c))
+	assert.Equal(t, "tcp://10.0.0.1:2376", c.Docker.Host)
+
+	jc := c.Job()
+	assert.Equal(t, job.DockerTLS{Enabled: true, Verify: true, CertPath: "/certs"}, jc.DockerTLS)
+	assert.Equal(t, 3, jc.MaxRunningJobs)
+	assert.Equal(t, int64(1024*1024*1024), jc.Resources.Maximums.Memory)
+	assert.Equal(t, int64(512), jc.Resources.Defaults.CPUShares)
+	assert.Equal(t, []string{"cache", "/srv/shared"}, jc.Volumes.Allowed)
+	assert.Equal(t, map[string]int{"team-a": 10, "team-b": 2}, jc.Quotas.Limits)
+	assert.Equal(t, 4, jc.Policy.MaxSteps)
+}
+
+func TestLoadRedisURLOverridesLink(t *testing.T) {
+	c, err := Load("dray", nil, env(map[string]string{
+		"REDIS_PORT": "tcp://172.17.0.2:6379",
+		"REDIS_URL":  "redis.example.com:6380",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "redis.example.com:6380", redis(t, c).Address)
+}
+
+func TestLoadRedisURL(t *testing.T) {
+	c, err := Load("dray", nil, env(map[string]string{
+		"REDIS_URL":      "redis+sentinel://:secret@s1,s2/dray/2",
+		"REDIS_PASSWORD": "ignored",
+		"REDIS_DB":       "1",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, job.RedisConfig{
+		Password:   "secret",
+		DB:         2,
+		PoolSize:   4,
+		Sentinels:  []string{"s1:26379", "s2:26379"},
+		MasterName: "dray",
+	}, redis(t, c))
+
+	c.Store.URL = "redis://redis.example.com"
+	assert.Equal(t, job.RedisConfig{Address: "redis.example.com:6379", Password: "ignored", DB: 1, PoolSize: 4}, redis(t, c))
+}
+
+func TestRedisTLSCAFile(t *testing.T) {
+	path := writeConfigFile(t, "not a certificate")
+	defer os.Remove(path)
+
+	c := Default()
+	c.Store.URL = "rediss://redis.example.com"
+	rc := redis(t, c)
+	assert.NotNil(t, rc.TLS)
+	assert.Nil(t, rc.TLS.RootCAs)
+
+	c.Store.TLSCAFile = path
+	_, err := c.Redis()
+	assert.EqualError(t, err, "Invalid Redis TLS CA file: no certificates found in "+path)
+
+	// The CA file is only read when TLS is used
+	c.Store.URL = "redis://redis.example.com"
+	_, err = c.Redis()
+	assert.NoError(t, err)
+}
+
+func TestLoadFlags(t *testing.T) {
+	c, err := Load("dray", []string{"-p", "8080", "-redis-db", "3", "-docker-tls-verify", "-allowed-images", "centurylink/*", "-print-config"}, env(map[string]string{
+		"HOME": "/home/dray",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", c.Listen)
+	assert.Equal(t, 3, c.Store.DB)
+	assert.True(t, c.Docker.TLSVerify)
+	assert.Equal(t, "/home/dray/.docker", c.Job().DockerTLS.CertPath)
+	assert.Equal(t, []string{"centurylink/*"}, c.Jobs.Policy.AllowedImages)
+	assert.True(t, c.PrintConfig)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load("dray", []string{"-config", "/does/not/exist"}, env(nil))
+	assert.Error(t, err)
+
+	path := writeConfigFile(t, "listen: [\n")
+	defer os.Remove(path)
+	_, err = Load("dray", []string{"-config", path}, env(nil))
+	assert.Contains(t, err.Error(), "Invalid config file")
+
+	_, err = Load("dray", nil, env(map[string]string{"REDIS_DB": "one"}))
+	assert.Contains(t, err.Error(), "Invalid REDIS_DB")
+
+	_, err = Load("dray", []string{"-workers", "many"}, env(nil))
+	assert.Error(t, err)
+
+}
+
+func TestLoadInvalidLogLevel(t *testing.T) {
+	c, err := Load("dray", []string{"-log-level", "loud"}, env(nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "info", c.LogLevel)
+	assert.Equal(t, log.InfoLevel, c.Level())
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		change func(c *Config)
+		err    string
+	}{
+		{func(c *Config) { c.Listen = "3000" }, `Invalid listen address "3000"`},
+		{func(c *Config) { c.ShutdownGracePeriod = "soon" }, `Invalid shutdownGracePeriod "soon"`},
+		{func(c *Config) { c.Store.Backend = "etcd" }, `Unsupported store backend "etcd", must be "redis"`},
+		{func(c *Config) { c.Store.URL = "tcp://" }, `Invalid Redis URL "tcp://"`},
+		{func(c *Config) { c.Store.URL = "http://redis" }, `Invalid Redis URL "http://redis", unknown scheme "http"`},
+		{func(c *Config) { c.Store.URL = "redis" }, `Invalid Redis URL "redis"`},
+		{func(c *Config) { c.Store.DB = -1 }, "Invalid Redis database -1"},
+		{func(c *Config) { c.Store.PoolSize = 0 }, "Invalid Redis pool size 0, must be at least 1"},
+		{func(c *Config) { c.TLS.CertFile = "cert.pem" }, "Invalid TLS configuration: the certificate and key files must both be set"},
+		{func(c *Config) { c.Jobs.Workers = -1 }, "Invalid number of workers -1"},
+		{func(c *Config) { c.Jobs.StopTimeout = "-1s" }, `Invalid stopTimeout "-1s"`},
+		{func(c *Config) { c.Jobs.Maximums.Memory = "lots" }, "Invalid maximums.memory: invalid size: 'lots'"},
+		{func(c *Config) { c.Jobs.NamespaceQuotas = map[string]int{"Team A": 1} }, `Invalid namespace "Team A" in namespace quotas`},
+		{func(c *Config) { c.Jobs.Policy.Forbidden = []string{"sudo"} }, `Invalid security policy: Unknown option "sudo", must be "root", "host-network" or "host-volumes"`},
+	} {
+		c := Default()
+		tc.change(c)
+		assert.EqualError(t, c.Validate(), tc.err)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	c := Default()
+	c.Store.Password = "secret"
+	c.Auth.JWTSecret = "hmac"
+	c.Store.URL = "redis://:hidden@redis.example.com/2"
+
+	b, err := c.YAML()
+	assert.NoError(t, err)
+
+	printed := &Config{}
+	assert.NoError(t, yaml.Unmarshal(b, printed))
+	assert.Equal(t, "********", printed.Store.Password)
+	assert.Equal(t, "********", printed.Auth.JWTSecret)
+	assert.Equal(t, "redis://:********@redis.example.com/2", printed.Store.URL)
+	assert.Equal(t, ":3000", printed.Listen)
+
+	// The secrets of the configuration itself are unchanged
+	assert.Equal(t, "secret", c.Store.Password)
+}
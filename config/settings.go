@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CenturyLinkLabs/dray/job"
+)
+
+// A setting binds a field of the Config to the command-line flag and the
+// environment variable which set it. Either name may be empty.
+type setting struct {
+	flag  string
+	env   string
+	value valueSetter
+	usage string
+}
+
+type valueSetter interface {
+	String() string
+	Set(string) error
+}
+
+// Returns the settings which can be changed by flags and the environment.
+// When several environment variables set the same field, the last one which
+// is present wins.
+func (c *Config) settings() []setting {
+	s := []setting{
+		{"listen", "LISTEN_ADDRESS", (*stringValue)(&c.Listen), "address on which the server listens"},
+		{"p", "", (*portValue)(&c.Listen), "port on which the server listens (on all interfaces)"},
+		{"log-level", "LOG_LEVEL", (*stringValue)(&c.LogLevel), "minimum level of the messages to log"},
+		{"shutdown-grace-period", "SHUTDOWN_GRACE_PERIOD", (*stringValue)(&c.ShutdownGracePeriod), "time to wait for running jobs to finish on shutdown"},
+
+		{"store", "STORE_BACKEND", (*stringValue)(&c.Store.Backend), "backend in which jobs are stored"},
+		{"", "REDIS_PORT", (*stringValue)(&c.Store.URL), ""},
+		{"redis", "REDIS_URL", (*stringValue)(&c.Store.URL), "URL of the Redis server or Sentinels"},
+		{"", "REDIS_PASSWORD", (*stringValue)(&c.Store.Password), ""},
+		{"redis-db", "REDIS_DB", (*intValue)(&c.Store.DB), "number of the Redis database"},
+		{"redis-pool-size", "REDIS_POOL_SIZE", (*intValue)(&c.Store.PoolSize), "number of idle Redis connections to keep open"},
+		{"redis-tls-ca", "REDIS_TLS_CA_FILE", (*stringValue)(&c.Store.TLSCAFile), "CA certificates which sign the Redis server's certificate"},
+
+		{"docker-host", "DOCKER_HOST", (*stringValue)(&c.Docker.Host), "Docker API endpoint"},
+		{"docker-tls-verify", "DOCKER_TLS_VERIFY", (*boolValue)(&c.Docker.TLSVerify), "connect to Docker with TLS and verify its certificate"},
+		{"docker-cert-path", "DOCKER_CERT_PATH", (*stringValue)(&c.Docker.CertPath), "directory holding the certificates for Docker"},
+
+		{"tls-cert", "TLS_CERT_FILE", (*stringValue)(&c.TLS.CertFile), "certificate with which to serve HTTPS"},
+		{"tls-key", "TLS_KEY_FILE", (*stringValue)(&c.TLS.KeyFile), "key with which to serve HTTPS"},
+		{"tls-client-ca", "TLS_CLIENT_CA_FILE", (*stringValue)(&c.TLS.ClientCAFile), "CA certificates which must have signed client certificates"},
+
+		{"api-tokens-file", "API_TOKENS_FILE", (*stringValue)(&c.Auth.TokensFile), "file listing the static API tokens"},
+		{"", "JWT_SECRET", (*stringValue)(&c.Auth.JWTSecret), ""},
+		{"jwt-issuer", "JWT_ISSUER", (*stringValue)(&c.Auth.JWTIssuer), "issuer required of JWTs"},
+
+		{"workers", "MAX_RUNNING_JOBS", (*intValue)(&c.Jobs.Workers), "number of running jobs at which the server reports it is not ready"},
+		{"stop-timeout", "STOP_TIMEOUT", (*stringValue)(&c.Jobs.StopTimeout), "time a cancelled step is given to exit before it is killed"},
+		{"workspace-path", "WORKSPACE_PATH", (*stringValue)(&c.Jobs.WorkspacePath), "path at which the default workspace is mounted"},
+		{"registry-auth-file", "REGISTRY_AUTH_FILE", (*stringValue)(&c.Jobs.RegistryAuthFile), "file holding private registry credentials"},
+		{"allowed-volumes", "ALLOWED_VOLUMES", (*listValue)(&c.Jobs.AllowedVolumes), "volumes and host paths which steps may mount"},
+		{"allowed-networks", "ALLOWED_NETWORKS", (*listValue)(&c.Jobs.AllowedNetworks), "networks which steps may join"},
+		{"namespace-quota", "NAMESPACE_QUOTA", (*intValue)(&c.Jobs.NamespaceQuota), "number of active jobs allowed in each namespace, per server"},
+		{"namespace-quotas", "NAMESPACE_QUOTAS", (*quotasValue)(&c.Jobs.NamespaceQuotas), "per-namespace limits on active jobs (e.g. team-a=10)"},
+
+		{"allowed-registries", "ALLOWED_REGISTRIES", (*listValue)(&c.Jobs.Policy.AllowedRegistries), "registries from which images may be pulled"},
+		{"allowed-images", "ALLOWED_IMAGES", (*listValue)(&c.Jobs.Policy.AllowedImages), "patterns of the images which jobs may use"},
+		{"forbidden-options", "FORBIDDEN_OPTIONS", (*listValue)(&c.Jobs.Policy.Forbidden), "privileged step options which are forbidden"},
+		{"required-resources", "REQUIRED_RESOURCES", (*listValue)(&c.Jobs.Policy.RequiredResources), "resource limits which every step must have"},
+		{"max-steps", "MAX_STEPS", (*intValue)(&c.Jobs.Policy.MaxSteps), "maximum number of steps in a job"},
+	}
+
+	s = append(s, c.Jobs.Defaults.settings("default", "DEFAULT_", "default")...)
+	s = append(s, c.Jobs.Maximums.settings("max", "MAX_", "maximum")...)
+
+	return s
+}
+
+func (l *LimitsConfig) settings(flagPrefix, envPrefix, desc string) []setting {
+	return []setting{
+		{flagPrefix + "-memory", envPrefix + "MEMORY", (*stringValue)(&l.Memory), desc + " memory limit for steps and services"},
+		{flagPrefix + "-memory-swap", envPrefix + "MEMORY_SWAP", (*stringValue)(&l.MemorySwap), desc + " memory and swap limit for steps and services"},
+		{flagPrefix + "-cpu-shares", envPrefix + "CPU_SHARES", (*int64Value)(&l.CPUShares), desc + " CPU shares for steps and services"},
+		{flagPrefix + "-cpu-quota", envPrefix + "CPU_QUOTA", (*int64Value)(&l.CPUQuota), desc + " CPU quota for steps and services"},
+		{flagPrefix + "-pids-limit", envPrefix + "PIDS_LIMIT", (*int64Value)(&l.PidsLimit), desc + " number of processes for steps and services"},
+		{flagPrefix + "-ulimits", envPrefix + "ULIMITS", (*stringValue)(&l.Ulimits), desc + " ulimits for steps and services"},
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+// portValue sets the listen address to a port on all interfaces.
+type portValue string
+
+func (v *portValue) Set(s string) error {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+
+	*v = portValue(fmt.Sprintf(":%d", port))
+	return nil
+}
+
+func (v *portValue) String() string {
+	s := string(*v)
+	return s[strings.LastIndex(s, ":")+1:]
+}
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	*v = int64Value(n)
+	return nil
+}
+
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+// listValue is a comma-separated list.
+type listValue []string
+
+func (v *listValue) Set(s string) error {
+	var list []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+
+	*v = list
+	return nil
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+// quotasValue is a comma-separated list of "namespace=limit" pairs.
+type quotasValue map[string]int
+
+func (v *quotasValue) Set(s string) error {
+	limits, err := job.ParseNamespaceQuotas(s)
+	if err != nil {
+		return err
+	}
+
+	*v = limits
+	return nil
+}
+
+func (v *quotasValue) String() string {
+	var pairs []string
+
+	for ns, limit := range *v {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", ns, limit))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,192 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type cronField struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	cronMinute = cronField{min: 0, max: 59}
+	cronHour   = cronField{min: 0, max: 23}
+	cronDom    = cronField{min: 1, max: 31}
+	cronMonth  = cronField{min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// cronSpec is a parsed, standard five-field cron expression. Each field is
+// stored as a bit set of the values which match.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// parseCron parses a standard cron expression consisting of the minute, hour,
+// day of month, month and day of week fields. The @hourly, @daily, @weekly,
+// @monthly and @yearly descriptors are also accepted.
+func parseCron(expr string) (*cronSpec, error) {
+	if d, ok := cronDescriptors[strings.TrimSpace(expr)]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Expected 5 fields in cron expression, found %d", len(fields))
+	}
+
+	spec := &cronSpec{
+		domStar: fields[2] == "*" || fields[2] == "?",
+		dowStar: fields[4] == "*" || fields[4] == "?",
+	}
+
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&spec.minute, cronMinute},
+		{&spec.hour, cronHour},
+		{&spec.dom, cronDom},
+		{&spec.month, cronMonth},
+		{&spec.dow, cronDow},
+	} {
+		if *f.bits, err = f.field.parse(fields[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sunday may be specified as either 0 or 7
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+
+	return spec, nil
+}
+
+func (f cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		low, high, step := f.min, f.max, uint(1)
+		rangeExpr := part
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || s == 0 {
+				return 0, fmt.Errorf("Invalid step in cron expression: %s", part)
+			}
+
+			step = uint(s)
+			rangeExpr = part[:i]
+		}
+
+		if rangeExpr != "*" && rangeExpr != "?" {
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+
+			high = low
+			if len(bounds) == 2 {
+				if high, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				high = f.max
+			}
+		}
+
+		if low > high {
+			return 0, fmt.Errorf("Invalid range in cron expression: %s", part)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) value(s string) (uint, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(v) < f.min || uint(v) > f.max {
+		return 0, fmt.Errorf("Invalid value in cron expression: %s", s)
+	}
+
+	return uint(v), nil
+}
+
+// next returns the first time after t which matches the cron expression. The
+// zero time is returned if no match can be found within five years.
+func (s *cronSpec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// When both the day of month and day of week are restricted a day matches if
+// either field matches, otherwise both must match.
+func (s *cronSpec) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
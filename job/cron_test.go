@@ -0,0 +1,48 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	start := time.Date(2015, time.March, 19, 10, 30, 15, 0, time.UTC)
+
+	for expr, expected := range map[string]time.Time{
+		"* * * * *":           time.Date(2015, time.March, 19, 10, 31, 0, 0, time.UTC),
+		"*/15 * * * *":        time.Date(2015, time.March, 19, 10, 45, 0, 0, time.UTC),
+		"0 9-17 * * *":        time.Date(2015, time.March, 19, 11, 0, 0, 0, time.UTC),
+		"30 10 * * *":         time.Date(2015, time.March, 20, 10, 30, 0, 0, time.UTC),
+		"0 0 1 jan *":         time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * sun":         time.Date(2015, time.March, 22, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":           time.Date(2015, time.March, 22, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * mon":         time.Date(2015, time.March, 23, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":          time.Date(2016, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"@hourly":             time.Date(2015, time.March, 19, 11, 0, 0, 0, time.UTC),
+		"5,10 12 20-25/2 * *": time.Date(2015, time.March, 20, 12, 5, 0, 0, time.UTC),
+	} {
+		spec, err := parseCron(expr)
+		if assert.NoError(t, err, expr) {
+			assert.Equal(t, expected, spec.next(start), expr)
+		}
+	}
+}
+
+func TestCronNextTimezone(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	start := time.Date(2015, time.March, 19, 10, 30, 0, 0, time.UTC)
+	spec, _ := parseCron("0 9 * * *")
+
+	next := spec.next(start.In(loc))
+
+	assert.Equal(t, time.Date(2015, time.March, 19, 14, 0, 0, 0, time.UTC), next.UTC())
+}
@@ -0,0 +1,275 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// dockerAPI makes requests to the parts of the Docker Remote API which are not
+// supported by the vendored revision of go-dockerclient (such as the resource
+// limits in a container's HostConfig). Errors are reported using the same
+// docker.Error type returned by go-dockerclient.
+type dockerAPI struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newDockerAPI(endpoint string, t DockerTLS) (*dockerAPI, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	api := &dockerAPI{httpClient: &http.Client{}}
+	scheme := "http://"
+
+	if t.Enabled && u.Scheme != "unix" {
+		c, err := t.config()
+		if err != nil {
+			return nil, err
+		}
+
+		scheme = "https://"
+		api.httpClient.Transport = &http.Transport{TLSClientConfig: c}
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		api.baseURL = "http://docker"
+		api.httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	case "tcp":
+		api.baseURL = scheme + u.Host
+	default:
+		api.baseURL = strings.TrimRight(endpoint, "/")
+	}
+
+	return api, nil
+}
+
+// containerConfig is the body of a container create request.
+type containerConfig struct {
+	Image      string
+	Cmd        []string   `json:",omitempty"`
+	Entrypoint []string   `json:",omitempty"`
+	WorkingDir string     `json:",omitempty"`
+	User       string     `json:",omitempty"`
+	Env        []string   `json:",omitempty"`
+	OpenStdin  bool       `json:",omitempty"`
+	StdinOnce  bool       `json:",omitempty"`
+	HostConfig hostConfig `json:",omitempty"`
+
+	Healthcheck      *dockerHealthcheck `json:",omitempty"`
+	NetworkingConfig *networkingConfig  `json:",omitempty"`
+}
+
+type dockerHealthcheck struct {
+	Test     []string
+	Interval int64
+	Retries  int
+}
+
+// containerState is the part of a container's inspect response which
+// describes whether it is running and healthy.
+type containerState struct {
+	Running  bool
+	ExitCode int
+	Health   *struct {
+		Status string
+	}
+}
+
+type hostConfig struct {
+	Binds       []string       `json:",omitempty"`
+	Memory      int64          `json:",omitempty"`
+	MemorySwap  int64          `json:",omitempty"`
+	CPUShares   int64          `json:"CpuShares,omitempty"`
+	CPUQuota    int64          `json:"CpuQuota,omitempty"`
+	PidsLimit   int64          `json:",omitempty"`
+	Ulimits     []dockerUlimit `json:",omitempty"`
+	NetworkMode string         `json:",omitempty"`
+	ExtraHosts  []string       `json:",omitempty"`
+	DNS         []string       `json:"Dns,omitempty"`
+}
+
+// Applies the limits to the container. Nothing is changed if r is nil.
+func (hc *hostConfig) setResources(r *Resources) {
+	if r == nil {
+		return
+	}
+
+	hc.Memory = r.Memory
+	hc.MemorySwap = r.MemorySwap
+	hc.CPUShares = r.CPUShares
+	hc.CPUQuota = r.CPUQuota
+	hc.PidsLimit = r.PidsLimit
+
+	for _, u := range r.Ulimits {
+		hc.Ulimits = append(hc.Ulimits, dockerUlimit(u))
+	}
+}
+
+type networkingConfig struct {
+	EndpointsConfig map[string]endpointConfig
+}
+
+type endpointConfig struct {
+	Aliases []string `json:",omitempty"`
+}
+
+type dockerUlimit struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
+// imageInfo is the part of an image's inspect response which identifies the
+// exact image.
+type imageInfo struct {
+	ID          string `json:"Id"`
+	RepoDigests []string
+}
+
+func (d *dockerAPI) inspectImage(name string) (*imageInfo, error) {
+	image := &imageInfo{}
+
+	err := d.do("GET", "/images/"+name+"/json", nil, image)
+	if e, ok := err.(*docker.Error); ok && e.Status == http.StatusNotFound {
+		return nil, docker.ErrNoSuchImage
+	} else if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// Asks the registry for the digest of the manifest currently referenced by
+// the image name.
+func (d *dockerAPI) distributionDigest(name string, auth docker.AuthConfiguration) (string, error) {
+	b, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+
+	header := http.Header{}
+	header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(b))
+
+	distribution := struct {
+		Descriptor struct {
+			Digest string `json:"digest"`
+		}
+	}{}
+
+	if err := d.request("GET", "/distribution/"+name+"/json", header, nil, &distribution); err != nil {
+		return "", err
+	}
+
+	return distribution.Descriptor.Digest, nil
+}
+
+func (d *dockerAPI) createContainer(config *containerConfig) (string, error) {
+	container := struct {
+		ID string `json:"Id"`
+	}{}
+
+	err := d.do("POST", "/containers/create", config, &container)
+	if e, ok := err.(*docker.Error); ok && e.Status == http.StatusNotFound {
+		return "", docker.ErrNoSuchImage
+	}
+
+	return container.ID, err
+}
+
+func (d *dockerAPI) inspectContainer(id string) (*containerState, error) {
+	container := struct {
+		State containerState
+	}{}
+
+	if err := d.do("GET", "/containers/"+id+"/json", nil, &container); err != nil {
+		return nil, err
+	}
+
+	return &container.State, nil
+}
+
+func (d *dockerAPI) createVolume(name string) error {
+	return d.do("POST", "/volumes/create", map[string]string{"Name": name}, nil)
+}
+
+func (d *dockerAPI) removeVolume(name string) error {
+	return d.do("DELETE", "/volumes/"+name, nil, nil)
+}
+
+func (d *dockerAPI) createNetwork(name string) error {
+	return d.do("POST", "/networks/create", map[string]interface{}{"Name": name, "CheckDuplicate": true}, nil)
+}
+
+func (d *dockerAPI) removeNetwork(name string) error {
+	return d.do("DELETE", "/networks/"+name, nil, nil)
+}
+
+func (d *dockerAPI) do(method, path string, in, out interface{}) error {
+	return d.request(method, path, nil, in, out)
+}
+
+func (d *dockerAPI) request(method, path string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, d.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 400 {
+		return &docker.Error{Status: res.StatusCode, Message: string(b)}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, out)
+}
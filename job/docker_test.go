@@ -0,0 +1,36 @@
+package job
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDockerAPI(t *testing.T) {
+	api, _ := newDockerAPI("tcp://10.0.0.1:2375", DockerTLS{})
+	assert.Equal(t, "http://10.0.0.1:2375", api.baseURL)
+
+	api, _ = newDockerAPI("unix:///var/run/docker.sock", DockerTLS{})
+	assert.Equal(t, "http://docker", api.baseURL)
+	assert.NotNil(t, api.httpClient.Transport)
+
+	api, _ = newDockerAPI("http://localhost:2375/", DockerTLS{})
+	assert.Equal(t, "http://localhost:2375", api.baseURL)
+}
+
+func TestNewDockerAPIWithTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dray-tls")
+	defer os.RemoveAll(dir)
+	writeTestCerts(t, dir)
+
+	api, err := newDockerAPI("tcp://10.0.0.1:2376", DockerTLS{Enabled: true, Verify: true, CertPath: dir})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://10.0.0.1:2376", api.baseURL)
+	assert.NotNil(t, api.httpClient.Transport.(*http.Transport).TLSClientConfig)
+
+	_, err = newDockerAPI("tcp://10.0.0.1:2376", DockerTLS{Enabled: true, CertPath: "/does/not/exist"})
+	assert.Error(t, err)
+}
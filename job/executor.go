@@ -3,25 +3,122 @@ package job
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
 )
 
+// defaultStopTimeout is the time a step's container is given to exit after
+// it is asked to stop, unless the Config sets a StopTimeout.
+const defaultStopTimeout = 10 * time.Second
+
+// servicePollInterval is the time between checks of a service's health.
+var servicePollInterval = 500 * time.Millisecond
+
 type jobStepExecutor struct {
-	client *docker.Client
+	client      *docker.Client
+	api         *dockerAPI
+	registry    RegistryAuth
+	stopTimeout time.Duration
 }
 
 // NewExecutor returns a JobStepExecutor instance with a connection to the
-// specified Docker API endpoint.
-func NewExecutor(dockerEndpoint string) JobStepExecutor {
-	client, err := docker.NewClient(dockerEndpoint)
+// specified Docker API endpoint. Images are pulled using the credentials in
+// the Config's RegistryAuth, and the connection uses TLS if it is enabled
+// by the Config's DockerTLS.
+func NewExecutor(dockerEndpoint string, c Config) JobStepExecutor {
+	var client *docker.Client
+	var err error
+
+	if c.DockerTLS.Enabled {
+		ca, cert, key := c.DockerTLS.files()
+		client, err = docker.NewTLSClient(tlsEndpoint(dockerEndpoint), cert, key, ca)
+	} else {
+		client, err = docker.NewClient(dockerEndpoint)
+	}
+	if err != nil {
+		log.Errorf("Error instantiating Docker client: %s", err)
+		panic(err)
+	}
+
+	api, err := newDockerAPI(dockerEndpoint, c.DockerTLS)
 	if err != nil {
 		log.Errorf("Error instantiating Docker client: %s", err)
 		panic(err)
 	}
 
-	return &jobStepExecutor{client: client}
+	stopTimeout := c.StopTimeout
+	if stopTimeout <= 0 {
+		stopTimeout = defaultStopTimeout
+	}
+
+	return &jobStepExecutor{client: client, api: api, registry: c.Registry, stopTimeout: stopTimeout}
+}
+
+func (e *jobStepExecutor) Ping() error {
+	return e.client.Ping()
+}
+
+func (e *jobStepExecutor) ResolveImage(j *Job, image string) (string, error) {
+	if isPinned(image) {
+		return image, nil
+	}
+
+	digest, err := e.api.distributionDigest(image, e.registry.lookup(j.RegistryCredentials, image))
+	if err != nil {
+		return "", err
+	}
+
+	return pinImage(image, digest), nil
+}
+
+func (e *jobStepExecutor) Setup(j *Job, serviceLog io.Writer) error {
+	if len(j.Workspace) > 0 {
+		name := workspaceVolume(j)
+		if err := e.api.createVolume(name); err != nil {
+			return err
+		}
+
+		log.Infof("Volume %s created", name)
+	}
+
+	if j.usesNetwork() {
+		name := jobNetwork(j)
+		if err := e.api.createNetwork(name); err != nil {
+			return err
+		}
+
+		log.Infof("Network %s created", name)
+	}
+
+	for i := range j.Services {
+		if err := e.startService(j, &j.Services[i], serviceLog); err != nil {
+			return err
+		}
+	}
+
+	for i := range j.Services {
+		if err := e.waitForService(&j.Services[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *jobStepExecutor) Pull(j *Job, progress io.Writer) error {
+	step := j.currentStep()
+	auth := e.registry.lookup(j.RegistryCredentials, step.Source)
+
+	image, err := e.ensureImage(step.Source, step.pullPolicy(), auth, progress)
+	if err != nil {
+		return err
+	}
+
+	step.ResolvedImage = newImageRef(step.Source, image)
+	return nil
 }
 
 func (e *jobStepExecutor) Start(j *Job, stdIn io.Reader, stdOut, stdErr io.WriteCloser) error {
@@ -62,6 +159,20 @@ func (e *jobStepExecutor) Inspect(j *Job) error {
 	return nil
 }
 
+func (e *jobStepExecutor) Stop(id string) error {
+	if len(id) == 0 {
+		return nil
+	}
+
+	err := e.client.StopContainer(id, uint(e.stopTimeout/time.Second))
+
+	if err == nil {
+		log.Infof("Container %s stopped", id)
+	}
+
+	return err
+}
+
 func (e *jobStepExecutor) CleanUp(j *Job) error {
 	removeOpts := docker.RemoveContainerOptions{
 		ID: j.currentStep().id,
@@ -76,37 +187,195 @@ func (e *jobStepExecutor) CleanUp(j *Job) error {
 	return err
 }
 
+// Attempts to remove all of the job's resources, returning the first error.
+func (e *jobStepExecutor) TearDown(j *Job) error {
+	var err error
+
+	for i := range j.Services {
+		if svcErr := e.removeService(&j.Services[i]); svcErr != nil && err == nil {
+			err = svcErr
+		}
+	}
+
+	if j.usesNetwork() {
+		name := jobNetwork(j)
+		if netErr := e.api.removeNetwork(name); netErr != nil {
+			if err == nil {
+				err = netErr
+			}
+		} else {
+			log.Infof("Network %s removed", name)
+		}
+	}
+
+	if len(j.Workspace) > 0 {
+		name := workspaceVolume(j)
+		if volErr := e.api.removeVolume(name); volErr != nil {
+			if err == nil {
+				err = volErr
+			}
+		} else {
+			log.Infof("Volume %s removed", name)
+		}
+	}
+
+	return err
+}
+
 func (e *jobStepExecutor) createContainer(j *Job) (string, error) {
 	step := j.currentStep()
-	if err := e.ensureImage(step.Source, step.Refresh); err != nil {
-		return "", err
+	config := &containerConfig{
+		Image:      step.Source,
+		Cmd:        step.Command,
+		Entrypoint: step.Entrypoint,
+		WorkingDir: step.WorkingDir,
+		User:       step.User,
+		Env:        j.currentStepEnvironment().stringify(),
+		OpenStdin:  true,
+		StdinOnce:  true,
 	}
 
-	opts := docker.CreateContainerOptions{
-		Config: &docker.Config{
-			Image:     step.Source,
-			Env:       j.currentStepEnvironment().stringify(),
-			OpenStdin: true,
-			StdinOnce: true,
-		},
+	if step.usesFilePipe() {
+		config.HostConfig.Binds = append(config.HostConfig.Binds, fmt.Sprintf("%s:%s", step.filePipePath(), step.Output))
 	}
 
-	if step.usesFilePipe() {
-		opts.HostConfig = &docker.HostConfig{
-			Binds: []string{fmt.Sprintf("%s:%s", step.filePipePath(), step.Output)},
+	if len(j.Workspace) > 0 {
+		config.HostConfig.Binds = append(config.HostConfig.Binds, fmt.Sprintf("%s:%s", workspaceVolume(j), j.Workspace))
+	}
+
+	for _, vol := range step.Volumes {
+		config.HostConfig.Binds = append(config.HostConfig.Binds, vol.bind())
+	}
+
+	config.HostConfig.setResources(step.Resources)
+
+	if network := j.currentStepNetwork(); len(network) > 0 {
+		config.HostConfig.NetworkMode = network
+
+		if aliases := step.aliases(); isUserDefinedNetwork(network) && len(aliases) > 0 {
+			config.NetworkingConfig = &networkingConfig{
+				EndpointsConfig: map[string]endpointConfig{network: {Aliases: aliases}},
+			}
 		}
 	}
 
-	container, err := e.client.CreateContainer(opts)
+	config.HostConfig.ExtraHosts = step.ExtraHosts
+	config.HostConfig.DNS = step.DNS
+
+	id, err := e.api.createContainer(config)
 
 	if err == nil {
-		log.Infof("Container %s created from %s", container.ID, step.Source)
-		return container.ID, err
+		log.Infof("Container %s created from %s", id, step.Source)
+		return id, err
 	}
 
 	return "", err
 }
 
+// Starts the service's container on the job's network and begins copying its
+// output to the serviceLog.
+func (e *jobStepExecutor) startService(j *Job, svc *Service, serviceLog io.Writer) error {
+	auth := e.registry.lookup(j.RegistryCredentials, svc.Source)
+	progress, done := prefixWriter(serviceLog, svc.Name)
+	_, err := e.ensureImage(svc.Source, svc.pullPolicy(), auth, progress)
+	progress.Close()
+	<-done
+
+	if err != nil {
+		return err
+	}
+
+	network := jobNetwork(j)
+	config := &containerConfig{
+		Image: svc.Source,
+		Env:   svc.Environment.stringify(),
+		User:  svc.User,
+		HostConfig: hostConfig{
+			NetworkMode: network,
+		},
+		NetworkingConfig: &networkingConfig{
+			EndpointsConfig: map[string]endpointConfig{network: {Aliases: []string{svc.Name}}},
+		},
+	}
+
+	config.HostConfig.setResources(svc.Resources)
+
+	if svc.Healthcheck != nil {
+		config.Healthcheck = svc.Healthcheck.config()
+	}
+
+	id, err := e.api.createContainer(config)
+	if err != nil {
+		return err
+	}
+
+	svc.id = id
+	log.Infof("Container %s created from %s for service %s", id, svc.Source, svc.Name)
+
+	if err := e.startContainer(id); err != nil {
+		return err
+	}
+
+	stdOutWriter, _ := prefixWriter(serviceLog, svc.Name)
+	stdErrWriter, _ := prefixWriter(serviceLog, svc.Name)
+
+	go func() {
+		defer stdOutWriter.Close()
+		defer stdErrWriter.Close()
+
+		e.client.Logs(docker.LogsOptions{
+			Container:    id,
+			OutputStream: stdOutWriter,
+			ErrorStream:  stdErrWriter,
+			Follow:       true,
+			Stdout:       true,
+			Stderr:       true,
+		})
+	}()
+
+	return nil
+}
+
+// Blocks until the service's healthcheck succeeds. A service without a
+// healthcheck is ready as soon as it is running.
+func (e *jobStepExecutor) waitForService(svc *Service) error {
+	for {
+		state, err := e.api.inspectContainer(svc.id)
+		if err != nil {
+			return err
+		}
+
+		if !state.Running {
+			return fmt.Errorf("Service %s exited with code %d", svc.Name, state.ExitCode)
+		}
+
+		if state.Health == nil || state.Health.Status == "healthy" {
+			log.Infof("Service %s is ready", svc.Name)
+			return nil
+		}
+
+		if state.Health.Status == "unhealthy" {
+			return fmt.Errorf("Service %s is unhealthy", svc.Name)
+		}
+
+		time.Sleep(servicePollInterval)
+	}
+}
+
+func (e *jobStepExecutor) removeService(svc *Service) error {
+	if len(svc.id) == 0 {
+		return nil
+	}
+
+	err := e.client.RemoveContainer(docker.RemoveContainerOptions{ID: svc.id, Force: true})
+
+	if err == nil {
+		log.Infof("Container %s for service %s removed", svc.id, svc.Name)
+	}
+
+	return err
+}
+
 func (e *jobStepExecutor) attachContainer(id string, stdIn io.Reader, stdOut, stdErr io.Writer) error {
 	attachOpts := docker.AttachToContainerOptions{
 		Container:    id,
@@ -133,37 +402,69 @@ func (e *jobStepExecutor) startContainer(id string) error {
 	return err
 }
 
-func (e *jobStepExecutor) ensureImage(name string, force bool) error {
-	image, err := e.client.InspectImage(name)
-	if err == docker.ErrNoSuchImage || force {
+// Makes sure that the image is available according to the pull policy and
+// returns the details of the image which will be used. Images pinned to a
+// digest are never pulled again since the registry cannot return a different
+// image for them.
+func (e *jobStepExecutor) ensureImage(name, policy string, auth docker.AuthConfiguration, progress io.Writer) (*imageInfo, error) {
+	image, err := e.api.inspectImage(name)
+	if err != nil && err != docker.ErrNoSuchImage {
+		return nil, err
+	}
 
-		log.Infof("Pulling image %s", name)
-		if err = e.pullImage(name); err != nil {
-			return err
-		}
+	if image == nil && policy == pullNever {
+		return nil, fmt.Errorf("Image %s is not present and its pull policy is %q", name, pullNever)
+	} else if image != nil && (policy != pullAlways || isPinned(name)) {
+		return image, nil
 	}
 
-	if force && image != nil {
-		newImage, err := e.client.InspectImage(name)
-		if err != nil {
-			return err
-		}
+	log.Infof("Pulling image %s", name)
+	fmt.Fprintf(progress, "Pulling image %s\n", name)
 
-		// Only remove image if new ID is different than old ID
-		if newImage.ID != image.ID {
-			e.removeImage(image.ID)
-		}
+	if err := e.pullImage(name, auth, progress); err != nil {
+		return nil, err
 	}
 
-	return err
+	newImage, err := e.api.inspectImage(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only remove image if new ID is different than old ID
+	if image != nil && newImage.ID != image.ID {
+		e.removeImage(image.ID)
+	}
+
+	return newImage, nil
 }
 
-func (e *jobStepExecutor) pullImage(name string) error {
+// Pulls the image, writing a line to progress whenever the status of the
+// pull (or of one of the image's layers) changes.
+func (e *jobStepExecutor) pullImage(name string, auth docker.AuthConfiguration, progress io.Writer) error {
+	start := time.Now()
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- writePullProgress(progress, r)
+		io.Copy(ioutil.Discard, r)
+	}()
+
 	opts := docker.PullImageOptions{
-		Repository: name,
+		Repository:    name,
+		OutputStream:  w,
+		RawJSONStream: true,
 	}
 
-	return e.client.PullImage(opts, docker.AuthConfiguration{})
+	err := e.client.PullImage(opts, auth)
+	w.Close()
+
+	if streamErr := <-done; err == nil {
+		err = streamErr
+	}
+
+	imagePullDuration.ObserveSince(start, result(err))
+	return err
 }
 
 func (e *jobStepExecutor) removeImage(name string) error {
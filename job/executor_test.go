@@ -3,13 +3,18 @@ package job
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/CenturyLinkLabs/testmux"
 	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -18,10 +23,40 @@ type mockExecutor struct {
 	mock.Mock
 
 	output string
+	image  *ImageRef
+
+	// onStart and onStop, if set, are called whenever a step is started or
+	// a container is stopped
+	onStart func(job *Job)
+	onStop  func(containerID string)
+}
+
+func (m *mockExecutor) Ping() error {
+	args := m.Mock.Called()
+	return args.Error(0)
+}
+
+func (m *mockExecutor) ResolveImage(job *Job, image string) (string, error) {
+	args := m.Mock.Called(job, image)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockExecutor) Setup(job *Job, serviceLog io.Writer) error {
+	args := m.Mock.Called(job, serviceLog)
+	return args.Error(0)
+}
+
+func (m *mockExecutor) Pull(job *Job, progress io.Writer) error {
+	args := m.Mock.Called(job, progress)
+	job.currentStep().ResolvedImage = m.image
+	return args.Error(0)
 }
 
 func (m *mockExecutor) Start(job *Job, stdIn io.Reader, stdOut, stdErr io.WriteCloser) error {
 	args := m.Mock.Called(job, stdIn, stdOut, stdErr)
+	if m.onStart != nil {
+		m.onStart(job)
+	}
 
 	if len(m.output) > 0 {
 		go func() {
@@ -43,11 +78,24 @@ func (m *mockExecutor) Inspect(job *Job) error {
 	return args.Error(0)
 }
 
+func (m *mockExecutor) Stop(containerID string) error {
+	args := m.Mock.Called(containerID)
+	if m.onStop != nil {
+		m.onStop(containerID)
+	}
+	return args.Error(0)
+}
+
 func (m *mockExecutor) CleanUp(job *Job) error {
 	args := m.Mock.Called(job)
 	return args.Error(0)
 }
 
+func (m *mockExecutor) TearDown(job *Job) error {
+	args := m.Mock.Called(job)
+	return args.Error(0)
+}
+
 type JobStepExecutorTestSuite struct {
 	suite.Suite
 
@@ -63,7 +111,16 @@ func (suite *JobStepExecutorTestSuite) SetupTest() {
 
 	suite.mux = &testmux.Router{}
 	suite.server = httptest.NewServer(suite.mux)
-	suite.jse = NewExecutor(suite.server.URL)
+	suite.jse = NewExecutor(suite.server.URL, Config{
+		Registry: RegistryAuth{
+			Auths: map[string]RegistryCredential{
+				"registry.example.com": {Username: "dray", Password: "secret"},
+			},
+			Credentials: map[string]map[string]RegistryCredential{
+				"team-a": {"registry.example.com": {Username: "team-a", Password: "hidden"}},
+			},
+		},
+	})
 
 	suite.jobStep = &JobStep{
 		id:     "abc123",
@@ -84,8 +141,6 @@ func (suite *JobStepExecutorTestSuite) TestStart_Success() {
 	stdOutReader, stdOutWriter := io.Pipe()
 	_, stdErrWriter := io.Pipe()
 
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
-		"{\"ID\":\"xyz789\"}")
 	suite.mux.RegisterResp("POST", "/containers/create", http.StatusCreated,
 		"{\"ID\":\"123abc\"}")
 	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusNoContent, "")
@@ -108,37 +163,60 @@ func (suite *JobStepExecutorTestSuite) TestStart_Success() {
 	suite.mux.AssertVisited(suite.T())
 }
 
-func (suite *JobStepExecutorTestSuite) TestStart_CreateError() {
+func (suite *JobStepExecutorTestSuite) TestStart_Resources() {
+	suite.job.currentStep().Resources = &Resources{
+		Memory:     1024,
+		MemorySwap: 2048,
+		CPUShares:  512,
+		CPUQuota:   50000,
+		PidsLimit:  100,
+		Ulimits:    []Ulimit{{Name: "nofile", Soft: 10, Hard: 20}},
+	}
 	stdIn := &bytes.Buffer{}
-	_, stdOutWriter := io.Pipe()
+	stdOutReader, stdOutWriter := io.Pipe()
 	_, stdErrWriter := io.Pipe()
 
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
-		"{\"ID\":\"xyz789\"}")
-	suite.mux.RegisterResp("POST", "/containers/create", http.StatusInternalServerError, "")
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"HostConfig\":{\"Memory\":1024,\"MemorySwap\":2048,\"CpuShares\":512,\"CpuQuota\":50000,\"PidsLimit\":100,\"Ulimits\":[{\"Name\":\"nofile\",\"Soft\":10,\"Hard\":20}]}")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"123abc\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusNoContent, "")
+	suite.mux.RegisterFunc("POST", "/containers/123abc/attach",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
 
 	err := suite.jse.Start(suite.job, stdIn, stdOutWriter, stdErrWriter)
 
-	suite.EqualError(err, "API error (500): \n")
+	// Must read in order to block until the attach call is complete
+	stdOutReader.Read([]byte{})
+
+	suite.NoError(err)
 	suite.mux.AssertVisited(suite.T())
 }
 
-func (suite *JobStepExecutorTestSuite) TestStart_StartError() {
+func (suite *JobStepExecutorTestSuite) TestStart_Volumes() {
+	suite.job.ID = "123"
+	suite.job.Workspace = "/workspace"
+	suite.job.currentStep().Volumes = []Volume{{Source: "data", Target: "/data", ReadOnly: true}}
 	stdIn := &bytes.Buffer{}
 	stdOutReader, stdOutWriter := io.Pipe()
 	_, stdErrWriter := io.Pipe()
 
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
-		"{\"ID\":\"xyz789\"}")
-	suite.mux.RegisterResp("POST", "/containers/create", http.StatusCreated,
-		"{\"ID\":\"123abc\"}")
-	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusBadRequest, "")
-
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"Binds\":[\"dray-workspace-123:/workspace\",\"data:/data:ro\"]")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"123abc\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusNoContent, "")
 	suite.mux.RegisterFunc("POST", "/containers/123abc/attach",
 		func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
-			w.Write([]byte{1, 0, 0, 0, 0, 0, 0, 5})
-			w.Write([]byte("hello"))
 		})
 
 	err := suite.jse.Start(suite.job, stdIn, stdOutWriter, stdErrWriter)
@@ -146,26 +224,31 @@ func (suite *JobStepExecutorTestSuite) TestStart_StartError() {
 	// Must read in order to block until the attach call is complete
 	stdOutReader.Read([]byte{})
 
-	suite.EqualError(err, "API error (400): \n")
+	suite.NoError(err)
 	suite.mux.AssertVisited(suite.T())
 }
 
-func (suite *JobStepExecutorTestSuite) TestStart_MissingImage() {
+func (suite *JobStepExecutorTestSuite) TestStart_Overrides() {
+	step := suite.job.currentStep()
+	step.Command = []string{"-c", "echo hello"}
+	step.Entrypoint = []string{"/bin/sh"}
+	step.WorkingDir = "/tmp"
+	step.User = "nobody"
 	stdIn := &bytes.Buffer{}
 	stdOutReader, stdOutWriter := io.Pipe()
 	_, stdErrWriter := io.Pipe()
 
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusNotFound, "")
-	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK, "")
-	suite.mux.RegisterResp("POST", "/containers/create", http.StatusCreated,
-		"{\"ID\":\"123abc\"}")
-	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusOK, "")
-
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"Image\":\"foo\",\"Cmd\":[\"-c\",\"echo hello\"],\"Entrypoint\":[\"/bin/sh\"],\"WorkingDir\":\"/tmp\",\"User\":\"nobody\"")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"123abc\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusNoContent, "")
 	suite.mux.RegisterFunc("POST", "/containers/123abc/attach",
 		func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
-			w.Write([]byte{1, 0, 0, 0, 0, 0, 0, 5})
-			w.Write([]byte("hello"))
 		})
 
 	err := suite.jse.Start(suite.job, stdIn, stdOutWriter, stdErrWriter)
@@ -177,35 +260,85 @@ func (suite *JobStepExecutorTestSuite) TestStart_MissingImage() {
 	suite.mux.AssertVisited(suite.T())
 }
 
-func (suite *JobStepExecutorTestSuite) TestStart_PullError() {
+func (suite *JobStepExecutorTestSuite) TestStart_Network() {
+	suite.job.ID = "123"
+	suite.job.CreateNetwork = true
+	step := suite.job.currentStep()
+	step.Name = "build"
+	step.NetworkAliases = []string{"builder"}
+	step.ExtraHosts = []string{"db:10.0.0.2"}
+	step.DNS = []string{"8.8.8.8"}
+	stdIn := &bytes.Buffer{}
+	stdOutReader, stdOutWriter := io.Pipe()
+	_, stdErrWriter := io.Pipe()
+
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"NetworkMode\":\"dray-network-123\",\"ExtraHosts\":[\"db:10.0.0.2\"],\"Dns\":[\"8.8.8.8\"]")
+			suite.Contains(string(body), "\"NetworkingConfig\":{\"EndpointsConfig\":{\"dray-network-123\":{\"Aliases\":[\"builder\",\"build\"]}}}")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"123abc\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusNoContent, "")
+	suite.mux.RegisterResp("POST", "/containers/123abc/attach", http.StatusOK, "")
+
+	err := suite.jse.Start(suite.job, stdIn, stdOutWriter, stdErrWriter)
+
+	// Must read in order to block until the attach call is complete
+	stdOutReader.Read([]byte{})
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestStart_HostNetwork() {
+	suite.job.currentStep().Network = "host"
+	stdIn := &bytes.Buffer{}
+	stdOutReader, stdOutWriter := io.Pipe()
+	_, stdErrWriter := io.Pipe()
+
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"NetworkMode\":\"host\"")
+			suite.NotContains(string(body), "NetworkingConfig")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"123abc\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusNoContent, "")
+	suite.mux.RegisterResp("POST", "/containers/123abc/attach", http.StatusOK, "")
+
+	err := suite.jse.Start(suite.job, stdIn, stdOutWriter, stdErrWriter)
+
+	// Must read in order to block until the attach call is complete
+	stdOutReader.Read([]byte{})
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestStart_CreateError() {
 	stdIn := &bytes.Buffer{}
 	_, stdOutWriter := io.Pipe()
 	_, stdErrWriter := io.Pipe()
 
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusNotFound, "")
-	suite.mux.RegisterResp("POST", "/images/create", http.StatusNotFound, "")
+	suite.mux.RegisterResp("POST", "/containers/create", http.StatusInternalServerError, "")
 
 	err := suite.jse.Start(suite.job, stdIn, stdOutWriter, stdErrWriter)
 
-	suite.EqualError(err, "API error (404): \n")
+	suite.EqualError(err, "API error (500): \n")
 	suite.mux.AssertVisited(suite.T())
 }
 
-func (suite *JobStepExecutorTestSuite) TestStart_ForceRefresh() {
-	suite.job.currentStep().Refresh = true
+func (suite *JobStepExecutorTestSuite) TestStart_StartError() {
 	stdIn := &bytes.Buffer{}
 	stdOutReader, stdOutWriter := io.Pipe()
 	_, stdErrWriter := io.Pipe()
 
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
-		"{\"Id\":\"xyz890\"}")
-	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK, "")
-	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
-		"{\"Id\":\"15930e\"}")
-	suite.mux.RegisterResp("DELETE", "/images/xyz890", http.StatusOK, "")
 	suite.mux.RegisterResp("POST", "/containers/create", http.StatusCreated,
 		"{\"ID\":\"123abc\"}")
-	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusOK, "")
+	suite.mux.RegisterResp("POST", "/containers/123abc/start", http.StatusBadRequest, "")
 
 	suite.mux.RegisterFunc("POST", "/containers/123abc/attach",
 		func(w http.ResponseWriter, r *http.Request) {
@@ -219,7 +352,220 @@ func (suite *JobStepExecutorTestSuite) TestStart_ForceRefresh() {
 	// Must read in order to block until the attach call is complete
 	stdOutReader.Read([]byte{})
 
+	suite.EqualError(err, "API error (400): \n")
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_Present() {
+	progress := &bytes.Buffer{}
+
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz789\"}")
+
+	err := suite.jse.Pull(suite.job, progress)
+
+	suite.NoError(err)
+	suite.Equal(&ImageRef{ID: "xyz789"}, suite.job.currentStep().ResolvedImage)
+	suite.Empty(progress.String())
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_MissingImage() {
+	progress := &bytes.Buffer{}
+
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusNotFound, "")
+	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK,
+		`{"status":"Pulling from library/foo","id":"latest"}
+		{"status":"Pulling fs layer","id":"a3ed95caeb02"}
+		{"status":"Downloading","progress":"[=>   ] 1 MB/5 MB","id":"a3ed95caeb02"}
+		{"status":"Downloading","progress":"[==>  ] 2 MB/5 MB","id":"a3ed95caeb02"}
+		{"status":"Pull complete","id":"a3ed95caeb02"}
+		{"status":"Status: Downloaded newer image for foo:latest"}`)
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz789\",\"RepoDigests\":[\"foo@sha256:abc\"]}")
+
+	err := suite.jse.Pull(suite.job, progress)
+
+	suite.NoError(err)
+	suite.Equal(&ImageRef{ID: "xyz789", Digest: "sha256:abc"}, suite.job.currentStep().ResolvedImage)
+	suite.Equal(`Pulling image foo
+latest: Pulling from library/foo
+a3ed95caeb02: Pulling fs layer
+a3ed95caeb02: Downloading
+a3ed95caeb02: Pull complete
+Status: Downloaded newer image for foo:latest
+`, progress.String())
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_Error() {
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusNotFound, "")
+	suite.mux.RegisterResp("POST", "/images/create", http.StatusNotFound, "")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
+	suite.EqualError(err, "API error (404): \n")
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_StreamError() {
+	progress := &bytes.Buffer{}
+
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusNotFound, "")
+	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK,
+		`{"error":"manifest for foo:latest not found"}`)
+
+	err := suite.jse.Pull(suite.job, progress)
+
+	suite.EqualError(err, "manifest for foo:latest not found")
+	suite.Equal("Pulling image foo\nmanifest for foo:latest not found\n", progress.String())
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_Never() {
+	suite.job.currentStep().PullPolicy = "never"
+
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusNotFound, "")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
+	suite.EqualError(err, "Image foo is not present and its pull policy is \"never\"")
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_Always() {
+	suite.job.currentStep().PullPolicy = "always"
+
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz890\"}")
+	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK, "")
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
+		"{\"Id\":\"15930e\"}")
+	suite.mux.RegisterResp("DELETE", "/images/xyz890", http.StatusOK, "")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.Equal("15930e", suite.job.currentStep().ResolvedImage.ID)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_Refresh() {
+	suite.job.currentStep().Refresh = true
+
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz890\"}")
+	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK, "")
+	suite.mux.RegisterResp("GET", "/images/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz890\"}")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_PrivateRegistry() {
+	suite.job.currentStep().Source = "registry.example.com/foo"
+	var auth docker.AuthConfiguration
+
+	suite.mux.RegisterResp("GET", "/images/registry.example.com/foo/json", http.StatusNotFound, "")
+	suite.mux.RegisterFunc("POST", "/images/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			b, _ := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+			json.Unmarshal(b, &auth)
+			w.WriteHeader(http.StatusOK)
+		})
+	suite.mux.RegisterResp("GET", "/images/registry.example.com/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz789\"}")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.Equal(docker.AuthConfiguration{
+		Username:      "dray",
+		Password:      "secret",
+		ServerAddress: "registry.example.com",
+	}, auth)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_RefreshCredentials() {
+	suite.job.RegistryCredentials = "team-a"
+	suite.job.currentStep().Source = "registry.example.com/foo"
+	suite.job.currentStep().PullPolicy = "always"
+	var auth docker.AuthConfiguration
+
+	suite.mux.RegisterResp("GET", "/images/registry.example.com/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz890\"}")
+	suite.mux.RegisterFunc("POST", "/images/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			b, _ := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+			json.Unmarshal(b, &auth)
+			w.WriteHeader(http.StatusOK)
+		})
+	suite.mux.RegisterResp("GET", "/images/registry.example.com/foo/json", http.StatusOK,
+		"{\"Id\":\"xyz890\"}")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
 	suite.NoError(err)
+	suite.Equal("team-a", auth.Username)
+	suite.Equal("hidden", auth.Password)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPull_PinnedAlways() {
+	suite.job.currentStep().Source = "foo@sha256:abc"
+	suite.job.currentStep().PullPolicy = "always"
+
+	suite.mux.RegisterResp("GET", "/images/foo@sha256:abc/json", http.StatusOK,
+		"{\"Id\":\"xyz789\"}")
+
+	err := suite.jse.Pull(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestResolveImage() {
+	suite.job.RegistryCredentials = "team-a"
+	suite.mux.RegisterFunc("GET", "/distribution/registry.example.com/foo:1.0/json",
+		func(w http.ResponseWriter, r *http.Request) {
+			b, _ := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+			suite.Contains(string(b), "\"username\":\"team-a\"")
+			w.Write([]byte("{\"Descriptor\":{\"digest\":\"sha256:abc\"}}"))
+		})
+
+	image, err := suite.jse.ResolveImage(suite.job, "registry.example.com/foo:1.0")
+
+	suite.NoError(err)
+	suite.Equal("registry.example.com/foo@sha256:abc", image)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestResolveImage_Pinned() {
+	image, err := suite.jse.ResolveImage(suite.job, "foo@sha256:abc")
+
+	suite.NoError(err)
+	suite.Equal("foo@sha256:abc", image)
+}
+
+func (suite *JobStepExecutorTestSuite) TestPing() {
+	suite.mux.RegisterResp("GET", "/_ping", http.StatusOK, "OK")
+
+	err := suite.jse.Ping()
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestPing_Error() {
+	suite.mux.RegisterResp("GET", "/_ping", http.StatusInternalServerError, "oops")
+
+	err := suite.jse.Ping()
+
+	suite.Error(err)
 	suite.mux.AssertVisited(suite.T())
 }
 
@@ -252,6 +598,37 @@ func (suite *JobStepExecutorTestSuite) TestInspect_ErrorExit() {
 	suite.mux.AssertVisited(suite.T())
 }
 
+func (suite *JobStepExecutorTestSuite) TestStop_Success() {
+	suite.mux.RegisterFunc("POST", "/containers/abc123/stop", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("10", r.URL.Query().Get("t"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := suite.jse.Stop("abc123")
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestStop_Timeout() {
+	suite.jse = NewExecutor(suite.server.URL, Config{StopTimeout: 90 * time.Second})
+	suite.mux.RegisterFunc("POST", "/containers/abc123/stop", func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("90", r.URL.Query().Get("t"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := suite.jse.Stop("abc123")
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestStop_NotStarted() {
+	err := suite.jse.Stop("")
+
+	suite.NoError(err)
+}
+
 func (suite *JobStepExecutorTestSuite) TestCleanUp_Success() {
 	suite.mux.RegisterResp("DELETE", "/containers/abc123", http.StatusNoContent, "")
 
@@ -270,6 +647,189 @@ func (suite *JobStepExecutorTestSuite) TestCleanUp_Error() {
 	suite.mux.AssertVisited(suite.T())
 }
 
+func (suite *JobStepExecutorTestSuite) TestSetup_Workspace() {
+	suite.job.ID = "123"
+	suite.job.Workspace = "/workspace"
+	suite.mux.RegisterFunc("POST", "/volumes/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Equal("{\"Name\":\"dray-workspace-123\"}", string(body))
+			w.WriteHeader(http.StatusCreated)
+		})
+
+	err := suite.jse.Setup(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestSetup_NoWorkspace() {
+	err := suite.jse.Setup(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestTearDown_Workspace() {
+	suite.job.ID = "123"
+	suite.job.Workspace = "/workspace"
+	suite.mux.RegisterResp("DELETE", "/volumes/dray-workspace-123", http.StatusInternalServerError, "oops")
+
+	err := suite.jse.TearDown(suite.job)
+
+	suite.EqualError(err, "API error (500): oops\n")
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestSetup_Network() {
+	suite.job.ID = "123"
+	suite.job.CreateNetwork = true
+	suite.mux.RegisterFunc("POST", "/networks/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Equal("{\"CheckDuplicate\":true,\"Name\":\"dray-network-123\"}", string(body))
+			w.WriteHeader(http.StatusCreated)
+		})
+
+	err := suite.jse.Setup(suite.job, ioutil.Discard)
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestTearDown_Network() {
+	suite.job.ID = "123"
+	suite.job.CreateNetwork = true
+	suite.job.Workspace = "/workspace"
+	suite.mux.RegisterResp("DELETE", "/networks/dray-network-123", http.StatusInternalServerError, "oops")
+	suite.mux.RegisterResp("DELETE", "/volumes/dray-workspace-123", http.StatusNoContent, "")
+
+	err := suite.jse.TearDown(suite.job)
+
+	suite.EqualError(err, "API error (500): oops\n")
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestSetup_Services() {
+	defer func(d time.Duration) { servicePollInterval = d }(servicePollInterval)
+	servicePollInterval = 0
+	suite.job.ID = "123"
+	suite.job.Services = []Service{
+		{Name: "db", Source: "postgres", User: "postgres", Healthcheck: &Healthcheck{Command: []string{"pg_isready"}}},
+	}
+	serviceLogReader, serviceLogWriter := io.Pipe()
+
+	suite.mux.RegisterResp("POST", "/networks/create", http.StatusCreated, "")
+	suite.mux.RegisterResp("GET", "/images/postgres/json", http.StatusOK,
+		"{\"ID\":\"xyz789\"}")
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"Healthcheck\":{\"Test\":[\"CMD\",\"pg_isready\"],\"Interval\":1000000000,\"Retries\":30}")
+			suite.Contains(string(body), "\"NetworkingConfig\":{\"EndpointsConfig\":{\"dray-network-123\":{\"Aliases\":[\"db\"]}}}")
+			suite.Contains(string(body), "\"User\":\"postgres\"")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"db123\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/db123/start", http.StatusNoContent, "")
+	suite.mux.RegisterFunc("GET", "/containers/db123/logs",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte{1, 0, 0, 0, 0, 0, 0, 6})
+			w.Write([]byte("ready\n"))
+		})
+	suite.mux.RegisterResp("GET", "/containers/db123/json", http.StatusOK,
+		"{\"State\":{\"Running\":true,\"Health\":{\"Status\":\"starting\"}}}")
+	suite.mux.RegisterResp("GET", "/containers/db123/json", http.StatusOK,
+		"{\"State\":{\"Running\":true,\"Health\":{\"Status\":\"healthy\"}}}")
+
+	err := suite.jse.Setup(suite.job, serviceLogWriter)
+
+	serviceLogScanner := bufio.NewScanner(serviceLogReader)
+	serviceLogScanner.Scan()
+
+	suite.NoError(err)
+	suite.Equal("db: ready", serviceLogScanner.Text())
+	suite.Equal("db123", suite.job.Services[0].id)
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestSetup_ServiceExited() {
+	suite.job.ID = "123"
+	suite.job.Services = []Service{{Name: "db", Source: "postgres"}}
+
+	suite.mux.RegisterResp("POST", "/networks/create", http.StatusCreated, "")
+	suite.mux.RegisterResp("GET", "/images/postgres/json", http.StatusOK,
+		"{\"ID\":\"xyz789\"}")
+	suite.mux.RegisterResp("POST", "/containers/create", http.StatusCreated,
+		"{\"Id\":\"db123\"}")
+	suite.mux.RegisterResp("POST", "/containers/db123/start", http.StatusNoContent, "")
+	suite.mux.RegisterResp("GET", "/containers/db123/logs", http.StatusOK, "")
+	suite.mux.RegisterResp("GET", "/containers/db123/json", http.StatusOK,
+		"{\"State\":{\"Running\":false,\"ExitCode\":3}}")
+
+	err := suite.jse.Setup(suite.job, ioutil.Discard)
+
+	suite.EqualError(err, "Service db exited with code 3")
+}
+
+func (suite *JobStepExecutorTestSuite) TestSetup_ServicePullPolicy() {
+	serviceLog := &bytes.Buffer{}
+	suite.job.ID = "123"
+	suite.job.Services = []Service{
+		{Name: "db", Source: "postgres", PullPolicy: "always", Resources: &Resources{Memory: 1024}},
+	}
+
+	suite.mux.RegisterResp("POST", "/networks/create", http.StatusCreated, "")
+	suite.mux.RegisterResp("GET", "/images/postgres/json", http.StatusOK,
+		"{\"Id\":\"xyz789\"}")
+	suite.mux.RegisterResp("POST", "/images/create", http.StatusOK,
+		`{"status":"Status: Downloaded newer image for postgres:latest"}`)
+	suite.mux.RegisterResp("GET", "/images/postgres/json", http.StatusOK,
+		"{\"Id\":\"xyz789\"}")
+	suite.mux.RegisterFunc("POST", "/containers/create",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			suite.Contains(string(body), "\"Memory\":1024")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{\"Id\":\"db123\"}"))
+		})
+	suite.mux.RegisterResp("POST", "/containers/db123/start", http.StatusNoContent, "")
+	suite.mux.RegisterResp("GET", "/containers/db123/logs", http.StatusOK, "")
+	suite.mux.RegisterResp("GET", "/containers/db123/json", http.StatusOK,
+		"{\"State\":{\"Running\":true}}")
+
+	err := suite.jse.Setup(suite.job, serviceLog)
+
+	suite.NoError(err)
+	suite.Equal("db: Pulling image postgres\ndb: Status: Downloaded newer image for postgres:latest\n", serviceLog.String())
+}
+
+func (suite *JobStepExecutorTestSuite) TestSetup_ServicePullNever() {
+	suite.job.ID = "123"
+	suite.job.Services = []Service{{Name: "db", Source: "postgres", PullPolicy: "never"}}
+
+	suite.mux.RegisterResp("POST", "/networks/create", http.StatusCreated, "")
+	suite.mux.RegisterResp("GET", "/images/postgres/json", http.StatusNotFound, "")
+
+	err := suite.jse.Setup(suite.job, ioutil.Discard)
+
+	suite.EqualError(err, "Image postgres is not present and its pull policy is \"never\"")
+	suite.mux.AssertVisited(suite.T())
+}
+
+func (suite *JobStepExecutorTestSuite) TestTearDown_Services() {
+	suite.job.ID = "123"
+	suite.job.Services = []Service{{Name: "db", id: "db123"}, {Name: "cache"}}
+	suite.mux.RegisterResp("DELETE", "/containers/db123", http.StatusNoContent, "")
+	suite.mux.RegisterResp("DELETE", "/networks/dray-network-123", http.StatusNoContent, "")
+
+	err := suite.jse.TearDown(suite.job)
+
+	suite.NoError(err)
+	suite.mux.AssertVisited(suite.T())
+}
+
 func TestJobStepExecutor(t *testing.T) {
 	suite.Run(t, new(JobStepExecutorTestSuite))
 }
@@ -0,0 +1,82 @@
+package job
+
+import (
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+// Readiness reports whether Dray is able to accept and execute jobs. Its
+// Status is "ok" only if both Redis and Docker can be reached, there is
+// capacity for another job to run and the server is not shutting down.
+type Readiness struct {
+	Status  string           `json:"status"`
+	Redis   DependencyHealth `json:"redis"`
+	Docker  DependencyHealth `json:"docker"`
+	Workers WorkerHealth     `json:"workers"`
+}
+
+// DependencyHealth describes the result of checking one of the services on
+// which Dray depends. The reason a service cannot be reached is only logged,
+// as the readiness endpoint does not require authentication.
+type DependencyHealth struct {
+	Status string `json:"status"`
+}
+
+// WorkerHealth describes the number of jobs currently executing and the
+// maximum number which may run at once (zero meaning there is no limit).
+// Draining is set once the server has started shutting down.
+type WorkerHealth struct {
+	Status   string `json:"status"`
+	Running  int    `json:"running"`
+	Capacity int    `json:"capacity,omitempty"`
+	Draining bool   `json:"draining,omitempty"`
+}
+
+// Ready returns true if every check passed.
+func (r Readiness) Ready() bool {
+	return r.Status == healthOK
+}
+
+func dependencyHealth(name string, err error) DependencyHealth {
+	if err != nil {
+		log.Warnf("Readiness check failed, %s is unavailable: %s", name, err)
+		return DependencyHealth{Status: healthUnavailable}
+	}
+
+	return DependencyHealth{Status: healthOK}
+}
+
+func workerHealth(running, capacity int, draining bool) WorkerHealth {
+	wh := WorkerHealth{Status: healthOK, Running: running, Capacity: capacity, Draining: draining}
+
+	if draining || (capacity > 0 && running >= capacity) {
+		wh.Status = healthUnavailable
+	}
+
+	return wh
+}
+
+func (jm *jobManager) Readiness() Readiness {
+	jm.mu.Lock()
+	running, draining := len(jm.running), jm.draining
+	jm.mu.Unlock()
+
+	r := Readiness{
+		Status:  healthOK,
+		Redis:   dependencyHealth("Redis", jm.repository.Ping()),
+		Docker:  dependencyHealth("Docker", jm.executor.Ping()),
+		Workers: workerHealth(running, jm.config.MaxRunningJobs, draining),
+	}
+
+	for _, s := range []string{r.Redis.Status, r.Docker.Status, r.Workers.Status} {
+		if s != healthOK {
+			r.Status = healthUnavailable
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	r := &mockRepository{}
+	e := &mockExecutor{}
+	r.On("Ping").Return(nil)
+	e.On("Ping").Return(nil)
+	jm := NewJobManager(r, e, Config{MaxRunningJobs: 2}).(*jobManager)
+	jm.track(&Job{ID: "123"})
+	defer jm.untrack(&Job{ID: "123"})
+
+	readiness := jm.Readiness()
+
+	assert.True(t, readiness.Ready())
+	assert.Equal(t, Readiness{
+		Status:  "ok",
+		Redis:   DependencyHealth{Status: "ok"},
+		Docker:  DependencyHealth{Status: "ok"},
+		Workers: WorkerHealth{Status: "ok", Running: 1, Capacity: 2},
+	}, readiness)
+}
+
+func TestReadinessDependencyUnavailable(t *testing.T) {
+	r := &mockRepository{}
+	e := &mockExecutor{}
+	r.On("Ping").Return(errors.New("Redis connection error"))
+	e.On("Ping").Return(nil)
+	jm := NewJobManager(r, e, Config{})
+
+	readiness := jm.Readiness()
+
+	assert.False(t, readiness.Ready())
+	assert.Equal(t, "unavailable", readiness.Status)
+	assert.Equal(t, DependencyHealth{Status: "unavailable"}, readiness.Redis)
+	assert.Equal(t, WorkerHealth{Status: "ok"}, readiness.Workers)
+}
+
+func TestReadinessAtCapacity(t *testing.T) {
+	r := &mockRepository{}
+	e := &mockExecutor{}
+	r.On("Ping").Return(nil)
+	e.On("Ping").Return(nil)
+	jm := NewJobManager(r, e, Config{MaxRunningJobs: 1}).(*jobManager)
+	jm.track(&Job{ID: "123"})
+	defer jm.untrack(&Job{ID: "123"})
+
+	readiness := jm.Readiness()
+
+	assert.False(t, readiness.Ready())
+	assert.Equal(t, WorkerHealth{Status: "unavailable", Running: 1, Capacity: 1}, readiness.Workers)
+}
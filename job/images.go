@@ -0,0 +1,68 @@
+package job
+
+import (
+	"regexp"
+	"strings"
+)
+
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ImageRef identifies the exact image used to execute a job step. The ID is
+// the local Docker image ID while the Digest is the registry's manifest
+// digest (which is not known for images which were never pulled).
+type ImageRef struct {
+	ID     string `json:"id"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// Returns true if the image name refers to a specific digest (e.g.
+// "busybox@sha256:...") rather than a tag.
+func isPinned(image string) bool {
+	return strings.Contains(image, "@")
+}
+
+func validateImage(v *validator, field, image string) {
+	if !isPinned(image) {
+		return
+	}
+
+	parts := strings.SplitN(image, "@", 2)
+	if len(parts[0]) == 0 || !digestPattern.MatchString(parts[1]) {
+		v.add(field, "must be in the form \"name@sha256:<digest>\"")
+	}
+}
+
+// Returns the image name without any tag or digest.
+func repositoryName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+
+	return image
+}
+
+// Returns the reference to the image which resolves to the specified
+// digest.
+func pinImage(image, digest string) string {
+	return repositoryName(image) + "@" + digest
+}
+
+// Returns an ImageRef for the inspected image, picking the digest recorded
+// for the image's own repository.
+func newImageRef(image string, info *imageInfo) *ImageRef {
+	ref := &ImageRef{ID: info.ID}
+	name := repositoryName(image)
+
+	for _, rd := range info.RepoDigests {
+		parts := strings.SplitN(rd, "@", 2)
+		if len(parts) == 2 && (parts[0] == name || len(ref.Digest) == 0) {
+			ref.Digest = parts[1]
+		}
+	}
+
+	return ref
+}
@@ -0,0 +1,43 @@
+package job
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	j := Job{
+		Steps: []JobStep{
+			{Source: "foo@" + digest},
+			{Source: "foo@sha256:abc"},
+			{Source: "@" + digest},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "steps[1].source", Message: "must be in the form \"name@sha256:<digest>\""},
+		{Field: "steps[2].source", Message: "must be in the form \"name@sha256:<digest>\""},
+	}, j.Validate())
+}
+
+func TestRepositoryName(t *testing.T) {
+	assert.Equal(t, "busybox", repositoryName("busybox"))
+	assert.Equal(t, "busybox", repositoryName("busybox:latest"))
+	assert.Equal(t, "busybox", repositoryName("busybox@sha256:abc"))
+	assert.Equal(t, "localhost:5000/foo", repositoryName("localhost:5000/foo"))
+	assert.Equal(t, "localhost:5000/foo", repositoryName("localhost:5000/foo:1.0"))
+}
+
+func TestNewImageRef(t *testing.T) {
+	info := &imageInfo{
+		ID:          "sha256:123",
+		RepoDigests: []string{"mirror/foo@sha256:aaa", "foo@sha256:bbb"},
+	}
+
+	assert.Equal(t, &ImageRef{ID: "sha256:123", Digest: "sha256:bbb"}, newImageRef("foo:latest", info))
+	assert.Equal(t, &ImageRef{ID: "sha256:123", Digest: "sha256:aaa"}, newImageRef("bar", info))
+	assert.Equal(t, &ImageRef{ID: "sha256:123"}, newImageRef("foo", &imageInfo{ID: "sha256:123"}))
+}
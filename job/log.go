@@ -0,0 +1,168 @@
+package job
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	streamStdout  = "stdout"
+	streamStderr  = "stderr"
+	streamSystem  = "system"
+	streamService = "service"
+
+	systemLogPrefix = "[system] "
+
+	// Marks the persisted entries which are encoded as JSON. The ASCII
+	// record separator is not written by the programs run in steps, so a
+	// line of their output is never mistaken for an entry.
+	logEntryPrefix = "\x1e"
+)
+
+// LogEntry is a single line of a job's log. Step is the number (starting at
+// 1) of the step which produced the line and is zero for lines which do not
+// belong to a step. Stream is "stdout" or "stderr" for the output of a step,
+// "system" for messages written by Dray itself (such as image pull progress)
+// or "service" for the output of the job's services.
+type LogEntry struct {
+	Time   time.Time `json:"ts"`
+	Step   int       `json:"step,omitempty"`
+	Stream string    `json:"stream,omitempty"`
+	Text   string    `json:"text"`
+}
+
+// JobLog represents the log output of a job. The Index field contains the
+// index which should be requested next in order to retrieve only the entries
+// which have been added since (whether or not the entries read so far were
+// selected by the LogQuery).
+type JobLog struct {
+	Index   int        `json:"index"`
+	Entries []LogEntry `json:"entries"`
+}
+
+// LogQuery selects entries from a job's log. Index is the position in the
+// log of the first entry to be considered. If set, Step and Stream restrict
+// the entries to those produced by a single step or written to a single
+// stream.
+type LogQuery struct {
+	Index  int
+	Step   int
+	Stream string
+}
+
+// String returns the entry as a line of plain text, with messages written by
+// Dray prefixed with "[system]".
+func (e LogEntry) String() string {
+	if e.Stream == streamSystem {
+		return systemLogPrefix + e.Text
+	}
+
+	return e.Text
+}
+
+// Lines returns the entries in the plain-text format of the original log API.
+// Messages written by Dray are left out, as that API only returned the output
+// of the steps.
+func (jl JobLog) Lines() []string {
+	lines := []string{}
+
+	for _, e := range jl.Entries {
+		if e.Stream != streamSystem {
+			lines = append(lines, e.Text)
+		}
+	}
+
+	return lines
+}
+
+// Validate checks that the query refers to a valid step and stream.
+func (q LogQuery) Validate() error {
+	v := &validator{}
+
+	if q.Index < 0 {
+		v.add("index", "must not be negative")
+	}
+
+	if q.Step < 0 {
+		v.add("step", "must not be negative")
+	}
+
+	switch q.Stream {
+	case "", streamStdout, streamStderr, streamSystem, streamService:
+	default:
+		v.add("stream", "must be %q, %q, %q or %q", streamStdout, streamStderr, streamSystem, streamService)
+	}
+
+	return v.err()
+}
+
+// Returns the entries of the log selected by the query, along with the index
+// which follows the last entry read.
+func (q LogQuery) filter(jl *JobLog) *JobLog {
+	filtered := &JobLog{Index: jl.Index, Entries: []LogEntry{}}
+
+	for _, e := range jl.Entries {
+		if (q.Step == 0 || e.Step == q.Step) && (len(q.Stream) == 0 || e.Stream == q.Stream) {
+			filtered.Entries = append(filtered.Entries, e)
+		}
+	}
+
+	return filtered
+}
+
+// Encodes an entry to be persisted by the JobRepository.
+func encodeLogEntry(e LogEntry) (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	return logEntryPrefix + string(b), nil
+}
+
+// Parses an entry persisted by the JobRepository. Entries written by older
+// versions of Dray are plain lines of text, even if they look like JSON.
+func decodeLogEntry(s string) LogEntry {
+	e := LogEntry{}
+
+	if !strings.HasPrefix(s, logEntryPrefix) || json.Unmarshal([]byte(s[len(logEntryPrefix):]), &e) != nil {
+		return LogEntry{Text: s}
+	}
+
+	return e
+}
+
+// logWriter is an io.Writer which appends the lines written to it to one of
+// a job's logs. Each write is expected to hold one or more complete lines.
+type logWriter struct {
+	jobID       string
+	step        int
+	stream      string
+	appendEntry func(jobID string, entry LogEntry) error
+}
+
+// Returns a writer for the messages which Dray itself adds to the log of the
+// job's current step (such as image pull progress).
+func systemLog(r JobRepository, job *Job) logWriter {
+	return logWriter{jobID: job.ID, step: job.StepsCompleted + 1, stream: streamSystem, appendEntry: r.AppendLogEntry}
+}
+
+// Returns a writer for the output of the job's services.
+func serviceLog(r JobRepository, jobID string) logWriter {
+	return logWriter{jobID: jobID, stream: streamService, appendEntry: r.AppendServiceLogEntry}
+}
+
+func (l logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSuffix(string(p), "\n"), "\n") {
+		if err := l.appendEntry(l.jobID, l.entry(line)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (l logWriter) entry(text string) LogEntry {
+	return LogEntry{Time: time.Now().UTC(), Step: l.step, Stream: l.stream, Text: text}
+}
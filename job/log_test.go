@@ -0,0 +1,77 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServiceLogWrite(t *testing.T) {
+	r := &mockRepository{}
+	r.On("AppendServiceLogEntry", "123", mock.AnythingOfType("job.LogEntry")).Return(nil)
+
+	n, err := serviceLog(r, "123").Write([]byte("db: one\ndb: two\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 16, n)
+	r.AssertNumberOfCalls(t, "AppendServiceLogEntry", 2)
+
+	entry := r.Calls[1].Arguments.Get(1).(LogEntry)
+	assert.Equal(t, "service", entry.Stream)
+	assert.Equal(t, "db: two", entry.Text)
+	assert.Equal(t, 0, entry.Step)
+}
+
+func TestSystemLogWrite(t *testing.T) {
+	r := &mockRepository{}
+	r.On("AppendLogEntry", "123", mock.AnythingOfType("job.LogEntry")).Return(nil)
+	j := &Job{ID: "123", StepsCompleted: 1}
+
+	_, err := systemLog(r, j).Write([]byte("Pulling image foo\n"))
+
+	assert.NoError(t, err)
+
+	entry := r.Calls[0].Arguments.Get(1).(LogEntry)
+	assert.Equal(t, LogEntry{Time: entry.Time, Step: 2, Stream: "system", Text: "Pulling image foo"}, entry)
+	assert.False(t, entry.Time.IsZero())
+}
+
+func TestLogEntryString(t *testing.T) {
+	assert.Equal(t, "foo", LogEntry{Stream: "stdout", Text: "foo"}.String())
+	assert.Equal(t, "[system] Pulling image foo", LogEntry{Stream: "system", Text: "Pulling image foo"}.String())
+}
+
+func TestJobLogLines(t *testing.T) {
+	jl := JobLog{Entries: []LogEntry{{Stream: "stderr", Text: "foo"}, {Stream: "system", Text: "bar"}, {Stream: "stdout", Text: "baz"}}}
+
+	assert.Equal(t, []string{"foo", "baz"}, jl.Lines())
+	assert.Equal(t, []string{}, JobLog{}.Lines())
+}
+
+func TestEncodeLogEntry(t *testing.T) {
+	entry := LogEntry{Time: time.Date(2015, time.March, 19, 10, 0, 0, 0, time.UTC), Step: 2, Stream: "stderr", Text: "foo"}
+
+	s, err := encodeLogEntry(entry)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1e"+`{"ts":"2015-03-19T10:00:00Z","step":2,"stream":"stderr","text":"foo"}`, s)
+	assert.Equal(t, entry, decodeLogEntry(s))
+}
+
+func TestDecodeLogEntry(t *testing.T) {
+	entry := decodeLogEntry("\x1e" + `{"ts":"2015-03-19T10:00:00Z","step":2,"stream":"stderr","text":"foo"}`)
+
+	assert.Equal(t, 2, entry.Step)
+	assert.Equal(t, "stderr", entry.Stream)
+	assert.Equal(t, "foo", entry.Text)
+	assert.Equal(t, 2015, entry.Time.Year())
+}
+
+func TestDecodeLogEntryLegacy(t *testing.T) {
+	assert.Equal(t, LogEntry{Text: "plain line"}, decodeLogEntry("plain line"))
+	assert.Equal(t, LogEntry{Text: "{not json"}, decodeLogEntry("{not json"))
+	assert.Equal(t, LogEntry{Text: `{"ts":"2015-03-19T10:00:00Z","text":"foo"}`}, decodeLogEntry(`{"ts":"2015-03-19T10:00:00Z","text":"foo"}`))
+	assert.Equal(t, LogEntry{Text: "\x1e{not json"}, decodeLogEntry("\x1e{not json"))
+}
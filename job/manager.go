@@ -3,11 +3,14 @@ package job
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -15,72 +18,610 @@ import (
 const (
 	fieldStatus         = "status"
 	fieldCompletedSteps = "completedSteps"
-
-	statusRunning  = "running"
-	statusError    = "error"
-	statusComplete = "complete"
+	fieldStepImage      = "stepImage.%d"
+	fieldStepStatus     = "stepStatus.%d"
+
+	statusRunning     = "running"
+	statusError       = "error"
+	statusComplete    = "complete"
+	statusCancelled   = "cancelled"
+	statusInterrupted = "interrupted"
+	statusPulling     = "pulling"
 )
 
+// NotRunningError is an error returned when attempting to cancel a job which
+// is not currently being executed.
+type NotRunningError string
+
+// Error returns the error string for the NotRunningError
+func (s NotRunningError) Error() string {
+	return fmt.Sprintf("Job with ID %s is not running", string(s))
+}
+
+// runningJob tracks a job which is being executed. Once the job has been
+// cancelled or interrupted, stopStatus holds the status it should finish with.
+type runningJob struct {
+	job        *Job
+	stopStatus string
+
+	// containerID is the container of the step being executed, once it has
+	// been started
+	containerID string
+}
+
+// Config holds the server-wide settings which are applied to every job
+// managed by the JobManager.
+type Config struct {
+	Resources ResourcePolicy
+	Volumes   VolumePolicy
+	Networks  NetworkPolicy
+	Registry  RegistryAuth
+
+	// WorkspacePath is the path at which a workspace volume is mounted into
+	// the steps of any job which does not specify its own workspace. No
+	// workspace is created for such jobs if it is empty.
+	WorkspacePath string
+
+	// MaxRunningJobs is the number of jobs which may execute at once before
+	// the server reports that it is not ready to accept more. There is no
+	// limit if it is zero.
+	MaxRunningJobs int
+
+	// Quotas limits the number of active jobs in each namespace.
+	Quotas NamespaceQuotas
+
+	// Policy holds the security rules which every job must follow. Jobs
+	// are checked when they are submitted and again before their containers
+	// are started.
+	Policy SecurityPolicy
+
+	// DockerTLS holds the certificates used to connect to the Docker daemon
+	// over TCP.
+	DockerTLS DockerTLS
+
+	// StopTimeout is the time a step's container is given to exit when its
+	// job is cancelled before it is killed. Defaults to 10 seconds.
+	StopTimeout time.Duration
+}
+
 type jobManager struct {
 	repository JobRepository
 	executor   JobStepExecutor
+	config     Config
+
+	mu       sync.Mutex
+	running  map[string]*runningJob
+	queued   map[string]bool
+	active   map[string]int
+	draining bool
+	wg       sync.WaitGroup
 }
 
 // NewJobManager returns a JobManager instance with connections to the
-// specified JobRepository and JobStepExecutor.
-func NewJobManager(r JobRepository, e JobStepExecutor) JobManager {
+// specified JobRepository and JobStepExecutor. The Config is applied to every
+// job which is created.
+func NewJobManager(r JobRepository, e JobStepExecutor, c Config) JobManager {
 	return &jobManager{
 		repository: r,
 		executor:   e,
+		config:     c,
 	}
 }
 
-func (jm *jobManager) ListAll() ([]Job, error) {
-	return jm.repository.All()
+func (jm *jobManager) ListAll(namespace string) ([]Job, error) {
+	return jm.repository.InNamespace(namespaceOrDefault(namespace)).All()
 }
 
-func (jm *jobManager) GetByID(jobID string) (*Job, error) {
-	return jm.repository.Get(jobID)
+func (jm *jobManager) GetByID(namespace, jobID string) (*Job, error) {
+	return jm.repository.InNamespace(namespaceOrDefault(namespace)).Get(jobID)
 }
 
 func (jm *jobManager) Create(job *Job) error {
-	return jm.repository.Create(job)
+	if jm.shuttingDown() {
+		return ShuttingDownError{}
+	}
+
+	for i := range job.Steps {
+		job.Steps[i].Status = ""
+		job.Steps[i].ResolvedImage = nil
+	}
+
+	if err := jm.prepare(job); err != nil {
+		return err
+	}
+
+	if err := jm.createJob(job); err != nil {
+		return err
+	}
+
+	jobsCreated.Inc()
+	jm.enqueue(job)
+	return nil
 }
 
 func (jm *jobManager) Execute(job *Job) error {
 	var capture io.Reader
 	var err error
 	status := statusRunning
+	start := time.Now()
 
-	jm.repository.Update(job.ID, fieldStatus, status)
+	if !jm.track(job) {
+		jm.release(job.Namespace)
+		jm.jobRepository(job).Update(job.ID, fieldStatus, statusInterrupted)
+		return ShuttingDownError{}
+	}
+	defer jm.untrack(job)
 
-	for i := range job.Steps {
+	jm.jobRepository(job).Update(job.ID, fieldStatus, status)
+
+	// The policy may have changed since the job was submitted
+	if err = jm.config.Policy.check(job); err != nil {
+		fmt.Fprintf(systemLog(jm.jobRepository(job), job), "Job rejected by security policy: %s\n", err)
+	} else {
+		err = jm.executor.Setup(job, serviceLog(jm.jobRepository(job), job.ID))
+	}
+	defer jm.tearDown(job)
+
+	// A resumed job starts with the persisted output of the last step
+	if err == nil && job.StepsCompleted > 0 {
+		capture, err = jm.stepOutput(job)
+	}
+
+	for err == nil && job.StepsCompleted < len(job.Steps) {
+		if len(jm.stopStatus(job)) > 0 {
+			break
+		}
+
+		stepStart := time.Now()
 		capture, err = jm.executeStep(job, capture)
 
-		if err != nil {
+		if stopStatus := jm.stopStatus(job); err != nil && len(stopStatus) > 0 {
+			jm.finishStep(job, stopStatus, stepStart)
+			break
+		} else if err != nil {
+			jm.finishStep(job, statusError, stepStart)
 			break
 		}
 
+		jm.finishStep(job, statusComplete, stepStart)
+
 		job.StepsCompleted++
-		jm.repository.Update(job.ID, fieldCompletedSteps, strconv.Itoa(i+1))
+
+		if job.StepsCompleted < len(job.Steps) {
+			if capture, err = jm.saveOutput(job, capture); err != nil {
+				break
+			}
+		}
+
+		jm.jobRepository(job).Update(job.ID, fieldCompletedSteps, strconv.Itoa(job.StepsCompleted))
 	}
 
-	if err != nil {
+	if stopStatus := jm.stopStatus(job); len(stopStatus) > 0 {
+		status = stopStatus
+	} else if err != nil {
 		status = statusError
 	} else {
 		status = statusComplete
 	}
 
-	jm.repository.Update(job.ID, fieldStatus, status)
+	jm.jobRepository(job).Update(job.ID, fieldStatus, status)
+	jobsFinished.Inc(status)
+	jobDuration.ObserveSince(start, status)
 	return err
 }
 
-func (jm *jobManager) GetLog(job *Job, index int) (*JobLog, error) {
-	return jm.repository.GetJobLog(job.ID, index)
+func (jm *jobManager) Cancel(job *Job) error {
+	containerID, ok := jm.stop(job.ID, statusCancelled)
+	if !ok {
+		return NotRunningError(job.ID)
+	}
+
+	log.Infof("Cancelling job %s", job.ID)
+	return jm.stopContainer(containerID)
+}
+
+func (jm *jobManager) GetLog(job *Job, q LogQuery) (*JobLog, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	jl, err := jm.jobRepository(job).GetJobLog(job.ID, q.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.filter(jl), nil
+}
+
+func (jm *jobManager) GetServiceLog(job *Job, q LogQuery) (*JobLog, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	jl, err := jm.jobRepository(job).GetServiceLog(job.ID, q.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.filter(jl), nil
 }
 
 func (jm *jobManager) Delete(job *Job) error {
-	return jm.repository.Delete(job.ID)
+	return jm.jobRepository(job).Delete(job.ID)
+}
+
+func (jm *jobManager) Rerun(job *Job) (*Job, error) {
+	if jm.shuttingDown() {
+		return nil, ShuttingDownError{}
+	}
+
+	rerun, err := derive(job)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jm.prepare(rerun); err != nil {
+		return nil, err
+	}
+
+	if err := jm.createJob(rerun); err != nil {
+		return nil, err
+	}
+
+	jobsCreated.Inc()
+	jm.enqueue(rerun)
+	return rerun, nil
+}
+
+func (jm *jobManager) Resume(job *Job) (*Job, error) {
+	if jm.shuttingDown() {
+		return nil, ShuttingDownError{}
+	}
+
+	if job.active() || job.Status == statusComplete {
+		return nil, NewValidationError("status", fmt.Sprintf("job cannot be resumed while its status is %q", job.Status))
+	}
+
+	resumed, err := derive(job)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jm.prepare(resumed); err != nil {
+		return nil, err
+	}
+
+	resumed.StepsCompleted = job.StepsCompleted
+
+	output, err := jm.jobRepository(job).GetStepOutput(job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jm.createJob(resumed); err != nil {
+		return nil, err
+	}
+
+	// The job cannot be resumed without the output of its last step
+	if err := jm.jobRepository(resumed).SaveStepOutput(resumed.ID, output); err != nil {
+		jm.discardJob(resumed)
+		return nil, err
+	}
+
+	jobsCreated.Inc()
+	jm.enqueue(resumed)
+	return resumed, nil
+}
+
+func (jm *jobManager) ListTemplates() ([]JobTemplate, error) {
+	return jm.repository.AllTemplates()
+}
+
+func (jm *jobManager) GetTemplate(name string) (*JobTemplate, error) {
+	return jm.repository.GetTemplate(name)
+}
+
+func (jm *jobManager) CreateTemplate(template *JobTemplate) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+
+	return jm.repository.SaveTemplate(template)
+}
+
+func (jm *jobManager) DeleteTemplate(template *JobTemplate) error {
+	return jm.repository.DeleteTemplate(template.Name)
+}
+
+func (jm *jobManager) ListSchedules() ([]Schedule, error) {
+	return jm.repository.AllSchedules()
+}
+
+func (jm *jobManager) GetSchedule(scheduleID string) (*Schedule, error) {
+	return jm.repository.GetSchedule(scheduleID)
+}
+
+func (jm *jobManager) CreateSchedule(schedule *Schedule) error {
+	schedule.ID = ""
+	schedule.LastRun = nil
+	schedule.LastJobID = ""
+
+	return jm.saveSchedule(schedule)
+}
+
+func (jm *jobManager) UpdateSchedule(schedule *Schedule) error {
+	existing, err := jm.repository.GetSchedule(schedule.ID)
+	if err != nil {
+		return err
+	}
+
+	schedule.LastRun = existing.LastRun
+	schedule.LastJobID = existing.LastJobID
+
+	return jm.saveSchedule(schedule)
+}
+
+func (jm *jobManager) DeleteSchedule(schedule *Schedule) error {
+	return jm.repository.DeleteSchedule(schedule.ID)
+}
+
+// Validates the schedule and calculates its next run time before persisting
+// it.
+func (jm *jobManager) saveSchedule(schedule *Schedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
+	next := schedule.nextRunAfter(time.Now())
+	schedule.NextRun = &next
+
+	return jm.repository.SaveSchedule(schedule)
+}
+
+// Persists the output of the step which has just completed so that the job
+// can later be resumed from the following step. A new reader for the output
+// is returned.
+func (jm *jobManager) saveOutput(job *Job, output io.Reader) (io.Reader, error) {
+	b, err := ioutil.ReadAll(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jm.jobRepository(job).SaveStepOutput(job.ID, b); err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+func (jm *jobManager) stepOutput(job *Job) (io.Reader, error) {
+	b, err := jm.jobRepository(job).GetStepOutput(job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// Replaces the tags of the job's images with the digests they currently
+// refer to.
+func (jm *jobManager) lockImages(job *Job) error {
+	v := &validator{}
+
+	lock := func(field string, image *string) {
+		pinned, err := jm.executor.ResolveImage(job, *image)
+		if err != nil {
+			v.add(field, "cannot be resolved: %s", err)
+			return
+		}
+
+		*image = pinned
+	}
+
+	for i := range job.Steps {
+		lock(fmt.Sprintf("steps[%d].source", i), &job.Steps[i].Source)
+	}
+
+	for i := range job.Services {
+		lock(fmt.Sprintf("services[%d].source", i), &job.Services[i].Source)
+	}
+
+	return v.err()
+}
+
+// Records the image used to execute the current step.
+func (jm *jobManager) saveStepImage(job *Job, image *ImageRef) {
+	b, err := json.Marshal(image)
+	if err == nil {
+		err = jm.jobRepository(job).Update(job.ID, fmt.Sprintf(fieldStepImage, job.StepsCompleted), string(b))
+	}
+
+	if err != nil {
+		log.Errorf("Error recording image for job %s: %s", job.ID, err)
+	}
+}
+
+// Records the status of the current step.
+func (jm *jobManager) setStepStatus(job *Job, status string) {
+	job.currentStep().Status = status
+	jm.jobRepository(job).Update(job.ID, fmt.Sprintf(fieldStepStatus, job.StepsCompleted), status)
+}
+
+// Sets the final status of the current step and records how long it took.
+func (jm *jobManager) finishStep(job *Job, status string, start time.Time) {
+	jm.setStepStatus(job, status)
+	stepDuration.ObserveSince(start, status)
+}
+
+// Records the job as running, unless the JobManager is shutting down in which
+// case false is returned and the job must not be executed.
+func (jm *jobManager) track(job *Job) bool {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if jm.queued[job.ID] {
+		delete(jm.queued, job.ID)
+		jobsQueued.Dec()
+	}
+
+	if jm.draining {
+		return false
+	}
+
+	if jm.running == nil {
+		jm.running = map[string]*runningJob{}
+	}
+
+	jm.running[job.ID] = &runningJob{job: job}
+	jm.wg.Add(1)
+	jobsRunning.Inc()
+	return true
+}
+
+func (jm *jobManager) untrack(job *Job) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	delete(jm.running, job.ID)
+	jm.wg.Done()
+	jm.releaseLocked(job.Namespace)
+	jobsRunning.Dec()
+}
+
+// Records a newly created job as waiting to be executed.
+func (jm *jobManager) enqueue(job *Job) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if jm.queued == nil {
+		jm.queued = map[string]bool{}
+	}
+
+	jm.queued[job.ID] = true
+	jobsQueued.Inc()
+}
+
+// Applies the server's defaults to a new job, and checks it against the
+// server's limits and security policy. The job's images are pinned to their
+// digests if it asks for them to be locked.
+func (jm *jobManager) prepare(job *Job) error {
+	job.Namespace = namespaceOrDefault(job.Namespace)
+	jm.config.Resources.applyDefaults(job)
+	if len(job.Workspace) == 0 {
+		job.Workspace = jm.config.WorkspacePath
+	}
+
+	v := &validator{}
+	v.nest("", job.Validate())
+	jm.config.Resources.validate(v, job)
+	jm.config.Volumes.validate(v, job)
+	jm.config.Networks.validate(v, job)
+	jm.config.Registry.validate(v, job)
+
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	if err := jm.config.Policy.check(job); err != nil {
+		return err
+	}
+
+	if job.LockImages {
+		return jm.lockImages(job)
+	}
+
+	return nil
+}
+
+// Persists a new job within the quota of its namespace.
+func (jm *jobManager) createJob(job *Job) error {
+	if err := jm.reserve(job.Namespace); err != nil {
+		return err
+	}
+
+	if err := jm.jobRepository(job).Create(job); err != nil {
+		jm.release(job.Namespace)
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a job which has been created but cannot be executed, and releases
+// its place in the namespace's quota.
+func (jm *jobManager) discardJob(job *Job) {
+	if err := jm.jobRepository(job).Delete(job.ID); err != nil {
+		log.Errorf("Error deleting job %s: %s", job.ID, err)
+	}
+
+	jm.release(job.Namespace)
+}
+
+func (jm *jobManager) tearDown(job *Job) {
+	if err := jm.executor.TearDown(job); err != nil {
+		log.Errorf("Error tearing down job %s: %s", job.ID, err)
+	}
+}
+
+// Marks a running job as stopped so that it finishes with the specified
+// status (unless it has already been stopped), returning the container of
+// its current step if one has been started. Returns false if the job is not
+// running.
+func (jm *jobManager) stop(jobID, status string) (string, bool) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	rj, ok := jm.running[jobID]
+	if !ok {
+		return "", false
+	}
+
+	if len(rj.stopStatus) == 0 {
+		rj.stopStatus = status
+	}
+
+	return rj.containerID, true
+}
+
+// Records the container of the step being executed (or clears it once the
+// step has finished) so that stopping the job stops the container. Returns
+// true if the job has already been stopped, in which case the container was
+// started too late to be stopped with it.
+func (jm *jobManager) setContainer(job *Job, containerID string) bool {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	rj, ok := jm.running[job.ID]
+	if !ok {
+		return false
+	}
+
+	rj.containerID = containerID
+	return len(rj.stopStatus) > 0
+}
+
+// Stops the container of a stopped job's current step. There is nothing to
+// stop if the step's container has not been started yet, as Execute stops
+// it once it has.
+func (jm *jobManager) stopContainer(containerID string) error {
+	if len(containerID) == 0 {
+		return nil
+	}
+
+	return jm.executor.Stop(containerID)
+}
+
+// Returns the status with which a stopped job should finish, or an empty
+// string if the job has not been stopped.
+func (jm *jobManager) stopStatus(job *Job) string {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if rj, ok := jm.running[job.ID]; ok {
+		return rj.stopStatus
+	}
+
+	return ""
 }
 
 func (jm *jobManager) executeStep(job *Job, stdIn io.Reader) (io.Reader, error) {
@@ -111,22 +652,47 @@ func (jm *jobManager) executeStep(job *Job, stdIn io.Reader) (io.Reader, error)
 		}
 	}
 
+	jm.setStepStatus(job, statusPulling)
+
+	if err := jm.executor.Pull(job, systemLog(jm.jobRepository(job), job)); err != nil {
+		return nil, err
+	}
+
+	if step.ResolvedImage != nil {
+		jm.saveStepImage(job, step.ResolvedImage)
+	}
+
+	// The job may have been stopped while its image was being pulled
+	if len(jm.stopStatus(job)) > 0 {
+		return nil, fmt.Errorf("Job with ID %s was stopped before step %d started", job.ID, job.StepsCompleted+1)
+	}
+
+	jm.setStepStatus(job, statusRunning)
+
 	err := jm.executor.Start(job, stdIn, stdOutWriter, stdErrWriter)
 	if err != nil {
 		return nil, err
 	}
 	defer jm.executor.CleanUp(job)
+	defer jm.setContainer(job, "")
+
+	// The job may have been stopped while its container was being created
+	if jm.setContainer(job, step.id) {
+		if err := jm.stopContainer(step.id); err != nil {
+			log.Errorf("Error stopping job %s: %s", job.ID, err)
+		}
+	}
 
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		jm.capture(job, stdOutReader, outBuffer)
+		jm.capture(job, streamStdout, stdOutReader, outBuffer)
 	}()
 
 	go func() {
 		defer wg.Done()
-		jm.capture(job, stdErrReader, errBuffer)
+		jm.capture(job, streamStderr, stdErrReader, errBuffer)
 	}()
 
 	wg.Wait()
@@ -148,7 +714,29 @@ func (jm *jobManager) executeStep(job *Job, stdIn io.Reader) (io.Reader, error)
 	return stepOutput, nil
 }
 
-func (jm *jobManager) capture(job *Job, r io.Reader, w io.Writer) {
+// Returns a copy of the job definition, linked to the original job, which can
+// be submitted as a new job.
+func derive(job *Job) (*Job, error) {
+	if len(job.Steps) == 0 {
+		return nil, NewValidationError("", fmt.Sprintf("definition of job %s is not available", job.ID))
+	}
+
+	j := *job
+	j.ID = ""
+	j.Status = ""
+	j.StepsCompleted = 0
+	j.ParentID = job.ID
+	j.Steps = append([]JobStep{}, job.Steps...)
+
+	for i := range j.Steps {
+		j.Steps[i].Status = ""
+		j.Steps[i].ResolvedImage = nil
+	}
+
+	return &j, nil
+}
+
+func (jm *jobManager) capture(job *Job, stream string, r io.Reader, w io.Writer) {
 	step := job.currentStep()
 	scanner := bufio.NewScanner(r)
 	capture := !step.usesDelimitedOutput()
@@ -157,7 +745,12 @@ func (jm *jobManager) capture(job *Job, r io.Reader, w io.Writer) {
 		line := scanner.Text()
 
 		log.Debugf(line)
-		jm.repository.AppendLogLine(job.ID, line)
+		jm.jobRepository(job).AppendLogEntry(job.ID, LogEntry{
+			Time:   time.Now().UTC(),
+			Step:   job.StepsCompleted + 1,
+			Stream: stream,
+			Text:   line,
+		})
 
 		if w != nil {
 			if step.usesDelimitedOutput() && line == step.EndDelimiter {
@@ -2,7 +2,11 @@ package job
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -50,10 +54,11 @@ func (suite *JobManagerTestSuite) TestListAll() {
 
 	suite.r.On("All").Return(jobs, suite.err)
 
-	resultJobs, resultErr := suite.jm.ListAll()
+	resultJobs, resultErr := suite.jm.ListAll("team-a")
 
 	suite.Equal(jobs, resultJobs)
 	suite.Equal(suite.err, resultErr)
+	suite.Equal([]string{"team-a"}, suite.r.namespaces)
 }
 
 func (suite *JobManagerTestSuite) TestGetByID() {
@@ -61,10 +66,11 @@ func (suite *JobManagerTestSuite) TestGetByID() {
 
 	suite.r.On("Get", id).Return(suite.job, suite.err)
 
-	resultJob, resultErr := suite.jm.GetByID(id)
+	resultJob, resultErr := suite.jm.GetByID("", id)
 
 	suite.Equal(suite.job, resultJob)
 	suite.Equal(suite.err, resultErr)
+	suite.Equal([]string{DefaultNamespace}, suite.r.namespaces)
 }
 
 func (suite *JobManagerTestSuite) TestCreate() {
@@ -75,6 +81,165 @@ func (suite *JobManagerTestSuite) TestCreate() {
 	suite.Equal(suite.err, resultErr)
 }
 
+func (suite *JobManagerTestSuite) TestCreateQueuesJob() {
+	suite.r.On("Create", suite.job).Return(nil)
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.NoError(resultErr)
+	suite.True(suite.jm.queued[suite.job.ID])
+}
+
+func (suite *JobManagerTestSuite) TestCreateNamespace() {
+	suite.job.Namespace = "team-a"
+	suite.r.On("Create", suite.job).Return(nil)
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal([]string{"team-a"}, suite.r.namespaces)
+	suite.Equal(map[string]int{"team-a": 1}, suite.jm.active)
+}
+
+func (suite *JobManagerTestSuite) TestCreateDefaultNamespace() {
+	suite.r.On("Create", suite.job).Return(nil)
+
+	suite.jm.Create(suite.job)
+
+	suite.Equal(DefaultNamespace, suite.job.Namespace)
+}
+
+func (suite *JobManagerTestSuite) TestCreateInvalidNamespace() {
+	suite.job.Namespace = "Team A"
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(NewValidationError("namespace", "must consist of lowercase letters, digits and hyphens"), resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateQuotaExceeded() {
+	suite.jm.config.Quotas = NamespaceQuotas{Default: 5, Limits: map[string]int{"team-a": 1}}
+	suite.jm.active = map[string]int{"team-a": 1, DefaultNamespace: 4}
+	suite.job.Namespace = "team-a"
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(QuotaExceededError{Namespace: "team-a", Limit: 1}, resultErr)
+	suite.EqualError(resultErr, "Namespace team-a has reached its limit of 1 active jobs")
+	suite.r.Mock.AssertNotCalled(suite.T(), "Create", suite.job)
+}
+
+func (suite *JobManagerTestSuite) TestCreateReleasesQuotaOnError() {
+	suite.jm.config.Quotas = NamespaceQuotas{Default: 1}
+	suite.r.On("Create", suite.job).Return(suite.err)
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(suite.err, resultErr)
+	suite.Empty(suite.jm.active)
+}
+
+func (suite *JobManagerTestSuite) TestCreatePolicyViolation() {
+	suite.jm.config.Policy = SecurityPolicy{Forbidden: []string{"root"}, MaxSteps: 1}
+	suite.jm.config.Resources = ResourcePolicy{Defaults: Resources{Memory: 1024}}
+	suite.jm.config.Policy.RequiredResources = []string{"memory"}
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(PolicyViolationError{{Field: "steps[0].user", Message: "steps must run as a non-root user"}}, resultErr)
+	suite.Empty(suite.jm.active)
+}
+
+func (suite *JobManagerTestSuite) TestCreateInvalid() {
+	resultErr := suite.jm.Create(&Job{})
+
+	suite.Equal(NewValidationError("steps", "required"), resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateAppliesResourceDefaults() {
+	suite.jm.config.Resources = ResourcePolicy{
+		Defaults: Resources{Memory: 1024},
+		Maximums: Resources{CPUShares: 512},
+	}
+	suite.r.On("Create", suite.job).Return(nil)
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal(&Resources{Memory: 1024, CPUShares: 512}, suite.job.Steps[0].Resources)
+}
+
+func (suite *JobManagerTestSuite) TestCreateExceedsResourceMaximum() {
+	suite.jm.config.Resources = ResourcePolicy{Maximums: Resources{Memory: 1024}}
+	suite.job.Steps[0].Resources = &Resources{Memory: 2048, CPUShares: -1}
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(ValidationError{
+		{Field: "steps[0].resources.cpuShares", Message: "must not be negative"},
+		{Field: "steps[0].resources.memory", Message: "must not exceed 1024"},
+	}, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateWorkspaceAndVolumes() {
+	suite.jm.config.WorkspacePath = "/workspace"
+	suite.jm.config.Volumes = VolumePolicy{Allowed: []string{"data"}}
+	suite.job.Steps[0].Volumes = []Volume{{Source: "data", Target: "/data"}, {Source: "/etc", Target: "/etc"}}
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal("/workspace", suite.job.Workspace)
+	suite.Equal(NewValidationError("steps[0].volumes[1].source", "/etc is not an allowed volume"), resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateNetworkNotAllowed() {
+	suite.jm.config.Networks = NetworkPolicy{Allowed: []string{"ci"}}
+	suite.job.Steps[0].Network = "host"
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(NewValidationError("steps[0].network", "host is not an allowed network"), resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateLockImages() {
+	suite.job.LockImages = true
+	suite.job.Steps[0].ResolvedImage = &ImageRef{ID: "bogus"}
+	suite.job.Services = []Service{{Name: "db", Source: "postgres:9"}}
+
+	suite.e.On("ResolveImage", suite.job, "foo/bar").Return("foo/bar@sha256:abc", nil)
+	suite.e.On("ResolveImage", suite.job, "postgres:9").Return("postgres@sha256:def", nil)
+	suite.r.On("Create", suite.job).Return(nil)
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal("foo/bar@sha256:abc", suite.job.Steps[0].Source)
+	suite.Nil(suite.job.Steps[0].ResolvedImage)
+	suite.Equal("postgres@sha256:def", suite.job.Services[0].Source)
+}
+
+func (suite *JobManagerTestSuite) TestCreateLockImagesError() {
+	suite.job.LockImages = true
+
+	suite.e.On("ResolveImage", suite.job, "foo/bar").Return("", suite.err)
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(NewValidationError("steps[0].source", "cannot be resolved: oops"), resultErr)
+	suite.r.Mock.AssertNotCalled(suite.T(), "Create", suite.job)
+}
+
+func (suite *JobManagerTestSuite) TestCreateUnknownRegistryCredentials() {
+	suite.jm.config.Registry = RegistryAuth{
+		Credentials: map[string]map[string]RegistryCredential{"team-a": {}},
+	}
+	suite.job.RegistryCredentials = "team-b"
+
+	resultErr := suite.jm.Create(suite.job)
+
+	suite.Equal(NewValidationError("registryCredentials", "team-b is not a configured set of registry credentials"), resultErr)
+}
+
 func (suite *JobManagerTestSuite) TestDelete() {
 	suite.r.On("Delete", suite.job.ID).Return(suite.err)
 
@@ -84,18 +249,308 @@ func (suite *JobManagerTestSuite) TestDelete() {
 }
 
 func (suite *JobManagerTestSuite) TestGetLog() {
-	index := 3
-	jobLog := &JobLog{Index: 3}
+	jobLog := &JobLog{Index: 5, Entries: []LogEntry{{Step: 1, Stream: "stdout", Text: "foo"}}}
 
-	suite.r.On("GetJobLog", suite.job.ID, index).Return(jobLog, suite.err)
+	suite.r.On("GetJobLog", suite.job.ID, 4).Return(jobLog, nil)
 
-	resultLog, resultErr := suite.jm.GetLog(suite.job, index)
+	resultLog, resultErr := suite.jm.GetLog(suite.job, LogQuery{Index: 4})
 
+	suite.NoError(resultErr)
 	suite.Equal(jobLog, resultLog)
+}
+
+func (suite *JobManagerTestSuite) TestGetLogFiltered() {
+	jobLog := &JobLog{
+		Index: 7,
+		Entries: []LogEntry{
+			{Step: 1, Stream: "stdout", Text: "foo"},
+			{Step: 2, Stream: "system", Text: "Pulling image"},
+			{Step: 2, Stream: "stdout", Text: "bar"},
+			{Step: 2, Stream: "stderr", Text: "baz"},
+		},
+	}
+
+	suite.r.On("GetJobLog", suite.job.ID, 3).Return(jobLog, nil)
+
+	resultLog, resultErr := suite.jm.GetLog(suite.job, LogQuery{Index: 3, Step: 2, Stream: "stderr"})
+
+	suite.NoError(resultErr)
+	suite.Equal(&JobLog{Index: 7, Entries: []LogEntry{{Step: 2, Stream: "stderr", Text: "baz"}}}, resultLog)
+}
+
+func (suite *JobManagerTestSuite) TestGetLogInvalidQuery() {
+	_, resultErr := suite.jm.GetLog(suite.job, LogQuery{Step: -1, Stream: "foo"})
+
+	suite.IsType(ValidationError{}, resultErr)
+	suite.EqualError(resultErr, "step: must not be negative, stream: must be \"stdout\", \"stderr\", \"system\" or \"service\"")
+	suite.r.AssertNotCalled(suite.T(), "GetJobLog", suite.job.ID, 0)
+}
+
+func (suite *JobManagerTestSuite) TestGetLogError() {
+	suite.r.On("GetJobLog", suite.job.ID, 0).Return((*JobLog)(nil), suite.err)
+
+	_, resultErr := suite.jm.GetLog(suite.job, LogQuery{})
+
+	suite.Equal(suite.err, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestRerun() {
+	suite.job.Status = "error"
+	suite.job.StepsCompleted = 1
+
+	suite.r.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	rerun, resultErr := suite.jm.Rerun(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal(suite.job.ID, rerun.ParentID)
+	suite.Equal(suite.job.Steps, rerun.Steps)
+	suite.Equal(0, rerun.StepsCompleted)
+	suite.Empty(rerun.Status)
+}
+
+func (suite *JobManagerTestSuite) TestRerunPolicyViolation() {
+	suite.job.Status = "error"
+	suite.jm.config.Policy = SecurityPolicy{AllowedImages: []string{"centurylink/*"}}
+
+	_, resultErr := suite.jm.Rerun(suite.job)
+
+	suite.Equal(PolicyViolationError{{Field: "steps[0].source", Message: "foo/bar is not an allowed image"}}, resultErr)
+	suite.r.Mock.AssertNotCalled(suite.T(), "Create", mock.Anything)
+}
+
+func (suite *JobManagerTestSuite) TestRerunQueuesJob() {
+	suite.job.Status = "error"
+	suite.jm.config.Resources = ResourcePolicy{Defaults: Resources{Memory: 1024}}
+
+	suite.r.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	rerun, resultErr := suite.jm.Rerun(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal(&Resources{Memory: 1024}, rerun.Steps[0].Resources)
+	suite.True(suite.jm.queued[rerun.ID])
+	suite.Equal(map[string]int{DefaultNamespace: 1}, suite.jm.active)
+}
+
+func (suite *JobManagerTestSuite) TestRerunExceedsLimits() {
+	suite.job.Status = "error"
+	suite.job.Steps[0].Network = "host"
+	suite.jm.config.Networks = NetworkPolicy{Allowed: []string{"ci"}}
+
+	_, resultErr := suite.jm.Rerun(suite.job)
+
+	suite.Equal(NewValidationError("steps[0].network", "host is not an allowed network"), resultErr)
+	suite.r.Mock.AssertNotCalled(suite.T(), "Create", mock.Anything)
+}
+
+func (suite *JobManagerTestSuite) TestRerunMissingDefinition() {
+	_, resultErr := suite.jm.Rerun(&Job{ID: "123"})
+
+	suite.IsType(ValidationError{}, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestResume() {
+	output := []byte("foo")
+	suite.job.Status = "error"
+	suite.job.StepsCompleted = 1
+
+	suite.r.On("GetStepOutput", suite.job.ID).Return(output, nil)
+	suite.r.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.r.On("SaveStepOutput", "", output).Return(nil)
+
+	resumed, resultErr := suite.jm.Resume(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal(suite.job.ID, resumed.ParentID)
+	suite.Equal(1, resumed.StepsCompleted)
+}
+
+func (suite *JobManagerTestSuite) TestResumeExceedsLimits() {
+	suite.job.Status = "error"
+	suite.jm.config.Resources = ResourcePolicy{Maximums: Resources{Memory: 1024}}
+	suite.job.Steps[0].Resources = &Resources{Memory: 2048}
+
+	_, resultErr := suite.jm.Resume(suite.job)
+
+	suite.Equal(NewValidationError("steps[0].resources.memory", "must not exceed 1024"), resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestResumeSaveOutputError() {
+	output := []byte("foo")
+	suite.job.Status = "error"
+	suite.job.StepsCompleted = 1
+	suite.jm.config.Quotas = NamespaceQuotas{Default: 1}
+
+	suite.r.On("GetStepOutput", suite.job.ID).Return(output, nil)
+	suite.r.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.r.On("SaveStepOutput", "", output).Return(suite.err)
+	suite.r.On("Delete", "").Return(nil)
+
+	_, resultErr := suite.jm.Resume(suite.job)
+
+	suite.Equal(suite.err, resultErr)
+	suite.Empty(suite.jm.active)
+	suite.Empty(suite.jm.queued)
+}
+
+func (suite *JobManagerTestSuite) TestResumeComplete() {
+	suite.job.Status = "complete"
+
+	_, resultErr := suite.jm.Resume(suite.job)
+
+	suite.IsType(ValidationError{}, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestListTemplates() {
+	templates := []JobTemplate{{Name: "foo"}}
+
+	suite.r.On("AllTemplates").Return(templates, suite.err)
+
+	resultTemplates, resultErr := suite.jm.ListTemplates()
+
+	suite.Equal(templates, resultTemplates)
+	suite.Equal(suite.err, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestGetTemplate() {
+	template := &JobTemplate{Name: "foo"}
+
+	suite.r.On("GetTemplate", "foo").Return(template, suite.err)
+
+	resultTemplate, resultErr := suite.jm.GetTemplate("foo")
+
+	suite.Equal(template, resultTemplate)
+	suite.Equal(suite.err, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateTemplate() {
+	template := &JobTemplate{Name: "foo", Job: *suite.job}
+
+	suite.r.On("SaveTemplate", template).Return(suite.err)
+
+	resultErr := suite.jm.CreateTemplate(template)
+
 	suite.Equal(suite.err, resultErr)
 }
 
+func (suite *JobManagerTestSuite) TestCreateTemplateInvalid() {
+	template := &JobTemplate{}
+
+	resultErr := suite.jm.CreateTemplate(template)
+
+	suite.IsType(ValidationError{}, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestDeleteTemplate() {
+	template := &JobTemplate{Name: "foo"}
+
+	suite.r.On("DeleteTemplate", "foo").Return(suite.err)
+
+	resultErr := suite.jm.DeleteTemplate(template)
+
+	suite.Equal(suite.err, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCreateSchedule() {
+	schedule := &Schedule{ID: "abc", Cron: "@daily", Job: suite.job, LastJobID: "123"}
+
+	suite.r.On("SaveSchedule", schedule).Return(suite.err)
+
+	resultErr := suite.jm.CreateSchedule(schedule)
+
+	suite.Equal(suite.err, resultErr)
+	suite.Empty(schedule.ID)
+	suite.Empty(schedule.LastJobID)
+	suite.NotNil(schedule.NextRun)
+}
+
+func (suite *JobManagerTestSuite) TestCreateScheduleInvalid() {
+	schedule := &Schedule{Cron: "* * *", Job: suite.job}
+
+	resultErr := suite.jm.CreateSchedule(schedule)
+
+	suite.IsType(ValidationError{}, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestUpdateSchedule() {
+	lastRun := time.Now()
+	existing := &Schedule{ID: "abc", LastRun: &lastRun, LastJobID: "123"}
+	schedule := &Schedule{ID: "abc", Cron: "@hourly", Template: "foo"}
+
+	suite.r.On("GetSchedule", "abc").Return(existing, nil)
+	suite.r.On("SaveSchedule", schedule).Return(nil)
+
+	resultErr := suite.jm.UpdateSchedule(schedule)
+
+	suite.NoError(resultErr)
+	suite.Equal(&lastRun, schedule.LastRun)
+	suite.Equal("123", schedule.LastJobID)
+}
+
+func (suite *JobManagerTestSuite) TestDeleteSchedule() {
+	suite.r.On("DeleteSchedule", "abc").Return(suite.err)
+
+	resultErr := suite.jm.DeleteSchedule(&Schedule{ID: "abc"})
+
+	suite.Equal(suite.err, resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCancelNotRunning() {
+	resultErr := suite.jm.Cancel(suite.job)
+
+	suite.Equal(NotRunningError(suite.job.ID), resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestCancelRunning() {
+	suite.jm.track(suite.job)
+	suite.jm.setContainer(suite.job, "abc123")
+	suite.e.On("Stop", "abc123").Return(nil)
+
+	resultErr := suite.jm.Cancel(&Job{ID: suite.job.ID})
+
+	suite.NoError(resultErr)
+	suite.Equal("cancelled", suite.jm.stopStatus(suite.job))
+}
+
+func (suite *JobManagerTestSuite) TestCancelBeforeContainerStarted() {
+	suite.jm.track(suite.job)
+
+	resultErr := suite.jm.Cancel(&Job{ID: suite.job.ID})
+
+	suite.NoError(resultErr)
+	suite.e.AssertNotCalled(suite.T(), "Stop", mock.Anything)
+
+	// The container is stopped by Execute as soon as it has started
+	suite.True(suite.jm.setContainer(suite.job, "abc123"))
+}
+
+func (suite *JobManagerTestSuite) TestExecuteCancelledWhileStarting() {
+	suite.e.onStart = func(job *Job) {
+		job.currentStep().id = "abc123"
+		suite.jm.Cancel(job)
+	}
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
+	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.e.On("Stop", "abc123").Return(nil)
+	suite.e.On("Inspect", suite.job).Return(suite.err)
+	suite.e.On("CleanUp", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "cancelled").Return(nil)
+
+	suite.expectStepStatus(0, "pulling", "running", "cancelled")
+	suite.jm.Execute(suite.job)
+
+	suite.e.AssertNumberOfCalls(suite.T(), "Stop", 1)
+}
+
 func (suite *JobManagerTestSuite) TestExecuteSuccess() {
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
 	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	suite.e.On("Inspect", suite.job).Return(nil)
 	suite.e.On("CleanUp", suite.job).Return(nil)
@@ -104,17 +559,42 @@ func (suite *JobManagerTestSuite) TestExecuteSuccess() {
 	suite.r.On("Update", suite.job.ID, "completedSteps", "1").Return(nil)
 	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
 
+	suite.expectStepStatus(0, "pulling", "running", "complete")
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.Nil(resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestExecuteRecordsImage() {
+	suite.e.image = &ImageRef{ID: "xyz789", Digest: "sha256:abc"}
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
+	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.e.On("Inspect", suite.job).Return(nil)
+	suite.e.On("CleanUp", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("Update", suite.job.ID, "stepImage.0", `{"id":"xyz789","digest":"sha256:abc"}`).Return(nil)
+	suite.r.On("Update", suite.job.ID, "completedSteps", "1").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
+
+	suite.expectStepStatus(0, "pulling", "running", "complete")
 	resultErr := suite.jm.Execute(suite.job)
 
 	suite.Nil(resultErr)
 }
 
 func (suite *JobManagerTestSuite) TestExecuteExecutorStartError() {
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
 	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(suite.err)
 
 	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
 	suite.r.On("Update", suite.job.ID, "status", "error").Return(nil)
 
+	suite.expectStepStatus(0, "pulling", "running", "error")
 	resultErr := suite.jm.Execute(suite.job)
 
 	if suite.Error(resultErr) {
@@ -123,6 +603,9 @@ func (suite *JobManagerTestSuite) TestExecuteExecutorStartError() {
 }
 
 func (suite *JobManagerTestSuite) TestExecuteContainerInspectError() {
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
 	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	suite.e.On("Inspect", suite.job).Return(suite.err)
 	suite.e.On("CleanUp", suite.job).Return(nil)
@@ -130,6 +613,7 @@ func (suite *JobManagerTestSuite) TestExecuteContainerInspectError() {
 	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
 	suite.r.On("Update", suite.job.ID, "status", "error").Return(nil)
 
+	suite.expectStepStatus(0, "pulling", "running", "error")
 	resultErr := suite.jm.Execute(suite.job)
 
 	if suite.Error(resultErr) {
@@ -137,21 +621,179 @@ func (suite *JobManagerTestSuite) TestExecuteContainerInspectError() {
 	}
 }
 
+func (suite *JobManagerTestSuite) TestExecutePersistsStepOutput() {
+	suite.job.Steps = append(suite.job.Steps, JobStep{Name: "Step2", Source: "foo/baz"})
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.output = "line of output"
+
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
+	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.e.On("Inspect", suite.job).Return(nil)
+	suite.e.On("CleanUp", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("AppendLogEntry", suite.job.ID, mock.AnythingOfType("job.LogEntry")).Return(nil)
+	suite.r.On("SaveStepOutput", suite.job.ID, []byte("line of output\n")).Return(nil)
+	suite.r.On("Update", suite.job.ID, "completedSteps", "1").Return(nil)
+	suite.r.On("Update", suite.job.ID, "completedSteps", "2").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
+
+	suite.expectStepStatus(0, "pulling", "running", "complete")
+	suite.expectStepStatus(1, "pulling", "running", "complete")
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.Nil(resultErr)
+}
+
+func (suite *JobManagerTestSuite) TestExecuteResumed() {
+	suite.job.Steps = append(suite.job.Steps, JobStep{Name: "Step2", Source: "foo/baz"})
+	suite.job.StepsCompleted = 1
+
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
+	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.e.On("Inspect", suite.job).Return(nil)
+	suite.e.On("CleanUp", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("GetStepOutput", suite.job.ID).Return([]byte("foo"), nil)
+	suite.r.On("Update", suite.job.ID, "completedSteps", "2").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
+
+	suite.expectStepStatus(1, "pulling", "running", "complete")
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.Nil(resultErr)
+	suite.e.Mock.AssertNumberOfCalls(suite.T(), "Start", 1)
+
+	stdIn, _ := ioutil.ReadAll(suite.e.Calls[2].Arguments.Get(1).(io.Reader))
+	suite.Equal("foo", string(stdIn))
+}
+
+func (suite *JobManagerTestSuite) TestExecuteDequeuesJob() {
+	suite.jm.queued = map[string]bool{suite.job.ID: true}
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
+	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.e.On("Inspect", suite.job).Return(nil)
+	suite.e.On("CleanUp", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("Update", suite.job.ID, "completedSteps", "1").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
+	suite.expectStepStatus(0, "pulling", "running", "complete")
+
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Empty(suite.jm.queued)
+	suite.Empty(suite.jm.running)
+}
+
+func (suite *JobManagerTestSuite) TestExecutePolicyViolation() {
+	suite.jm.config.Policy = SecurityPolicy{AllowedImages: []string{"centurylink/*"}}
+	suite.e.On("TearDown", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("AppendLogEntry", suite.job.ID, mock.AnythingOfType("job.LogEntry")).Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "error").Return(nil)
+
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.IsType(PolicyViolationError{}, resultErr)
+	suite.e.Mock.AssertNotCalled(suite.T(), "Setup", suite.job, mock.Anything)
+	suite.e.Mock.AssertNotCalled(suite.T(), "Start", suite.job, mock.Anything, mock.Anything, mock.Anything)
+
+	entry := suite.r.Calls[1].Arguments.Get(1).(LogEntry)
+	suite.Equal("Job rejected by security policy: steps[0].source: foo/bar is not an allowed image", entry.Text)
+	suite.Equal(streamSystem, entry.Stream)
+}
+
+func (suite *JobManagerTestSuite) TestExecuteReleasesQuota() {
+	suite.job.Namespace = "team-a"
+	suite.jm.active = map[string]int{"team-a": 2}
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
+	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.e.On("Inspect", suite.job).Return(nil)
+	suite.e.On("CleanUp", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("Update", suite.job.ID, "completedSteps", "1").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
+	suite.expectStepStatus(0, "pulling", "running", "complete")
+
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.NoError(resultErr)
+	suite.Equal(map[string]int{"team-a": 1}, suite.jm.active)
+	suite.Contains(suite.r.namespaces, "team-a")
+}
+
 func (suite *JobManagerTestSuite) TestExecuteOutputLogging() {
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
 	suite.e.output = "line of output"
 
+	suite.e.On("Pull", suite.job, mock.Anything).Return(nil)
 	suite.e.On("Start", suite.job, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	suite.e.On("Inspect", suite.job).Return(nil)
 	suite.e.On("CleanUp", suite.job).Return(nil)
 
 	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
 	suite.r.On("Update", suite.job.ID, "completedSteps", "1").Return(nil)
-	suite.r.On("AppendLogLine", suite.job.ID, suite.e.output).Return(nil)
+	suite.r.On("AppendLogEntry", suite.job.ID, mock.AnythingOfType("job.LogEntry")).Return(nil)
 	suite.r.On("Update", suite.job.ID, "status", "complete").Return(nil)
 
+	suite.expectStepStatus(0, "pulling", "running", "complete")
 	resultErr := suite.jm.Execute(suite.job)
 
 	suite.Nil(resultErr)
+
+	entry := suite.r.Calls[3].Arguments.Get(1).(LogEntry)
+	suite.Equal(1, entry.Step)
+	suite.Equal("stdout", entry.Stream)
+	suite.Equal("line of output", entry.Text)
+	suite.False(entry.Time.IsZero())
+}
+
+func (suite *JobManagerTestSuite) TestExecutePullError() {
+	suite.e.On("Setup", suite.job, mock.Anything).Return(nil)
+	suite.e.On("TearDown", suite.job).Return(nil)
+	suite.e.On("Pull", suite.job, mock.Anything).Return(suite.err)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "error").Return(nil)
+	suite.expectStepStatus(0, "pulling", "error")
+
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.Equal(suite.err, resultErr)
+	suite.Equal("error", suite.job.Steps[0].Status)
+	suite.e.Mock.AssertNotCalled(suite.T(), "Start", suite.job, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *JobManagerTestSuite) TestExecuteSetupError() {
+	suite.e.On("Setup", suite.job, mock.Anything).Return(suite.err)
+	suite.e.On("TearDown", suite.job).Return(nil)
+
+	suite.r.On("Update", suite.job.ID, "status", "running").Return(nil)
+	suite.r.On("Update", suite.job.ID, "status", "error").Return(nil)
+
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.Equal(suite.err, resultErr)
+	suite.e.Mock.AssertNotCalled(suite.T(), "Start", suite.job, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *JobManagerTestSuite) expectStepStatus(step int, statuses ...string) {
+	for _, status := range statuses {
+		suite.r.On("Update", suite.job.ID, fmt.Sprintf("stepStatus.%d", step), status).Return(nil)
+	}
 }
 
 func TestJobManagerTestSuite(t *testing.T) {
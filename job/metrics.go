@@ -0,0 +1,45 @@
+package job
+
+import "github.com/CenturyLinkLabs/dray/metrics"
+
+var (
+	jobsCreated = metrics.NewCounter(
+		"dray_jobs_created_total",
+		"Number of jobs created.")
+	jobsFinished = metrics.NewCounter(
+		"dray_jobs_finished_total",
+		"Number of jobs which finished executing, by final status (complete, error, cancelled or interrupted).",
+		"status")
+	jobsRunning = metrics.NewGauge(
+		"dray_jobs_running",
+		"Number of jobs currently executing.")
+	jobsQueued = metrics.NewGauge(
+		"dray_jobs_queued",
+		"Number of jobs which have been created but have not started executing.")
+	jobDuration = metrics.NewHistogram(
+		"dray_job_duration_seconds",
+		"Time taken to execute a job, by final status.",
+		nil, "status")
+	stepDuration = metrics.NewHistogram(
+		"dray_step_duration_seconds",
+		"Time taken to execute a job step (including pulling its image), by final status.",
+		nil, "status")
+	imagePullDuration = metrics.NewHistogram(
+		"dray_image_pull_duration_seconds",
+		"Time taken to pull an image, by result (success or error).",
+		nil, "result")
+	redisCommandDuration = metrics.NewHistogram(
+		"dray_redis_command_duration_seconds",
+		"Latency of Redis commands, by command.",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		"command")
+)
+
+// Returns the result label for an operation which returned err.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "success"
+}
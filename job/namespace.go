@@ -0,0 +1,137 @@
+package job
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultNamespace is the namespace of jobs which are submitted without
+// one. Its jobs are stored under the same keys used by versions of Dray
+// which predate namespaces.
+const DefaultNamespace = "default"
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// QuotaExceededError is an error returned when a job is submitted to a
+// namespace which already has as many active jobs as its quota allows.
+type QuotaExceededError struct {
+	Namespace string
+	Limit     int
+}
+
+// Error returns the error string for the QuotaExceededError
+func (e QuotaExceededError) Error() string {
+	return fmt.Sprintf("Namespace %s has reached its limit of %d active jobs", e.Namespace, e.Limit)
+}
+
+// NamespaceQuotas limits the number of jobs in each namespace which may be
+// active (queued or running) at once. The Default limit applies to any
+// namespace which is not listed in Limits. A limit of zero means there is no
+// limit.
+//
+// Active jobs are counted in the memory of the JobManager, so the quotas are
+// enforced per process: servers sharing a Redis instance each allow a
+// namespace up to its limit.
+type NamespaceQuotas struct {
+	Default int
+	Limits  map[string]int
+}
+
+func (q NamespaceQuotas) limit(namespace string) int {
+	if limit, ok := q.Limits[namespace]; ok {
+		return limit
+	}
+
+	return q.Default
+}
+
+// ParseNamespaceQuotas parses a comma-separated list of per-namespace job
+// limits in the form "namespace=limit".
+func ParseNamespaceQuotas(s string) (map[string]int, error) {
+	limits := map[string]int{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || !ValidNamespace(parts[0]) {
+			return nil, fmt.Errorf("Invalid namespace quota %q", item)
+		}
+
+		limit, err := strconv.Atoi(parts[1])
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("Invalid namespace quota %q", item)
+		}
+
+		limits[parts[0]] = limit
+	}
+
+	return limits, nil
+}
+
+// ValidNamespace returns true if the name can be used as a namespace: it
+// must consist of lowercase letters, digits and hyphens and may not start or
+// end with a hyphen.
+func ValidNamespace(name string) bool {
+	return len(name) <= 63 && namespacePattern.MatchString(name)
+}
+
+// Returns the namespace, substituting the DefaultNamespace if it is empty.
+func namespaceOrDefault(namespace string) string {
+	if len(namespace) == 0 {
+		return DefaultNamespace
+	}
+
+	return namespace
+}
+
+// Reserves a place in the namespace's quota for a job which is about to be
+// created. The place must be released once the job has finished or if it
+// could not be created.
+func (jm *jobManager) reserve(namespace string) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	namespace = namespaceOrDefault(namespace)
+	limit := jm.config.Quotas.limit(namespace)
+
+	if limit > 0 && jm.active[namespace] >= limit {
+		return QuotaExceededError{Namespace: namespace, Limit: limit}
+	}
+
+	if jm.active == nil {
+		jm.active = map[string]int{}
+	}
+
+	jm.active[namespace]++
+	return nil
+}
+
+func (jm *jobManager) release(namespace string) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	jm.releaseLocked(namespace)
+}
+
+// Releases a place in the namespace's quota while the mutex is held.
+func (jm *jobManager) releaseLocked(namespace string) {
+	namespace = namespaceOrDefault(namespace)
+
+	if jm.active[namespace] > 1 {
+		jm.active[namespace]--
+	} else {
+		delete(jm.active, namespace)
+	}
+}
+
+// Returns a view of the repository which stores the job's data under its
+// namespace.
+func (jm *jobManager) jobRepository(job *Job) JobRepository {
+	return jm.repository.InNamespace(namespaceOrDefault(job.Namespace))
+}
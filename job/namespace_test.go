@@ -0,0 +1,39 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidNamespace(t *testing.T) {
+	for _, ns := range []string{"default", "team-a", "a", "42"} {
+		assert.True(t, ValidNamespace(ns), ns)
+	}
+
+	for _, ns := range []string{"", "Team", "team_a", "-team", "team-", "team:a"} {
+		assert.False(t, ValidNamespace(ns), ns)
+	}
+}
+
+func TestParseNamespaceQuotas(t *testing.T) {
+	limits, err := ParseNamespaceQuotas("team-a=5, team-b=0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"team-a": 5, "team-b": 0}, limits)
+}
+
+func TestParseNamespaceQuotasInvalid(t *testing.T) {
+	for _, s := range []string{"team-a", "=1", "team-a=x", "team-a=-1", "Team=1"} {
+		_, err := ParseNamespaceQuotas(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNamespaceQuotasLimit(t *testing.T) {
+	q := NamespaceQuotas{Default: 3, Limits: map[string]int{"team-a": 1, "team-b": 0}}
+
+	assert.Equal(t, 1, q.limit("team-a"))
+	assert.Equal(t, 0, q.limit("team-b"))
+	assert.Equal(t, 3, q.limit("team-c"))
+}
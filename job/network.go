@@ -0,0 +1,117 @@
+package job
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	networkNone   = "none"
+	networkHost   = "host"
+	networkBridge = "bridge"
+)
+
+var networkNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// NetworkPolicy holds the server-wide list of Docker networks which job steps
+// are allowed to join. Steps may always use the default "bridge" network or
+// "none"; the "host" network is only available if it is on the list.
+type NetworkPolicy struct {
+	Allowed []string
+}
+
+// Returns true if steps on the network can be given aliases (which Docker
+// only supports on user-defined networks).
+func isUserDefinedNetwork(name string) bool {
+	switch name {
+	case "", networkNone, networkHost, networkBridge, "default":
+		return false
+	}
+
+	return true
+}
+
+func (js JobStep) validateNetwork(v *validator, path string, jobNetwork bool) {
+	if len(js.Network) > 0 && !networkNamePattern.MatchString(js.Network) {
+		v.add(path+".network", "must be a network name, \"none\" or \"host\"")
+	}
+
+	for i, h := range js.ExtraHosts {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || net.ParseIP(parts[1]) == nil {
+			v.add(fmt.Sprintf("%s.extraHosts[%d]", path, i), "must be in the form \"host:ip\"")
+		}
+	}
+
+	for i, dns := range js.DNS {
+		if net.ParseIP(dns) == nil {
+			v.add(fmt.Sprintf("%s.dns[%d]", path, i), "must be an IP address")
+		}
+	}
+
+	joinsJobNetwork := jobNetwork && len(js.Network) == 0
+	if len(js.NetworkAliases) > 0 && !joinsJobNetwork && !isUserDefinedNetwork(js.Network) {
+		v.add(path+".networkAliases", "requires a user-defined network")
+	}
+
+	for i, alias := range js.NetworkAliases {
+		if len(alias) == 0 {
+			v.add(fmt.Sprintf("%s.networkAliases[%d]", path, i), msgRequired)
+		}
+	}
+}
+
+// Returns the network which the current step's container should join. Steps
+// which do not name a network join the job's own network (if it has one).
+func (j Job) currentStepNetwork() string {
+	step := j.currentStep()
+
+	if len(step.Network) == 0 && j.usesNetwork() {
+		return jobNetwork(&j)
+	}
+
+	return step.Network
+}
+
+// Returns the aliases by which the step's container can be reached on its
+// network. A named step can always be reached by its name.
+func (js JobStep) aliases() []string {
+	aliases := append([]string{}, js.NetworkAliases...)
+
+	if networkNamePattern.MatchString(js.Name) {
+		aliases = append(aliases, js.Name)
+	}
+
+	return aliases
+}
+
+// Returns true if the network is on the allow-list.
+func (p NetworkPolicy) allows(name string) bool {
+	if name == networkNone || name == networkBridge || name == "default" {
+		return true
+	}
+
+	for _, allowed := range p.Allowed {
+		if name == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks that every network joined by the job's steps is on the allow-list.
+func (p NetworkPolicy) validate(v *validator, j *Job) {
+	for i, step := range j.Steps {
+		if len(step.Network) > 0 && !p.allows(step.Network) {
+			v.add(fmt.Sprintf("steps[%d].network", i), "%s is not an allowed network", step.Network)
+		}
+	}
+}
+
+// Returns the name of the user-defined network created for the job.
+func jobNetwork(j *Job) string {
+	return "dray-network-" + j.ID
+}
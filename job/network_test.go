@@ -0,0 +1,76 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkValidate(t *testing.T) {
+	j := Job{
+		Steps: []JobStep{
+			{
+				Source:         "foo",
+				Network:        "-bad",
+				ExtraHosts:     []string{"db:10.0.0.2", "db", ":10.0.0.2", "db:nope"},
+				DNS:            []string{"8.8.8.8", "dns.example.com"},
+				NetworkAliases: []string{"foo"},
+			},
+			{Source: "bar", Network: "bridge", NetworkAliases: []string{"bar"}},
+			{Source: "baz", Network: "ci", NetworkAliases: []string{""}},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "steps[0].network", Message: "must be a network name, \"none\" or \"host\""},
+		{Field: "steps[0].extraHosts[1]", Message: "must be in the form \"host:ip\""},
+		{Field: "steps[0].extraHosts[2]", Message: "must be in the form \"host:ip\""},
+		{Field: "steps[0].extraHosts[3]", Message: "must be in the form \"host:ip\""},
+		{Field: "steps[0].dns[1]", Message: "must be an IP address"},
+		{Field: "steps[1].networkAliases", Message: "requires a user-defined network"},
+		{Field: "steps[2].networkAliases[0]", Message: "required"},
+	}, j.Validate())
+}
+
+func TestNetworkValidateJobNetworkAliases(t *testing.T) {
+	j := Job{
+		CreateNetwork: true,
+		Steps: []JobStep{
+			{Source: "foo", NetworkAliases: []string{"foo"}},
+			{Source: "bar", Network: "none", NetworkAliases: []string{"bar"}},
+		},
+	}
+
+	assert.Equal(t, NewValidationError("steps[1].networkAliases", "requires a user-defined network"), j.Validate())
+}
+
+func TestNetworkPolicyValidate(t *testing.T) {
+	p := NetworkPolicy{Allowed: []string{"ci", "host"}}
+	j := &Job{
+		Steps: []JobStep{
+			{Network: "none"},
+			{Network: "bridge"},
+			{Network: "host"},
+			{Network: "ci"},
+			{Network: "other"},
+			{},
+		},
+	}
+	v := &validator{}
+
+	p.validate(v, j)
+
+	assert.Equal(t, NewValidationError("steps[4].network", "other is not an allowed network"), v.err())
+	assert.False(t, NetworkPolicy{}.allows("host"))
+}
+
+func TestCurrentStepNetwork(t *testing.T) {
+	j := Job{ID: "123", Steps: []JobStep{{}}}
+	assert.Equal(t, "", j.currentStepNetwork())
+
+	j.CreateNetwork = true
+	assert.Equal(t, "dray-network-123", j.currentStepNetwork())
+
+	j.Steps[0].Network = "none"
+	assert.Equal(t, "none", j.currentStepNetwork())
+}
@@ -0,0 +1,218 @@
+package job
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The privileged options which may be forbidden by a SecurityPolicy.
+const (
+	OptionRoot        = "root"
+	OptionHostNetwork = "host-network"
+	OptionHostVolumes = "host-volumes"
+)
+
+var requirableResources = []string{"memory", "memorySwap", "cpuShares", "cpuQuota", "pidsLimit"}
+
+// PolicyViolationError is an error returned when a job breaks the rules of
+// the server's SecurityPolicy. It lists every rule which was broken, along
+// with the path of the offending field.
+type PolicyViolationError []FieldError
+
+// Error returns the error string for the PolicyViolationError
+func (e PolicyViolationError) Error() string {
+	return ValidationError(e).Error()
+}
+
+// SecurityPolicy holds the server-wide rules which every job must follow
+// before its containers are started.
+//
+// AllowedRegistries lists the hosts of the registries from which step and
+// service images may be pulled (with "docker.io" standing for the Docker
+// Hub) and AllowedImages lists patterns, in the syntax of path.Match, for the
+// names of the images which may be used (e.g. "centurylink/*"). Image names
+// are matched without their tag or digest, and images from the Docker Hub
+// are matched without a registry host. Any registry or image is allowed if
+// the corresponding list is empty.
+//
+// Forbidden lists the privileged options which steps and services may not
+// use: "root" (running as the root user, including by not naming a user),
+// "host-network" and "host-volumes" (mounting paths from the host, even if
+// they are allowed by the VolumePolicy). RequiredResources names the resource
+// limits (e.g. "memory") which every step and service must have, either from
+// its own resources or from the server defaults. MaxSteps limits the number
+// of steps in a job; there is no limit if it is zero.
+type SecurityPolicy struct {
+	AllowedRegistries []string
+	AllowedImages     []string
+	Forbidden         []string
+	RequiredResources []string
+	MaxSteps          int
+}
+
+// Validate checks that the forbidden options and required resources are
+// known and that the image patterns are well-formed.
+func (p SecurityPolicy) Validate() error {
+	for _, pattern := range p.AllowedImages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid image pattern %q", pattern)
+		}
+	}
+
+	for _, option := range p.Forbidden {
+		switch option {
+		case OptionRoot, OptionHostNetwork, OptionHostVolumes:
+		default:
+			return fmt.Errorf("Unknown option %q, must be %q, %q or %q", option, OptionRoot, OptionHostNetwork, OptionHostVolumes)
+		}
+	}
+
+	for _, name := range p.RequiredResources {
+		if !contains(requirableResources, name) {
+			return fmt.Errorf("Unknown resource %q, must be one of %s", name, strings.Join(requirableResources, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Checks the job against every rule of the policy.
+func (p SecurityPolicy) check(j *Job) error {
+	v := &validator{}
+
+	if p.MaxSteps > 0 && len(j.Steps) > p.MaxSteps {
+		v.add("steps", "jobs may not have more than %d steps", p.MaxSteps)
+	}
+
+	for i, step := range j.Steps {
+		field := fmt.Sprintf("steps[%d]", i)
+
+		p.checkImage(v, field+".source", step.Source)
+		p.checkOptions(v, field, "steps", step.User, step.Network, step.Volumes)
+		p.checkResources(v, field+".resources", step.Resources)
+	}
+
+	// Services are checked as steps are, except that they always join the
+	// job's network and have no volumes, so their user is the only option
+	// which can be forbidden
+	for i, s := range j.Services {
+		field := fmt.Sprintf("services[%d]", i)
+
+		p.checkImage(v, field+".source", s.Source)
+		p.checkOptions(v, field, "services", s.User, "", nil)
+		p.checkResources(v, field+".resources", s.Resources)
+	}
+
+	if len(v.errs) > 0 {
+		return PolicyViolationError(v.errs)
+	}
+
+	return nil
+}
+
+func (p SecurityPolicy) checkImage(v *validator, field, image string) {
+	if len(image) == 0 {
+		return
+	}
+
+	host := registryHost(image)
+	if len(p.AllowedRegistries) > 0 && !p.allowsRegistry(normalizeRegistry(host)) {
+		v.add(field, "images may not be pulled from %s", host)
+	}
+
+	if len(p.AllowedImages) > 0 && !p.allowsImage(policyImageName(image)) {
+		v.add(field, "%s is not an allowed image", repositoryName(image))
+	}
+}
+
+func (p SecurityPolicy) allowsRegistry(host string) bool {
+	for _, allowed := range p.AllowedRegistries {
+		if normalizeRegistry(allowed) == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p SecurityPolicy) allowsImage(name string) bool {
+	for _, pattern := range p.AllowedImages {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks the privileged options of a step or service, where kind ("steps" or
+// "services") names the containers in the error messages.
+func (p SecurityPolicy) checkOptions(v *validator, field, kind, user, network string, volumes []Volume) {
+	if contains(p.Forbidden, OptionRoot) && runsAsRoot(user) {
+		v.add(field+".user", "%s must run as a non-root user", kind)
+	}
+
+	if contains(p.Forbidden, OptionHostNetwork) && network == networkHost {
+		v.add(field+".network", "%s may not use the host network", kind)
+	}
+
+	if contains(p.Forbidden, OptionHostVolumes) {
+		for n, vol := range volumes {
+			if vol.isHostPath() {
+				v.add(fmt.Sprintf("%s.volumes[%d].source", field, n), "%s may not mount paths from the host", kind)
+			}
+		}
+	}
+}
+
+func (p SecurityPolicy) checkResources(v *validator, field string, r *Resources) {
+	if r == nil {
+		r = &Resources{}
+	}
+
+	limits := map[string]int64{
+		"memory":     r.Memory,
+		"memorySwap": r.MemorySwap,
+		"cpuShares":  r.CPUShares,
+		"cpuQuota":   r.CPUQuota,
+		"pidsLimit":  r.PidsLimit,
+	}
+
+	for _, name := range p.RequiredResources {
+		if limits[name] <= 0 {
+			v.add(field+"."+name, "a %s limit is required", name)
+		}
+	}
+}
+
+// Returns the name of the image which is matched against the AllowedImages
+// patterns: the repository name without the Docker Hub's registry host or
+// "library/" prefix.
+func policyImageName(image string) string {
+	name := repositoryName(image)
+
+	for _, prefix := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+
+	return strings.TrimPrefix(name, "library/")
+}
+
+// Returns true if a container started with the user (in the "user[:group]"
+// form accepted by Docker) runs as root. The image's default user is assumed
+// to be root.
+func runsAsRoot(user string) bool {
+	name := strings.SplitN(user, ":", 2)[0]
+	return len(name) == 0 || name == "root" || name == "0"
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
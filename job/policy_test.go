@@ -0,0 +1,120 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityPolicyValidate(t *testing.T) {
+	p := SecurityPolicy{
+		AllowedImages:     []string{"centurylink/*"},
+		Forbidden:         []string{"root", "host-network", "host-volumes"},
+		RequiredResources: []string{"memory", "pidsLimit"},
+	}
+	assert.NoError(t, p.Validate())
+
+	assert.EqualError(t, SecurityPolicy{AllowedImages: []string{"[a-"}}.Validate(), `Invalid image pattern "[a-"`)
+	assert.EqualError(t, SecurityPolicy{Forbidden: []string{"privileged"}}.Validate(),
+		`Unknown option "privileged", must be "root", "host-network" or "host-volumes"`)
+	assert.EqualError(t, SecurityPolicy{RequiredResources: []string{"disk"}}.Validate(),
+		`Unknown resource "disk", must be one of memory, memorySwap, cpuShares, cpuQuota, pidsLimit`)
+}
+
+func TestSecurityPolicyAllowsAnything(t *testing.T) {
+	j := &Job{
+		Steps:    []JobStep{{Source: "evil/miner", Network: "host", Volumes: []Volume{{Source: "/", Target: "/host"}}}},
+		Services: []Service{{Name: "db", Source: "redis"}},
+	}
+
+	assert.NoError(t, SecurityPolicy{}.check(j))
+}
+
+func TestSecurityPolicyImages(t *testing.T) {
+	p := SecurityPolicy{
+		AllowedRegistries: []string{"docker.io", "registry.example.com"},
+		AllowedImages:     []string{"centurylink/*", "redis", "registry.example.com/tools/*"},
+	}
+	j := &Job{
+		Steps: []JobStep{
+			{Source: "centurylink/upper:1.0"},
+			{Source: "docker.io/library/redis"},
+			{Source: "registry.example.com/tools/lint@sha256:0f3b1b4e9dd8"},
+			{Source: "evil/miner:latest"},
+			{Source: "quay.io/centurylink/upper"},
+		},
+		Services: []Service{{Name: "db", Source: "postgres"}},
+	}
+
+	assert.Equal(t, PolicyViolationError{
+		{Field: "steps[3].source", Message: "evil/miner is not an allowed image"},
+		{Field: "steps[4].source", Message: "images may not be pulled from quay.io"},
+		{Field: "steps[4].source", Message: "quay.io/centurylink/upper is not an allowed image"},
+		{Field: "services[0].source", Message: "postgres is not an allowed image"},
+	}, p.check(j))
+}
+
+func TestSecurityPolicyForbiddenOptions(t *testing.T) {
+	p := SecurityPolicy{Forbidden: []string{"root", "host-network", "host-volumes"}}
+	j := &Job{
+		Steps: []JobStep{
+			{Source: "foo", User: "nobody", Volumes: []Volume{{Source: "cache", Target: "/cache"}}},
+			{Source: "foo", User: "0:0", Network: "host", Volumes: []Volume{{Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"}}},
+			{Source: "foo"},
+		},
+	}
+
+	err := p.check(j)
+
+	assert.Equal(t, PolicyViolationError{
+		{Field: "steps[1].user", Message: "steps must run as a non-root user"},
+		{Field: "steps[1].network", Message: "steps may not use the host network"},
+		{Field: "steps[1].volumes[0].source", Message: "steps may not mount paths from the host"},
+		{Field: "steps[2].user", Message: "steps must run as a non-root user"},
+	}, err)
+	assert.EqualError(t, err, "steps[1].user: steps must run as a non-root user, "+
+		"steps[1].network: steps may not use the host network, "+
+		"steps[1].volumes[0].source: steps may not mount paths from the host, "+
+		"steps[2].user: steps must run as a non-root user")
+}
+
+func TestSecurityPolicyServiceOptions(t *testing.T) {
+	p := SecurityPolicy{Forbidden: []string{"root", "host-network", "host-volumes"}}
+	j := &Job{
+		Steps: []JobStep{{Source: "foo", User: "nobody"}},
+		Services: []Service{
+			{Name: "db", Source: "postgres", User: "postgres"},
+			{Name: "cache", Source: "redis"},
+			{Name: "queue", Source: "rabbitmq", User: "root"},
+		},
+	}
+
+	assert.Equal(t, PolicyViolationError{
+		{Field: "services[1].user", Message: "services must run as a non-root user"},
+		{Field: "services[2].user", Message: "services must run as a non-root user"},
+	}, p.check(j))
+}
+
+func TestSecurityPolicyResourcesAndSteps(t *testing.T) {
+	p := SecurityPolicy{RequiredResources: []string{"memory", "cpuQuota"}, MaxSteps: 1}
+	j := &Job{
+		Steps: []JobStep{
+			{Source: "foo", Resources: &Resources{Memory: 1024, CPUQuota: 50000}},
+			{Source: "foo", Resources: &Resources{Memory: -1}},
+		},
+		Services: []Service{
+			{Name: "db", Source: "postgres", Resources: &Resources{Memory: 2048, CPUQuota: 50000}},
+			{Name: "cache", Source: "redis", Resources: &Resources{CPUQuota: 50000}},
+			{Name: "queue", Source: "rabbitmq"},
+		},
+	}
+
+	assert.Equal(t, PolicyViolationError{
+		{Field: "steps", Message: "jobs may not have more than 1 steps"},
+		{Field: "steps[1].resources.memory", Message: "a memory limit is required"},
+		{Field: "steps[1].resources.cpuQuota", Message: "a cpuQuota limit is required"},
+		{Field: "services[1].resources.memory", Message: "a memory limit is required"},
+		{Field: "services[2].resources.memory", Message: "a memory limit is required"},
+		{Field: "services[2].resources.cpuQuota", Message: "a cpuQuota limit is required"},
+	}, p.check(j))
+}
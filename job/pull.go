@@ -0,0 +1,89 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	pullAlways       = "always"
+	pullIfNotPresent = "if-not-present"
+	pullNever        = "never"
+)
+
+// pullMessage is one of the JSON progress events streamed by Docker while an
+// image is being pulled.
+type pullMessage struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// Returns the step's pull policy. The deprecated Refresh flag is equivalent
+// to a policy of "always".
+func (js JobStep) pullPolicy() string {
+	if len(js.PullPolicy) > 0 {
+		return js.PullPolicy
+	} else if js.Refresh {
+		return pullAlways
+	}
+
+	return pullIfNotPresent
+}
+
+func (js JobStep) validatePullPolicy(v *validator, path string) {
+	if !validatePullPolicy(v, path+".pullPolicy", js.PullPolicy) {
+		return
+	}
+
+	if js.Refresh && len(js.PullPolicy) > 0 && js.PullPolicy != pullAlways {
+		v.add(path+".refresh", "conflicts with pullPolicy %q", js.PullPolicy)
+	}
+}
+
+// Checks that the policy is empty or one of the known pull policies.
+func validatePullPolicy(v *validator, field, policy string) bool {
+	switch policy {
+	case "", pullAlways, pullIfNotPresent, pullNever:
+		return true
+	}
+
+	v.add(field, "must be %q, %q or %q", pullAlways, pullIfNotPresent, pullNever)
+	return false
+}
+
+// Reads the pull progress events from r and writes a line to w whenever the
+// status of the pull (or of one of the image's layers) changes. The
+// frequent "Downloading" and "Extracting" events are only reported once per
+// layer. Returns the error reported by Docker, if any.
+func writePullProgress(w io.Writer, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	statuses := map[string]string{}
+
+	for {
+		m := pullMessage{}
+		if err := dec.Decode(&m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if len(m.Error) > 0 {
+			fmt.Fprintln(w, m.Error)
+			return errors.New(m.Error)
+		}
+
+		if len(m.Status) == 0 || statuses[m.ID] == m.Status {
+			continue
+		}
+		statuses[m.ID] = m.Status
+
+		if len(m.ID) > 0 {
+			fmt.Fprintf(w, "%s: %s\n", m.ID, m.Status)
+		} else {
+			fmt.Fprintln(w, m.Status)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullPolicy(t *testing.T) {
+	assert.Equal(t, "if-not-present", JobStep{}.pullPolicy())
+	assert.Equal(t, "always", JobStep{Refresh: true}.pullPolicy())
+	assert.Equal(t, "never", JobStep{PullPolicy: "never"}.pullPolicy())
+}
+
+func TestPullPolicyValidate(t *testing.T) {
+	j := Job{
+		Steps: []JobStep{
+			{Source: "foo", PullPolicy: "sometimes"},
+			{Source: "foo", PullPolicy: "never", Refresh: true},
+			{Source: "foo", PullPolicy: "always", Refresh: true},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "steps[0].pullPolicy", Message: "must be \"always\", \"if-not-present\" or \"never\""},
+		{Field: "steps[1].refresh", Message: "conflicts with pullPolicy \"never\""},
+	}, j.Validate())
+}
@@ -0,0 +1,236 @@
+package job
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fzzy/radix/redis"
+)
+
+const (
+	defaultRedisPort    = "6379"
+	defaultSentinelPort = "26379"
+)
+
+// redisTimeout bounds the time taken to open a connection to Redis and to
+// read or write each command.
+var redisTimeout = 10 * time.Second
+
+// RedisConfig describes the connection to the Redis server in which jobs are
+// stored. If Password is set, each connection is authenticated with it and
+// each connection selects the numbered database DB. PoolSize is the number of
+// idle connections kept open, and defaults to 4. Connections are made with
+// TLS if it is set. Connecting, and reading or writing each command, fail if
+// they take longer than 10 seconds.
+//
+// If Sentinels lists the addresses of Redis Sentinels, Address is ignored and
+// the Sentinels are asked for the address of the master named MasterName
+// whenever a connection is made. After a failover the existing connections
+// fail, and are replaced with connections to the new master.
+type RedisConfig struct {
+	Address  string
+	Password string
+	DB       int
+	PoolSize int
+	TLS      *tls.Config
+
+	Sentinels  []string
+	MasterName string
+}
+
+// ParseRedisURL parses the address of a Redis server. The address may be a
+// bare "host:port" pair, a "tcp://host:port" URL (as set by Docker links) or
+// a URL of the form "redis://[:password@]host[:port][/db]", with the
+// "rediss" scheme connecting over TLS. Sentinels are addressed with a URL of
+// the form "redis+sentinel://[:password@]host[:port][,host[:port]...]/master[/db]"
+// (or "rediss+sentinel" for TLS), where the password is that of the master.
+func ParseRedisURL(s string) (RedisConfig, error) {
+	c := RedisConfig{}
+	invalid := fmt.Errorf("Invalid Redis URL %q", s)
+
+	if !strings.Contains(s, "://") {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return c, invalid
+		}
+
+		c.Address = s
+		return c, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || len(u.Host) == 0 {
+		return c, invalid
+	}
+
+	path := strings.Trim(u.Path, "/")
+
+	switch u.Scheme {
+	case "tcp":
+		c.Address = u.Host
+		return c, nil
+	case "redis", "rediss":
+		c.Address = withDefaultPort(u.Host, defaultRedisPort)
+	case "redis+sentinel", "rediss+sentinel":
+		for _, host := range strings.Split(u.Host, ",") {
+			c.Sentinels = append(c.Sentinels, withDefaultPort(host, defaultSentinelPort))
+		}
+
+		parts := strings.SplitN(path, "/", 2)
+		if len(parts[0]) == 0 {
+			return c, fmt.Errorf("Invalid Redis URL %q, the name of the master is required", s)
+		}
+
+		c.MasterName = parts[0]
+		path = strings.Join(parts[1:], "")
+	default:
+		return c, fmt.Errorf("Invalid Redis URL %q, unknown scheme %q", s, u.Scheme)
+	}
+
+	if strings.HasPrefix(u.Scheme, "rediss") {
+		c.TLS = &tls.Config{}
+	}
+
+	if u.User != nil {
+		c.Password, _ = u.User.Password()
+	}
+
+	if len(path) > 0 {
+		if c.DB, err = strconv.Atoi(path); err != nil || c.DB < 0 {
+			return c, fmt.Errorf("Invalid Redis URL %q, bad database %q", s, path)
+		}
+	}
+
+	return c, nil
+}
+
+func withDefaultPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// Opens a connection to Redis, authenticating and selecting the database.
+// When Sentinels are used, the master's address is looked up first and the
+// connection is rejected if the server is no longer the master.
+func (c RedisConfig) dial(network, addr string) (*redisClient, error) {
+	if len(c.Sentinels) > 0 {
+		master, err := c.masterAddress(network)
+		if err != nil {
+			return nil, err
+		}
+		addr = master
+	}
+
+	client, err := c.connect(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.setUp(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (c RedisConfig) setUp(client *redisClient) error {
+	if len(c.Password) > 0 {
+		if err := client.Cmd("auth", c.Password).Err; err != nil {
+			return err
+		}
+	}
+
+	if c.DB > 0 {
+		if err := client.Cmd("select", c.DB).Err; err != nil {
+			return err
+		}
+	}
+
+	// A Sentinel may briefly report a demoted master during a failover
+	if len(c.Sentinels) > 0 {
+		reply := client.Cmd("role")
+		if reply.Err != nil {
+			return reply.Err
+		}
+
+		if len(reply.Elems) == 0 {
+			return errors.New("Unexpected reply to Redis ROLE command")
+		}
+
+		if role, _ := reply.Elems[0].Str(); role != "master" {
+			return fmt.Errorf("Redis server for master %s has the role %s", c.MasterName, role)
+		}
+	}
+
+	return nil
+}
+
+func (c RedisConfig) connect(network, addr string) (*redisClient, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+
+	var conn net.Conn
+	var err error
+
+	if c.TLS == nil {
+		conn, err = dialer.Dial(network, addr)
+	} else {
+		conn, err = tls.DialWithDialer(dialer, network, addr, c.TLS)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newRedisClient(conn, redisTimeout), nil
+}
+
+// Asks each of the Sentinels in turn for the address of the master.
+func (c RedisConfig) masterAddress(network string) (string, error) {
+	var lastErr error
+
+	for _, sentinel := range c.Sentinels {
+		addr, err := c.askSentinel(network, sentinel)
+		if err == nil {
+			return addr, nil
+		}
+
+		log.Warnf("Redis Sentinel %s cannot provide master %s: %s", sentinel, c.MasterName, err)
+		lastErr = err
+	}
+
+	return "", fmt.Errorf("No Redis Sentinel could provide the address of master %s: %s", c.MasterName, lastErr)
+}
+
+func (c RedisConfig) askSentinel(network, sentinel string) (string, error) {
+	client, err := c.connect(network, sentinel)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	reply := client.Cmd("sentinel", "get-master-addr-by-name", c.MasterName)
+	if reply.Err != nil {
+		return "", reply.Err
+	}
+
+	if reply.Type == redis.NilReply {
+		return "", fmt.Errorf("unknown master %s", c.MasterName)
+	}
+
+	hostPort, err := reply.List()
+	if err != nil || len(hostPort) != 2 {
+		return "", errors.New("unexpected reply to SENTINEL get-master-addr-by-name")
+	}
+
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
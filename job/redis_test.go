@@ -0,0 +1,393 @@
+package job
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis is a Redis server which records the commands it receives and
+// answers each with the RESP-encoded reply returned by its handler. The
+// connection is closed if the handler returns an empty reply.
+type fakeRedis struct {
+	listener net.Listener
+	handler  func(cmd []string) string
+
+	mu          sync.Mutex
+	commands    [][]string
+	connections int
+}
+
+func newFakeRedis(t *testing.T, handler func(cmd []string) string) *fakeRedis {
+	return serveFakeRedis(listen(t), handler)
+}
+
+func newFakeTLSRedis(t *testing.T, handler func(cmd []string) string) *fakeRedis {
+	dir, _ := ioutil.TempDir("", "dray-tls")
+	defer os.RemoveAll(dir)
+	writeTestCerts(t, dir)
+
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return serveFakeRedis(tls.NewListener(listen(t), &tls.Config{Certificates: []tls.Certificate{pair}}), handler)
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func serveFakeRedis(l net.Listener, handler func(cmd []string) string) *fakeRedis {
+	f := &fakeRedis{listener: l, handler: handler}
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedis) received() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.commands
+}
+
+func (f *fakeRedis) connectionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.connections
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.connections++
+		f.mu.Unlock()
+
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, cmd)
+		f.mu.Unlock()
+
+		reply := f.handler(cmd)
+		if len(reply) == 0 {
+			return
+		}
+		conn.Write([]byte(reply))
+	}
+}
+
+// Reads a command sent as a RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	cmd := make([]string, n)
+
+	for i := range cmd {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		cmd[i] = strings.TrimSpace(arg)
+	}
+
+	return cmd, nil
+}
+
+func okReply(cmd []string) string {
+	return "+OK\r\n"
+}
+
+// Replies to ROLE with the role, and to every other command with OK.
+func roleReply(role string) func(cmd []string) string {
+	return func(cmd []string) string {
+		if cmd[0] == "role" {
+			return fmt.Sprintf("*3\r\n$%d\r\n%s\r\n:0\r\n*0\r\n", len(role), role)
+		}
+		return "+OK\r\n"
+	}
+}
+
+// Replies to SENTINEL get-master-addr-by-name with the address returned by
+// the function.
+func sentinelReply(master func() string) func(cmd []string) string {
+	return func(cmd []string) string {
+		host, port, _ := net.SplitHostPort(master())
+		return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+	}
+}
+
+func TestParseRedisURL(t *testing.T) {
+	for _, tc := range []struct {
+		url string
+		c   RedisConfig
+	}{
+		{"redis:6379", RedisConfig{Address: "redis:6379"}},
+		{"tcp://172.17.0.2:6379", RedisConfig{Address: "172.17.0.2:6379"}},
+		{"redis://redis.example.com", RedisConfig{Address: "redis.example.com:6379"}},
+		{"redis://:secret@redis.example.com:6380/2", RedisConfig{Address: "redis.example.com:6380", Password: "secret", DB: 2}},
+		{"rediss://redis.example.com/1", RedisConfig{Address: "redis.example.com:6379", DB: 1, TLS: &tls.Config{}}},
+		{"redis+sentinel://s1,s2:26380/dray", RedisConfig{Sentinels: []string{"s1:26379", "s2:26380"}, MasterName: "dray"}},
+		{"rediss+sentinel://:secret@s1/dray/3", RedisConfig{Sentinels: []string{"s1:26379"}, MasterName: "dray", Password: "secret", DB: 3, TLS: &tls.Config{}}},
+	} {
+		c, err := ParseRedisURL(tc.url)
+		assert.NoError(t, err, tc.url)
+		assert.Equal(t, tc.c, c, tc.url)
+	}
+}
+
+func TestParseRedisURLErrors(t *testing.T) {
+	for url, msg := range map[string]string{
+		"redis":                   `Invalid Redis URL "redis"`,
+		"tcp://":                  `Invalid Redis URL "tcp://"`,
+		"http://redis":            `Invalid Redis URL "http://redis", unknown scheme "http"`,
+		"redis://redis/one":       `Invalid Redis URL "redis://redis/one", bad database "one"`,
+		"redis+sentinel://s1":     `Invalid Redis URL "redis+sentinel://s1", the name of the master is required`,
+		"redis+sentinel://s1/m/x": `Invalid Redis URL "redis+sentinel://s1/m/x", bad database "x"`,
+	} {
+		_, err := ParseRedisURL(url)
+		assert.EqualError(t, err, msg, url)
+	}
+}
+
+func TestRedisConfigDial(t *testing.T) {
+	f := newFakeRedis(t, okReply)
+	defer f.close()
+
+	c := RedisConfig{Address: f.addr(), Password: "secret", DB: 2}
+	client, err := c.dial("tcp", c.Address)
+
+	assert.NoError(t, err)
+	client.Close()
+	assert.Equal(t, [][]string{{"auth", "secret"}, {"select", "2"}}, f.received())
+}
+
+func TestRedisConfigDialDefaults(t *testing.T) {
+	f := newFakeRedis(t, okReply)
+	defer f.close()
+
+	c := RedisConfig{Address: f.addr()}
+	client, err := c.dial("tcp", c.Address)
+
+	assert.NoError(t, err)
+	client.Close()
+	assert.Empty(t, f.received())
+}
+
+func TestRedisConfigDialAuthError(t *testing.T) {
+	f := newFakeRedis(t, func(cmd []string) string {
+		return "-ERR invalid password\r\n"
+	})
+	defer f.close()
+
+	c := RedisConfig{Address: f.addr(), Password: "wrong", DB: 2}
+	_, err := c.dial("tcp", c.Address)
+
+	assert.EqualError(t, err, "ERR invalid password")
+	assert.Equal(t, [][]string{{"auth", "wrong"}}, f.received())
+}
+
+func TestRedisConfigDialTLS(t *testing.T) {
+	f := newFakeTLSRedis(t, okReply)
+	defer f.close()
+
+	c := RedisConfig{Address: f.addr(), Password: "secret", TLS: &tls.Config{InsecureSkipVerify: true}}
+	client, err := c.dial("tcp", c.Address)
+
+	assert.NoError(t, err)
+	assert.NoError(t, client.Cmd("ping").Err)
+	client.Close()
+	assert.Equal(t, [][]string{{"auth", "secret"}, {"ping"}}, f.received())
+
+	// The server's certificate is verified by default
+	c.TLS = &tls.Config{}
+	_, err = c.dial("tcp", c.Address)
+	assert.Error(t, err)
+}
+
+func TestRedisConfigDialTLSTimeout(t *testing.T) {
+	defer func(d time.Duration) { redisTimeout = d }(redisTimeout)
+	redisTimeout = 50 * time.Millisecond
+
+	unblock := make(chan struct{})
+	f := newFakeTLSRedis(t, func(cmd []string) string {
+		<-unblock
+		return "+PONG\r\n"
+	})
+	defer f.close()
+	defer close(unblock)
+
+	c := RedisConfig{Address: f.addr(), TLS: &tls.Config{InsecureSkipVerify: true}}
+	client, err := c.dial("tcp", c.Address)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	err = client.Cmd("ping").Err
+	if nerr, ok := err.(net.Error); assert.True(t, ok, "%v", err) {
+		assert.True(t, nerr.Timeout())
+	}
+}
+
+func TestRedisConfigDialSentinel(t *testing.T) {
+	log.SetLevel(log.PanicLevel)
+
+	master := newFakeRedis(t, roleReply("master"))
+	defer master.close()
+	down := newFakeRedis(t, func(cmd []string) string { return "$-1\r\n" })
+	defer down.close()
+	sentinel := newFakeRedis(t, sentinelReply(master.addr))
+	defer sentinel.close()
+
+	c := RedisConfig{Sentinels: []string{down.addr(), sentinel.addr()}, MasterName: "dray", DB: 1}
+	client, err := c.dial("tcp", "")
+
+	assert.NoError(t, err)
+	client.Close()
+	assert.Equal(t, [][]string{{"sentinel", "get-master-addr-by-name", "dray"}}, sentinel.received())
+	assert.Equal(t, [][]string{{"select", "1"}, {"role"}}, master.received())
+}
+
+func TestRedisConfigDialSentinelReplica(t *testing.T) {
+	replica := newFakeRedis(t, roleReply("slave"))
+	defer replica.close()
+	sentinel := newFakeRedis(t, sentinelReply(replica.addr))
+	defer sentinel.close()
+
+	c := RedisConfig{Sentinels: []string{sentinel.addr()}, MasterName: "dray"}
+	_, err := c.dial("tcp", "")
+
+	assert.EqualError(t, err, "Redis server for master dray has the role slave")
+}
+
+func TestRedisConfigDialNoSentinels(t *testing.T) {
+	log.SetLevel(log.PanicLevel)
+
+	unknown := newFakeRedis(t, func(cmd []string) string { return "$-1\r\n" })
+	defer unknown.close()
+
+	c := RedisConfig{Sentinels: []string{unknown.addr()}, MasterName: "dray"}
+	_, err := c.dial("tcp", "")
+
+	assert.EqualError(t, err, "No Redis Sentinel could provide the address of master dray: unknown master dray")
+}
+
+func TestRepositoryReconnects(t *testing.T) {
+	var mu sync.Mutex
+	broken := false
+
+	f := newFakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if broken {
+			broken = false
+			return ""
+		}
+		return "+PONG\r\n"
+	})
+	defer f.close()
+
+	r := NewJobRepository(RedisConfig{Address: f.addr(), PoolSize: 2})
+
+	mu.Lock()
+	broken = true
+	mu.Unlock()
+
+	assert.EqualError(t, r.Ping(), "Redis connection error")
+	assert.NoError(t, r.Ping())
+
+	// Both pooled connections were discarded and replaced by a new one
+	assert.Equal(t, 3, f.connectionCount())
+}
+
+func TestRepositoryFailover(t *testing.T) {
+	var mu sync.Mutex
+	demoted := false
+
+	oldMaster := newFakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if demoted && cmd[0] != "role" {
+			return "-READONLY You can't write against a read only replica.\r\n"
+		}
+		return roleReply("master")(cmd)
+	})
+	defer oldMaster.close()
+	newMaster := newFakeRedis(t, roleReply("master"))
+	defer newMaster.close()
+
+	sentinel := newFakeRedis(t, sentinelReply(func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if demoted {
+			return newMaster.addr()
+		}
+		return oldMaster.addr()
+	}))
+	defer sentinel.close()
+
+	r := NewJobRepository(RedisConfig{Sentinels: []string{sentinel.addr()}, MasterName: "dray", PoolSize: 2})
+	assert.NoError(t, r.DeleteSchedule("123"))
+
+	mu.Lock()
+	demoted = true
+	mu.Unlock()
+
+	assert.EqualError(t, r.DeleteSchedule("123"), "READONLY You can't write against a read only replica.")
+	assert.NoError(t, r.DeleteSchedule("123"))
+	assert.Contains(t, newMaster.received(), []string{"role"})
+	assert.Equal(t, 1, newMaster.connectionCount())
+}
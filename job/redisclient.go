@@ -0,0 +1,255 @@
+package job
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/fzzy/radix/redis"
+	"github.com/fzzy/radix/redis/resp"
+)
+
+// The size of the buffer in which replies are read.
+const redisBufferSize = 4096
+
+// redisClient runs commands on a connection to Redis, which may be made with
+// TLS. Writing each command and reading its reply must take no longer than
+// the timeout, and the connection is closed if either fails.
+type redisClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+func newRedisClient(conn net.Conn, timeout time.Duration) *redisClient {
+	return &redisClient{
+		conn:    conn,
+		reader:  bufio.NewReaderSize(conn, redisBufferSize),
+		timeout: timeout,
+	}
+}
+
+// Cmd sends the command to Redis and waits for its reply. An error replied by
+// Redis is returned as a *redis.CmdError in the reply's Err, while any other
+// error means that the connection has failed.
+func (c *redisClient) Cmd(cmd string, args ...interface{}) *redisReply {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	request := append([]interface{}{cmd}, args...)
+	if err := resp.WriteArbitraryAsFlattenedStrings(c.conn, request); err != nil {
+		c.Close()
+		return &redisReply{Type: redis.ErrorReply, Err: err}
+	}
+
+	m, err := resp.ReadMessage(c.reader)
+	if err != nil {
+		c.Close()
+		return &redisReply{Type: redis.ErrorReply, Err: err}
+	}
+
+	reply, err := newRedisReply(m)
+	if err != nil {
+		return &redisReply{Type: redis.ErrorReply, Err: err}
+	}
+
+	return reply
+}
+
+// Close closes the connection.
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+// redisReply holds a reply from Redis. Its accessors behave as those of
+// radix's redis.Reply.
+type redisReply struct {
+	Type  redis.ReplyType
+	Elems []*redisReply
+	Err   error
+
+	buf []byte
+	num int64
+}
+
+func newRedisReply(m *resp.Message) (*redisReply, error) {
+	r := &redisReply{}
+	var err error
+
+	switch m.Type {
+	case resp.Err:
+		var msg error
+		if msg, err = m.Err(); err == nil {
+			r.Type = redis.ErrorReply
+			if strings.HasPrefix(msg.Error(), "LOADING") {
+				r.Err = redis.LoadingError
+			} else {
+				r.Err = &redis.CmdError{Err: msg}
+			}
+		}
+	case resp.SimpleStr:
+		r.Type = redis.StatusReply
+		r.buf, err = m.Bytes()
+	case resp.BulkStr:
+		r.Type = redis.BulkReply
+		r.buf, err = m.Bytes()
+	case resp.Int:
+		r.Type = redis.IntegerReply
+		r.num, err = m.Int()
+	case resp.Nil:
+		r.Type = redis.NilReply
+	case resp.Array:
+		var elems []*resp.Message
+		if elems, err = m.Array(); err == nil {
+			r.Type = redis.MultiReply
+			r.Elems = make([]*redisReply, len(elems))
+			for i := range elems {
+				if r.Elems[i], err = newRedisReply(elems[i]); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Bytes returns the value of a status or bulk reply.
+func (r *redisReply) Bytes() ([]byte, error) {
+	if r.Type == redis.ErrorReply {
+		return nil, r.Err
+	}
+
+	if r.Type != redis.StatusReply && r.Type != redis.BulkReply {
+		return nil, errors.New("string value is not available for this reply type")
+	}
+
+	return r.buf, nil
+}
+
+// Str returns the value of a status or bulk reply as a string.
+func (r *redisReply) Str() (string, error) {
+	b, err := r.Bytes()
+	return string(b), err
+}
+
+// Int returns the value of an integer reply.
+func (r *redisReply) Int() (int, error) {
+	if r.Type == redis.ErrorReply {
+		return 0, r.Err
+	}
+
+	if r.Type != redis.IntegerReply {
+		return 0, errors.New("integer value is not available for this reply type")
+	}
+
+	return int(r.num), nil
+}
+
+// List returns the elements of a multi-bulk reply as strings, with nil
+// elements returned as empty strings.
+func (r *redisReply) List() ([]string, error) {
+	if r.Type == redis.ErrorReply {
+		return nil, r.Err
+	}
+
+	if r.Type != redis.MultiReply {
+		return nil, errors.New("reply type is not MultiReply")
+	}
+
+	list := make([]string, len(r.Elems))
+	for i, e := range r.Elems {
+		switch e.Type {
+		case redis.BulkReply:
+			list[i] = string(e.buf)
+		case redis.NilReply:
+		default:
+			return nil, errors.New("element type is not BulkReply or NilReply")
+		}
+	}
+
+	return list, nil
+}
+
+// Hash returns a multi-bulk reply of alternating keys and values as a map.
+// Keys with nil values are left out.
+func (r *redisReply) Hash() (map[string]string, error) {
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list)%2 != 0 {
+		return nil, errors.New("reply has odd number of elements")
+	}
+
+	hash := map[string]string{}
+	for i := 0; i < len(list); i += 2 {
+		if r.Elems[i+1].Type == redis.NilReply {
+			continue
+		}
+		hash[list[i]] = list[i+1]
+	}
+
+	return hash, nil
+}
+
+// redisPool keeps idle connections to Redis for reuse. When none are idle a
+// new connection is dialled, and a connection which is returned to a full
+// pool is closed.
+type redisPool struct {
+	idle chan *redisClient
+	dial func() (*redisClient, error)
+}
+
+// Returns a pool of the given size, which is filled with new connections.
+func newRedisPool(size int, dial func() (*redisClient, error)) (*redisPool, error) {
+	p := &redisPool{idle: make(chan *redisClient, size), dial: dial}
+
+	for i := 0; i < size; i++ {
+		client, err := dial()
+		if err != nil {
+			p.Empty()
+			return nil, err
+		}
+		p.idle <- client
+	}
+
+	return p, nil
+}
+
+// Get returns an idle connection, or dials a new one if there are none.
+func (p *redisPool) Get() (*redisClient, error) {
+	select {
+	case client := <-p.idle:
+		return client, nil
+	default:
+		return p.dial()
+	}
+}
+
+// Put returns a working connection to the pool.
+func (p *redisPool) Put(client *redisClient) {
+	select {
+	case p.idle <- client:
+	default:
+		client.Close()
+	}
+}
+
+// Empty closes all of the idle connections.
+func (p *redisPool) Empty() {
+	for {
+		select {
+		case client := <-p.idle:
+			client.Close()
+		default:
+			return
+		}
+	}
+}
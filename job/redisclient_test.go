@@ -0,0 +1,127 @@
+package job
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fzzy/radix/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialFakeRedis(t *testing.T, f *fakeRedis) *redisClient {
+	conn, err := net.Dial("tcp", f.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newRedisClient(conn, time.Second)
+}
+
+func TestRedisClientCmd(t *testing.T) {
+	f := newFakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "get":
+			return "$3\r\nbar\r\n"
+		case "incr":
+			return ":42\r\n"
+		case "hgetall":
+			return "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$-1\r\n"
+		case "lrange":
+			return "*2\r\n$1\r\nx\r\n$-1\r\n"
+		default:
+			return "$-1\r\n"
+		}
+	})
+	defer f.close()
+
+	client := dialFakeRedis(t, f)
+	defer client.Close()
+
+	s, err := client.Cmd("get", "foo").Str()
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", s)
+
+	i, err := client.Cmd("incr", "foo").Int()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, i)
+
+	h, err := client.Cmd("hgetall", "foo").Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, h)
+
+	l, err := client.Cmd("lrange", "foo", 0, -1).List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x", ""}, l)
+
+	reply := client.Cmd("hget", "foo", "c")
+	assert.NoError(t, reply.Err)
+	assert.Equal(t, redis.NilReply, reply.Type)
+
+	assert.Equal(t, [][]string{{"get", "foo"}, {"incr", "foo"}, {"hgetall", "foo"}, {"lrange", "foo", "0", "-1"}, {"hget", "foo", "c"}}, f.received())
+}
+
+func TestRedisClientCmdError(t *testing.T) {
+	f := newFakeRedis(t, func(cmd []string) string {
+		return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	})
+	defer f.close()
+
+	client := dialFakeRedis(t, f)
+	defer client.Close()
+
+	reply := client.Cmd("get", "foo")
+	if cerr, ok := reply.Err.(*redis.CmdError); assert.True(t, ok) {
+		assert.EqualError(t, cerr, "WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+
+	_, err := reply.Str()
+	assert.Equal(t, reply.Err, err)
+
+	// The connection can still be used after a command error
+	assert.Error(t, client.Cmd("get", "foo").Err)
+	assert.Len(t, f.received(), 2)
+}
+
+func TestRedisClientConnectionError(t *testing.T) {
+	f := newFakeRedis(t, func(cmd []string) string { return "" })
+	defer f.close()
+
+	client := dialFakeRedis(t, f)
+
+	reply := client.Cmd("ping")
+	_, ok := reply.Err.(*redis.CmdError)
+	assert.Error(t, reply.Err)
+	assert.False(t, ok)
+}
+
+func TestRedisReplyWrongType(t *testing.T) {
+	reply := &redisReply{Type: redis.IntegerReply, num: 1}
+
+	_, err := reply.Str()
+	assert.EqualError(t, err, "string value is not available for this reply type")
+	_, err = reply.List()
+	assert.EqualError(t, err, "reply type is not MultiReply")
+}
+
+func TestRedisPool(t *testing.T) {
+	f := newFakeRedis(t, okReply)
+	defer f.close()
+
+	dial := func() (*redisClient, error) { return dialFakeRedis(t, f), nil }
+	p, err := newRedisPool(1, dial)
+	assert.NoError(t, err)
+
+	first, _ := p.Get()
+	second, _ := p.Get()
+	assert.NotEqual(t, first, second)
+
+	p.Put(first)
+	p.Put(second)
+
+	client, _ := p.Get()
+	assert.Equal(t, first, client)
+
+	// The second connection was closed when the pool was full
+	assert.Error(t, second.Cmd("ping").Err)
+}
@@ -0,0 +1,156 @@
+package job
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const defaultRegistry = "https://index.docker.io/v1/"
+
+// RegistryAuth holds the credentials used to pull images from private
+// registries. It is read from a file in the same format as the Docker
+// client's config.json: the Auths are keyed by registry host and are used
+// for every job. The optional Credentials are named sets of registry
+// credentials (keyed in the same way) which a job can reference by name in
+// order to pull its images with a different account.
+type RegistryAuth struct {
+	Auths       map[string]RegistryCredential            `json:"auths,omitempty"`
+	Credentials map[string]map[string]RegistryCredential `json:"credentials,omitempty"`
+}
+
+// RegistryCredential is the login for a single registry. The Auth field is
+// the base64 encoded "username:password" string written by "docker login"
+// and takes precedence over the Username and Password.
+type RegistryCredential struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// LoadRegistryAuth reads the registry credentials from the config.json-style
+// file at the specified path.
+func LoadRegistryAuth(path string) (RegistryAuth, error) {
+	ra := RegistryAuth{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ra, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&ra); err != nil {
+		return ra, fmt.Errorf("Error decoding registry auth file %s: %s", path, err)
+	}
+
+	credentials := map[string]map[string]RegistryCredential{"": ra.Auths}
+	for name, auths := range ra.Credentials {
+		credentials[name] = auths
+	}
+
+	for name, auths := range credentials {
+		for host, c := range auths {
+			if _, err := c.authConfiguration(host); err != nil {
+				if len(name) > 0 {
+					host = fmt.Sprintf("%s in %s", host, name)
+				}
+				return ra, fmt.Errorf("Invalid registry credentials for %s: %s", host, err)
+			}
+		}
+	}
+
+	return ra, nil
+}
+
+func (c RegistryCredential) authConfiguration(host string) (docker.AuthConfiguration, error) {
+	auth := docker.AuthConfiguration{
+		Username:      c.Username,
+		Password:      c.Password,
+		Email:         c.Email,
+		ServerAddress: host,
+	}
+
+	if len(c.Auth) > 0 {
+		b, err := base64.StdEncoding.DecodeString(c.Auth)
+		if err != nil {
+			return auth, err
+		}
+
+		parts := strings.SplitN(string(b), ":", 2)
+		if len(parts) != 2 {
+			return auth, fmt.Errorf("auth must be in the form \"username:password\"")
+		}
+
+		auth.Username, auth.Password = parts[0], parts[1]
+	}
+
+	return auth, nil
+}
+
+// Returns the credentials which should be used to pull the image. Credentials
+// in the named set take precedence over the server-wide Auths. An empty
+// AuthConfiguration is returned if no credentials are configured for the
+// image's registry.
+func (ra RegistryAuth) lookup(name, image string) docker.AuthConfiguration {
+	host := registryHost(image)
+
+	for _, auths := range []map[string]RegistryCredential{ra.Credentials[name], ra.Auths} {
+		for key, c := range auths {
+			if normalizeRegistry(key) == host {
+				auth, _ := c.authConfiguration(key)
+				return auth
+			}
+		}
+	}
+
+	return docker.AuthConfiguration{}
+}
+
+// Checks that the credentials referenced by the job have been configured.
+func (ra RegistryAuth) validate(v *validator, j *Job) {
+	if len(j.RegistryCredentials) == 0 {
+		return
+	}
+
+	if _, ok := ra.Credentials[j.RegistryCredentials]; !ok {
+		v.add("registryCredentials", "%s is not a configured set of registry credentials", j.RegistryCredentials)
+	}
+}
+
+// Returns the host of the registry from which the image is pulled. Following
+// the Docker convention, the first component of the image name is only
+// treated as a registry host if it contains a "." or ":" or is "localhost".
+func registryHost(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0]
+	}
+
+	return normalizeRegistry(defaultRegistry)
+}
+
+// Strips the scheme and path from a config.json key so that both
+// "registry.example.com" and "https://registry.example.com/v1/" match images
+// from that registry. The Docker Hub may also be referred to as "docker.io".
+func normalizeRegistry(key string) string {
+	if i := strings.Index(key, "://"); i >= 0 {
+		key = key[i+3:]
+	}
+
+	if i := strings.Index(key, "/"); i >= 0 {
+		key = key[:i]
+	}
+
+	switch key {
+	case "docker.io", "registry-1.docker.io":
+		return "index.docker.io"
+	}
+
+	return key
+}
@@ -0,0 +1,66 @@
+package job
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRegistryAuth(t *testing.T) {
+	f, _ := ioutil.TempFile("", "dray-auth")
+	defer os.Remove(f.Name())
+	f.WriteString(`{
+		"auths": {"https://index.docker.io/v1/": {"auth": "ZHJheTpzZWNyZXQ="}},
+		"credentials": {"team-a": {"registry.example.com": {"username": "a", "password": "b"}}}
+	}`)
+	f.Close()
+
+	ra, err := LoadRegistryAuth(f.Name())
+
+	assert.NoError(t, err)
+	assert.Equal(t, RegistryCredential{Auth: "ZHJheTpzZWNyZXQ="}, ra.Auths["https://index.docker.io/v1/"])
+	assert.Equal(t, RegistryCredential{Username: "a", Password: "b"}, ra.Credentials["team-a"]["registry.example.com"])
+}
+
+func TestLoadRegistryAuthInvalid(t *testing.T) {
+	f, _ := ioutil.TempFile("", "dray-auth")
+	defer os.Remove(f.Name())
+	f.WriteString(`{"credentials": {"team-a": {"registry.example.com": {"auth": "Zm9v"}}}}`)
+	f.Close()
+
+	_, err := LoadRegistryAuth(f.Name())
+
+	assert.EqualError(t, err, `Invalid registry credentials for registry.example.com in team-a: auth must be in the form "username:password"`)
+}
+
+func TestRegistryAuthLookup(t *testing.T) {
+	ra := RegistryAuth{
+		Auths: map[string]RegistryCredential{
+			"https://index.docker.io/v1/": {Auth: "ZHJheTpzZWNyZXQ="},
+			"localhost:5000":              {Username: "local", Password: "pass"},
+		},
+		Credentials: map[string]map[string]RegistryCredential{
+			"team-a": {"docker.io": {Username: "a", Password: "b"}},
+		},
+	}
+
+	assert.Equal(t, docker.AuthConfiguration{
+		Username:      "dray",
+		Password:      "secret",
+		ServerAddress: "https://index.docker.io/v1/",
+	}, ra.lookup("", "centurylink/dray"))
+	assert.Equal(t, "local", ra.lookup("team-a", "localhost:5000/foo:latest").Username)
+	assert.Equal(t, "a", ra.lookup("team-a", "busybox").Username)
+	assert.Equal(t, docker.AuthConfiguration{}, ra.lookup("", "quay.io/foo/bar"))
+}
+
+func TestRegistryHost(t *testing.T) {
+	assert.Equal(t, "index.docker.io", registryHost("busybox"))
+	assert.Equal(t, "index.docker.io", registryHost("centurylink/dray:latest"))
+	assert.Equal(t, "quay.io", registryHost("quay.io/foo/bar"))
+	assert.Equal(t, "localhost", registryHost("localhost/foo"))
+	assert.Equal(t, "registry:5000", registryHost("registry:5000/foo"))
+}
@@ -2,17 +2,30 @@ package job
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/fzzy/radix/extra/pool"
 	"github.com/fzzy/radix/redis"
 )
 
 const (
-	jobsKey = "jobs"
+	jobsKey       = "jobs"
+	namespacesKey = "namespaces"
+	templatesKey  = "templates"
+	schedulesKey  = "schedules"
+
+	// Claimed schedule runs are remembered long enough to outlast any restart
+	scheduleRunTTL = 7 * 24 * 60 * 60
+
+	defaultPoolSize = 4
+
+	// Sets the fields of the hash in KEYS[2] only if KEYS[1] exists
+	saveScheduleRunScript = `if redis.call("exists", KEYS[1]) == 1 then redis.call("hmset", KEYS[2], unpack(ARGV)) end`
 )
 
 // NotFoundError is an error returned when a referenced Job cannot be found.
@@ -24,25 +37,41 @@ func (s NotFoundError) Error() string {
 }
 
 type redisJobRepository struct {
-	pool *pool.Pool
+	pool      *redisPool
+	namespace string
 }
 
 // NewJobRepository returns a new JobRepository instance with a connection to
-// the specified Redis endpoint.
-func NewJobRepository(host string) JobRepository {
-	pool, err := pool.NewPool("tcp", host, 4)
+// the Redis server described by the RedisConfig.
+func NewJobRepository(c RedisConfig) JobRepository {
+	size := c.PoolSize
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+
+	pool, err := newRedisPool(size, func() (*redisClient, error) {
+		return c.dial("tcp", c.Address)
+	})
 	if err != nil {
 		log.Errorf("Error instantiating Redis pool: %s", err)
 		panic(err)
 	}
 
-	return &redisJobRepository{pool: pool}
+	return &redisJobRepository{pool: pool, namespace: DefaultNamespace}
+}
+
+func (r *redisJobRepository) Ping() error {
+	return r.command("ping").Err
+}
+
+func (r *redisJobRepository) InNamespace(namespace string) JobRepository {
+	return &redisJobRepository{pool: r.pool, namespace: namespaceOrDefault(namespace)}
 }
 
 func (r *redisJobRepository) All() ([]Job, error) {
 	jobs := []Job{}
 
-	jobIDs, err := r.command("lrange", jobsKey, 0, -1).List()
+	jobIDs, err := r.command("lrange", r.jobsKey(), 0, -1).List()
 	if err != nil {
 		return nil, err
 	}
@@ -55,8 +84,8 @@ func (r *redisJobRepository) All() ([]Job, error) {
 }
 
 func (r *redisJobRepository) Get(jobID string) (*Job, error) {
-	job := Job{ID: jobID}
-	reply := r.command("hgetall", jobKey(jobID))
+	job := Job{}
+	reply := r.command("hgetall", r.jobKey(jobID))
 
 	if len(reply.Elems) == 0 {
 		return nil, NotFoundError(jobID)
@@ -67,83 +96,386 @@ func (r *redisJobRepository) Get(jobID string) (*Job, error) {
 		return nil, err
 	}
 
-	job.StepsCompleted, _ = strconv.Atoi(status["completedSteps"])
-	job.Status = status["status"]
+	// Jobs created by older versions of Dray have no stored definition
+	if definition, ok := status["definition"]; ok {
+		if err := json.Unmarshal([]byte(definition), &job); err != nil {
+			return nil, err
+		}
+	}
+
+	job.ID = jobID
+	job.Namespace = r.namespace
+	job.StepsCompleted, _ = strconv.Atoi(status[fieldCompletedSteps])
+	job.Status = status[fieldStatus]
+
+	for i := range job.Steps {
+		job.Steps[i].Status = status[fmt.Sprintf(fieldStepStatus, i)]
+
+		if image, ok := status[fmt.Sprintf(fieldStepImage, i)]; ok {
+			job.Steps[i].ResolvedImage = &ImageRef{}
+			json.Unmarshal([]byte(image), job.Steps[i].ResolvedImage)
+		}
+	}
 	return &job, nil
 }
 
 func (r *redisJobRepository) Create(job *Job) error {
 	job.ID = pseudoUUID()
+	job.Namespace = r.namespace
 
-	reply := r.command("rpush", jobsKey, job.ID)
+	definition, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	reply := r.command("rpush", r.jobsKey(), job.ID)
 	if reply.Err != nil {
 		return reply.Err
 	}
 
-	totalSteps := string(len(job.Steps))
-	reply = r.command("hmset", jobKey(job.ID), "totalSteps", totalSteps, "completedSteps", "0", "status", "")
+	totalSteps := strconv.Itoa(len(job.Steps))
+	completedSteps := strconv.Itoa(job.StepsCompleted)
+	reply = r.command("hmset", r.jobKey(job.ID), "totalSteps", totalSteps, "completedSteps", completedSteps, "status", "", "definition", definition)
 	return reply.Err
 }
 
 func (r *redisJobRepository) Delete(jobID string) error {
-	reply := r.command("lrem", jobsKey, 0, jobID)
+	reply := r.command("lrem", r.jobsKey(), 0, jobID)
 	if reply.Err != nil {
 		return reply.Err
 	}
 
-	reply = r.command("del", jobKey(jobID))
+	reply = r.command("del", r.jobKey(jobID))
 	if reply.Err != nil {
 		return reply.Err
 	}
 
-	reply = r.command("del", jobLogKey(jobID))
+	reply = r.command("del", r.jobLogKey(jobID))
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	reply = r.command("del", r.jobServiceLogKey(jobID))
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	reply = r.command("del", r.jobOutputKey(jobID))
 	return reply.Err
 }
 
 func (r *redisJobRepository) Update(jobID, attr, value string) error {
-	reply := r.command("hset", jobKey(jobID), attr, value)
+	reply := r.command("hset", r.jobKey(jobID), attr, value)
 	return reply.Err
 }
 
 func (r *redisJobRepository) GetJobLog(jobID string, index int) (*JobLog, error) {
-	lines, err := r.command("lrange", jobLogKey(jobID), index, -1).List()
+	return r.getLog(r.jobLogKey(jobID), index)
+}
+
+func (r *redisJobRepository) AppendLogEntry(jobID string, entry LogEntry) error {
+	return r.appendLog(r.jobLogKey(jobID), entry)
+}
+
+func (r *redisJobRepository) GetServiceLog(jobID string, index int) (*JobLog, error) {
+	return r.getLog(r.jobServiceLogKey(jobID), index)
+}
+
+func (r *redisJobRepository) AppendServiceLogEntry(jobID string, entry LogEntry) error {
+	return r.appendLog(r.jobServiceLogKey(jobID), entry)
+}
+
+func (r *redisJobRepository) getLog(key string, index int) (*JobLog, error) {
+	lines, err := r.command("lrange", key, index, -1).List()
+	if err != nil {
+		return nil, err
+	}
+
+	jl := &JobLog{Index: index + len(lines), Entries: make([]LogEntry, len(lines))}
+	for i, line := range lines {
+		jl.Entries[i] = decodeLogEntry(line)
+	}
+
+	return jl, nil
+}
+
+func (r *redisJobRepository) appendLog(key string, entry LogEntry) error {
+	s, err := encodeLogEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	reply := r.command("rpush", key, s)
+	return reply.Err
+}
+
+func (r *redisJobRepository) GetStepOutput(jobID string) ([]byte, error) {
+	reply := r.command("get", r.jobOutputKey(jobID))
+	if reply.Type == redis.NilReply {
+		return []byte{}, nil
+	}
+
+	return reply.Bytes()
+}
+
+func (r *redisJobRepository) SaveStepOutput(jobID string, output []byte) error {
+	reply := r.command("set", r.jobOutputKey(jobID), output)
+	return reply.Err
+}
+
+func (r *redisJobRepository) AllTemplates() ([]JobTemplate, error) {
+	templates := []JobTemplate{}
+
+	names, err := r.command("sort", templatesKey, "alpha").List()
 	if err != nil {
 		return nil, err
 	}
 
-	return &JobLog{Lines: lines}, nil
+	for _, name := range names {
+		template, err := r.GetTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, *template)
+	}
+
+	return templates, nil
 }
 
-func (r *redisJobRepository) AppendLogLine(jobID, logLine string) error {
-	reply := r.command("rpush", jobLogKey(jobID), logLine)
+func (r *redisJobRepository) GetTemplate(name string) (*JobTemplate, error) {
+	reply := r.command("hget", templateKey(name), "definition")
+	if reply.Type == redis.NilReply {
+		return nil, TemplateNotFoundError(name)
+	}
+
+	definition, err := reply.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &JobTemplate{}
+	if err := json.Unmarshal(definition, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (r *redisJobRepository) SaveTemplate(template *JobTemplate) error {
+	version, err := r.command("hincrby", templateKey(template.Name), "version", 1).Int()
+	if err != nil {
+		return err
+	}
+
+	template.Version = version
+	definition, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+
+	reply := r.command("hset", templateKey(template.Name), "definition", definition)
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	reply = r.command("sadd", templatesKey, template.Name)
 	return reply.Err
 }
 
-func (r *redisJobRepository) command(cmd string, args ...interface{}) *redis.Reply {
+func (r *redisJobRepository) DeleteTemplate(name string) error {
+	reply := r.command("srem", templatesKey, name)
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	// The version counter is kept so that a re-created template does not
+	// reuse the version numbers recorded on existing jobs.
+	reply = r.command("hdel", templateKey(name), "definition")
+	return reply.Err
+}
+
+func (r *redisJobRepository) AllSchedules() ([]Schedule, error) {
+	schedules := []Schedule{}
+
+	scheduleIDs, err := r.command("sort", schedulesKey, "alpha").List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scheduleID := range scheduleIDs {
+		schedule, err := r.GetSchedule(scheduleID)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, *schedule)
+	}
+
+	return schedules, nil
+}
+
+func (r *redisJobRepository) GetSchedule(scheduleID string) (*Schedule, error) {
+	reply := r.command("get", scheduleKey(scheduleID))
+	if reply.Type == redis.NilReply {
+		return nil, ScheduleNotFoundError(scheduleID)
+	}
+
+	definition, err := reply.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &Schedule{}
+	if err := json.Unmarshal(definition, schedule); err != nil {
+		return nil, err
+	}
+
+	run, err := r.command("hgetall", scheduleRunKey(scheduleID)).Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := schedule.applyRun(run); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (r *redisJobRepository) SaveSchedule(schedule *Schedule) error {
+	if len(schedule.ID) == 0 {
+		schedule.ID = pseudoUUID()
+	}
+
+	definition, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	reply := r.command("set", scheduleKey(schedule.ID), definition)
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	// The definition now holds the run state
+	reply = r.command("del", scheduleRunKey(schedule.ID))
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	reply = r.command("sadd", schedulesKey, schedule.ID)
+	return reply.Err
+}
+
+// Records the run state of the schedule in a hash next to its definition so
+// that the definition is not overwritten with a copy which may have been
+// changed or deleted since it was read. Nothing is written if the schedule
+// no longer exists.
+func (r *redisJobRepository) SaveScheduleRun(schedule *Schedule) error {
+	args := []interface{}{saveScheduleRunScript, 2, scheduleKey(schedule.ID), scheduleRunKey(schedule.ID)}
+	run := schedule.run()
+	for _, field := range []string{"lastRun", "nextRun", "lastJobId"} {
+		if value, ok := run[field]; ok {
+			args = append(args, field, value)
+		}
+	}
+
+	return r.command("eval", args...).Err
+}
+
+func (r *redisJobRepository) DeleteSchedule(scheduleID string) error {
+	reply := r.command("srem", schedulesKey, scheduleID)
+	if reply.Err != nil {
+		return reply.Err
+	}
+
+	reply = r.command("del", scheduleKey(scheduleID), scheduleRunKey(scheduleID))
+	return reply.Err
+}
+
+func (r *redisJobRepository) ClaimScheduleRun(scheduleID string, runTime time.Time) (bool, error) {
+	key := fmt.Sprintf("%s:%d", scheduleKey(scheduleID), runTime.Unix())
+
+	reply := r.command("set", key, "1", "nx", "ex", scheduleRunTTL)
+	if reply.Err != nil {
+		return false, reply.Err
+	}
+
+	// A nil reply indicates that the key was already set
+	return reply.Type != redis.NilReply, nil
+}
+
+func (r *redisJobRepository) command(cmd string, args ...interface{}) *redisReply {
 	client, err := r.pool.Get()
 	if err != nil {
-		return &redis.Reply{Err: err}
+		log.Errorf("Error connecting to Redis: %s", err)
+		return &redisReply{Type: redis.ErrorReply, Err: errors.New("Redis connection error")}
 	}
-	defer r.pool.Put(client)
 
+	start := time.Now()
 	reply := client.Cmd(cmd, args...)
+	redisCommandDuration.ObserveSince(start, strings.ToLower(cmd))
+
+	cerr, ok := reply.Err.(*redis.CmdError)
+	if reply.Err == nil || (ok && !cerr.Readonly()) {
+		r.pool.Put(client)
+		return reply
+	}
+
+	// The connection is broken or the server has become a replica after a
+	// failover, so the idle connections are likely to be unusable too. They
+	// are all discarded so that new connections are made (to the current
+	// master, when Sentinels are used).
+	client.Close()
+	r.pool.Empty()
 
 	// Use a more friendly error message for connection problems
-	if reply.Err != nil {
-		if _, ok := reply.Err.(*redis.CmdError); !ok {
-			reply.Err = errors.New("Redis connection error")
-		}
+	if !ok {
+		reply.Err = errors.New("Redis connection error")
 	}
 
 	return reply
 }
 
-func jobKey(jobID string) string {
-	return fmt.Sprintf("%s:%s", jobsKey, jobID)
+// Returns the key of the list of the namespace's jobs, which also prefixes
+// the keys holding the data of each job. Jobs in the DefaultNamespace use the
+// keys from before namespaces were introduced so that existing jobs remain
+// available.
+func (r *redisJobRepository) jobsKey() string {
+	if r.namespace == DefaultNamespace {
+		return jobsKey
+	}
+
+	return fmt.Sprintf("%s:%s:%s", namespacesKey, r.namespace, jobsKey)
+}
+
+func (r *redisJobRepository) jobKey(jobID string) string {
+	return fmt.Sprintf("%s:%s", r.jobsKey(), jobID)
+}
+
+func (r *redisJobRepository) jobLogKey(jobID string) string {
+	return fmt.Sprintf("%s:%s:log", r.jobsKey(), jobID)
+}
+
+func (r *redisJobRepository) jobServiceLogKey(jobID string) string {
+	return fmt.Sprintf("%s:%s:services:log", r.jobsKey(), jobID)
+}
+
+func (r *redisJobRepository) jobOutputKey(jobID string) string {
+	return fmt.Sprintf("%s:%s:output", r.jobsKey(), jobID)
+}
+
+func templateKey(name string) string {
+	return fmt.Sprintf("%s:%s", templatesKey, name)
+}
+
+func scheduleKey(scheduleID string) string {
+	return fmt.Sprintf("%s:%s", schedulesKey, scheduleID)
 }
 
-func jobLogKey(jobID string) string {
-	return fmt.Sprintf("%s:%s:log", jobsKey, jobID)
+func scheduleRunKey(scheduleID string) string {
+	return fmt.Sprintf("%s:run", scheduleKey(scheduleID))
 }
 
 func pseudoUUID() (uuid string) {
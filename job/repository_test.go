@@ -1,11 +1,30 @@
 package job
 
 import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 type mockRepository struct {
 	mock.Mock
+
+	// namespaces records the namespace of each view of the repository
+	// which has been requested
+	namespaces []string
+}
+
+func (m *mockRepository) InNamespace(namespace string) JobRepository {
+	m.namespaces = append(m.namespaces, namespace)
+	return m
+}
+
+func (m *mockRepository) Ping() error {
+	args := m.Mock.Called()
+	return args.Error(0)
 }
 
 func (m *mockRepository) All() ([]Job, error) {
@@ -38,7 +57,127 @@ func (m *mockRepository) GetJobLog(jobID string, index int) (*JobLog, error) {
 	return args.Get(0).(*JobLog), args.Error(1)
 }
 
-func (m *mockRepository) AppendLogLine(jobID, logLine string) error {
-	args := m.Mock.Called(jobID, logLine)
+func (m *mockRepository) AppendLogEntry(jobID string, entry LogEntry) error {
+	args := m.Mock.Called(jobID, entry)
 	return args.Error(0)
 }
+
+func (m *mockRepository) GetServiceLog(jobID string, index int) (*JobLog, error) {
+	args := m.Mock.Called(jobID, index)
+	return args.Get(0).(*JobLog), args.Error(1)
+}
+
+func (m *mockRepository) AppendServiceLogEntry(jobID string, entry LogEntry) error {
+	args := m.Mock.Called(jobID, entry)
+	return args.Error(0)
+}
+
+func (m *mockRepository) GetStepOutput(jobID string) ([]byte, error) {
+	args := m.Mock.Called(jobID)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockRepository) SaveStepOutput(jobID string, output []byte) error {
+	args := m.Mock.Called(jobID, output)
+	return args.Error(0)
+}
+
+func (m *mockRepository) AllTemplates() ([]JobTemplate, error) {
+	args := m.Mock.Called()
+	return args.Get(0).([]JobTemplate), args.Error(1)
+}
+
+func (m *mockRepository) GetTemplate(name string) (*JobTemplate, error) {
+	args := m.Mock.Called(name)
+	return args.Get(0).(*JobTemplate), args.Error(1)
+}
+
+func (m *mockRepository) SaveTemplate(template *JobTemplate) error {
+	args := m.Mock.Called(template)
+	return args.Error(0)
+}
+
+func (m *mockRepository) DeleteTemplate(name string) error {
+	args := m.Mock.Called(name)
+	return args.Error(0)
+}
+
+func (m *mockRepository) AllSchedules() ([]Schedule, error) {
+	args := m.Mock.Called()
+	return args.Get(0).([]Schedule), args.Error(1)
+}
+
+func (m *mockRepository) GetSchedule(scheduleID string) (*Schedule, error) {
+	args := m.Mock.Called(scheduleID)
+	return args.Get(0).(*Schedule), args.Error(1)
+}
+
+func (m *mockRepository) SaveSchedule(schedule *Schedule) error {
+	args := m.Mock.Called(schedule)
+	return args.Error(0)
+}
+
+func (m *mockRepository) SaveScheduleRun(schedule *Schedule) error {
+	args := m.Mock.Called(schedule)
+	return args.Error(0)
+}
+
+func (m *mockRepository) DeleteSchedule(scheduleID string) error {
+	args := m.Mock.Called(scheduleID)
+	return args.Error(0)
+}
+
+func (m *mockRepository) ClaimScheduleRun(scheduleID string, runTime time.Time) (bool, error) {
+	args := m.Mock.Called(scheduleID, runTime)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestRepositoryJobKeys(t *testing.T) {
+	r := &redisJobRepository{namespace: DefaultNamespace}
+	teamA := r.InNamespace("team-a").(*redisJobRepository)
+
+	assert.Equal(t, "jobs", r.jobsKey())
+	assert.Equal(t, "jobs:123", r.jobKey("123"))
+	assert.Equal(t, "jobs:123:log", r.jobLogKey("123"))
+	assert.Equal(t, "namespaces:team-a:jobs", teamA.jobsKey())
+	assert.Equal(t, "namespaces:team-a:jobs:123", teamA.jobKey("123"))
+	assert.Equal(t, "namespaces:team-a:jobs:123:services:log", teamA.jobServiceLogKey("123"))
+	assert.Equal(t, "namespaces:team-a:jobs:123:output", teamA.jobOutputKey("123"))
+	assert.Equal(t, "jobs", r.InNamespace("").(*redisJobRepository).jobsKey())
+}
+
+func TestRepositorySaveScheduleRun(t *testing.T) {
+	f := newFakeRedis(t, func(cmd []string) string { return "$-1\r\n" })
+	defer f.close()
+
+	r := NewJobRepository(RedisConfig{Address: f.addr()})
+	nextRun := time.Date(2015, time.March, 19, 11, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, r.SaveScheduleRun(&Schedule{ID: "abc", Cron: "@hourly", NextRun: &nextRun}))
+
+	cmd := f.received()[0]
+	assert.Equal(t, "eval", cmd[0])
+	assert.Equal(t, []string{"2", "schedules:abc", "schedules:abc:run", "nextRun", "2015-03-19T11:00:00Z"}, cmd[2:])
+}
+
+func TestRepositoryGetScheduleRun(t *testing.T) {
+	definition := `{"id":"abc","cron":"@hourly","template":"foo","lastJobId":"123"}`
+
+	f := newFakeRedis(t, func(cmd []string) string {
+		if cmd[0] == "get" {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(definition), definition)
+		}
+		return "*4\r\n$9\r\nlastJobId\r\n$3\r\n456\r\n$7\r\nlastRun\r\n$20\r\n2015-03-19T10:00:00Z\r\n"
+	})
+	defer f.close()
+
+	r := NewJobRepository(RedisConfig{Address: f.addr()})
+	schedule, err := r.GetSchedule("abc")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", schedule.Template)
+	assert.Equal(t, "456", schedule.LastJobID)
+	assert.Equal(t, time.Date(2015, time.March, 19, 10, 0, 0, 0, time.UTC), *schedule.LastRun)
+	assert.Nil(t, schedule.NextRun)
+	assert.Equal(t, []string{"hgetall", "schedules:abc:run"}, f.received()[1])
+}
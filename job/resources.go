@@ -0,0 +1,222 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Resources describes the limits placed on the container which executes a
+// job step. Memory and MemorySwap are specified in bytes (a MemorySwap of -1
+// allows unlimited swap), CPUShares is a relative weight and CPUQuota is the
+// number of microseconds of CPU time allowed per 100ms period. A zero value
+// leaves the corresponding limit unset.
+type Resources struct {
+	Memory     int64    `json:"memory,omitempty"`
+	MemorySwap int64    `json:"memorySwap,omitempty"`
+	CPUShares  int64    `json:"cpuShares,omitempty"`
+	CPUQuota   int64    `json:"cpuQuota,omitempty"`
+	PidsLimit  int64    `json:"pidsLimit,omitempty"`
+	Ulimits    []Ulimit `json:"ulimits,omitempty"`
+}
+
+// Ulimit is a resource limit (e.g. "nofile") applied to the processes in a
+// container.
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// ResourcePolicy holds the server-wide resource settings for job steps. The
+// Defaults are applied to any limit which is not specified by a step while
+// the Maximums are limits which no step may exceed. A step which does not
+// specify a limit and has no default is given the maximum.
+type ResourcePolicy struct {
+	Defaults Resources
+	Maximums Resources
+}
+
+// ParseUlimits parses a comma-separated list of ulimits in the same
+// "name=soft[:hard]" format used by the Docker CLI.
+func ParseUlimits(s string) ([]Ulimit, error) {
+	ulimits := []Ulimit{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("Invalid ulimit %q", item)
+		}
+
+		limits := strings.SplitN(parts[1], ":", 2)
+		soft, err := strconv.ParseInt(limits[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ulimit %q", item)
+		}
+
+		hard := soft
+		if len(limits) == 2 {
+			if hard, err = strconv.ParseInt(limits[1], 10, 64); err != nil {
+				return nil, fmt.Errorf("Invalid ulimit %q", item)
+			}
+		}
+
+		ulimits = append(ulimits, Ulimit{Name: parts[0], Soft: soft, Hard: hard})
+	}
+
+	return ulimits, nil
+}
+
+func (r Resources) validate(v *validator, path string) {
+	limits := []struct {
+		field string
+		value int64
+	}{
+		{"memory", r.Memory},
+		{"cpuShares", r.CPUShares},
+		{"cpuQuota", r.CPUQuota},
+		{"pidsLimit", r.PidsLimit},
+	}
+
+	for _, l := range limits {
+		if l.value < 0 {
+			v.add(path+"."+l.field, "must not be negative")
+		}
+	}
+
+	if r.MemorySwap < -1 {
+		v.add(path+".memorySwap", "must be -1 or a positive number of bytes")
+	} else if r.MemorySwap != 0 && r.Memory <= 0 {
+		v.add(path+".memorySwap", "requires memory to be set")
+	} else if r.MemorySwap > 0 && r.MemorySwap < r.Memory {
+		v.add(path+".memorySwap", "must not be less than memory")
+	}
+
+	if r.CPUQuota > 0 && r.CPUQuota < 1000 {
+		v.add(path+".cpuQuota", "must be at least 1000")
+	}
+
+	for i, u := range r.Ulimits {
+		ulimitPath := fmt.Sprintf("%s.ulimits[%d]", path, i)
+
+		if len(u.Name) == 0 {
+			v.add(ulimitPath+".name", msgRequired)
+		}
+
+		if u.Soft > u.Hard {
+			v.add(ulimitPath+".soft", "must not exceed hard")
+		}
+	}
+}
+
+func (r Resources) isZero() bool {
+	return r.Memory == 0 && r.MemorySwap == 0 && r.CPUShares == 0 &&
+		r.CPUQuota == 0 && r.PidsLimit == 0 && len(r.Ulimits) == 0
+}
+
+func (r Resources) ulimit(name string) *Ulimit {
+	for i := range r.Ulimits {
+		if r.Ulimits[i].Name == name {
+			return &r.Ulimits[i]
+		}
+	}
+
+	return nil
+}
+
+// Fills in any limits which are not specified by the job's steps and
+// services with the default (or maximum) value.
+func (p ResourcePolicy) applyDefaults(j *Job) {
+	for i := range j.Steps {
+		j.Steps[i].Resources = p.withDefaults(j.Steps[i].Resources)
+	}
+
+	for i := range j.Services {
+		j.Services[i].Resources = p.withDefaults(j.Services[i].Resources)
+	}
+}
+
+// Returns a copy of the limits with the defaults filled in, or nil if there
+// are no limits.
+func (p ResourcePolicy) withDefaults(limits *Resources) *Resources {
+	r := Resources{}
+	if limits != nil {
+		r = *limits
+		r.Ulimits = append([]Ulimit(nil), r.Ulimits...)
+	}
+
+	r.Memory = firstSet(r.Memory, p.Defaults.Memory, p.Maximums.Memory)
+	r.MemorySwap = firstSet(r.MemorySwap, p.Defaults.MemorySwap, p.Maximums.MemorySwap)
+	r.CPUShares = firstSet(r.CPUShares, p.Defaults.CPUShares, p.Maximums.CPUShares)
+	r.CPUQuota = firstSet(r.CPUQuota, p.Defaults.CPUQuota, p.Maximums.CPUQuota)
+	r.PidsLimit = firstSet(r.PidsLimit, p.Defaults.PidsLimit, p.Maximums.PidsLimit)
+
+	for _, ulimits := range [][]Ulimit{p.Defaults.Ulimits, p.Maximums.Ulimits} {
+		for _, u := range ulimits {
+			if r.ulimit(u.Name) == nil {
+				r.Ulimits = append(r.Ulimits, u)
+			}
+		}
+	}
+
+	if r.isZero() {
+		return limits
+	}
+
+	return &r
+}
+
+// Checks that none of the job's steps or services exceed the maximum limits.
+func (p ResourcePolicy) validate(v *validator, j *Job) {
+	for i, step := range j.Steps {
+		p.checkMaximums(v, fmt.Sprintf("steps[%d].resources", i), step.Resources)
+	}
+
+	for i, svc := range j.Services {
+		p.checkMaximums(v, fmt.Sprintf("services[%d].resources", i), svc.Resources)
+	}
+}
+
+func (p ResourcePolicy) checkMaximums(v *validator, path string, limits *Resources) {
+	max := p.Maximums
+
+	r := Resources{}
+	if limits != nil {
+		r = *limits
+	}
+
+	checkMaximum(v, path+".memory", r.Memory, max.Memory)
+	checkMaximum(v, path+".memorySwap", r.MemorySwap, max.MemorySwap)
+	checkMaximum(v, path+".cpuShares", r.CPUShares, max.CPUShares)
+	checkMaximum(v, path+".cpuQuota", r.CPUQuota, max.CPUQuota)
+	checkMaximum(v, path+".pidsLimit", r.PidsLimit, max.PidsLimit)
+
+	for n, u := range r.Ulimits {
+		if m := max.ulimit(u.Name); m != nil && (u.Hard > m.Hard || u.Hard < 0) {
+			v.add(fmt.Sprintf("%s.ulimits[%d].hard", path, n), "must not exceed %d", m.Hard)
+		}
+	}
+}
+
+// A value which has not been set (or is negative, meaning unlimited) exceeds
+// any configured maximum.
+func checkMaximum(v *validator, field string, value, max int64) {
+	if max > 0 && (value > max || value <= 0) {
+		v.add(field, "must not exceed %d", max)
+	}
+}
+
+func firstSet(values ...int64) int64 {
+	for _, value := range values {
+		if value != 0 {
+			return value
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,106 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUlimits(t *testing.T) {
+	ulimits, err := ParseUlimits("nofile=1024:2048, nproc=512")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Ulimit{
+		{Name: "nofile", Soft: 1024, Hard: 2048},
+		{Name: "nproc", Soft: 512, Hard: 512},
+	}, ulimits)
+}
+
+func TestParseUlimitsInvalid(t *testing.T) {
+	for _, s := range []string{"nofile", "=1", "nofile=x", "nofile=1:x"} {
+		_, err := ParseUlimits(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestResourcesValidate(t *testing.T) {
+	j := Job{
+		Steps: []JobStep{
+			{
+				Source: "foo",
+				Resources: &Resources{
+					Memory:     -1,
+					MemorySwap: 1024,
+					CPUQuota:   10,
+					Ulimits:    []Ulimit{{Soft: 2, Hard: 1}},
+				},
+			},
+			{Source: "bar", Resources: &Resources{Memory: 2048, MemorySwap: 1024, PidsLimit: -5}},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "steps[0].resources.memory", Message: "must not be negative"},
+		{Field: "steps[0].resources.memorySwap", Message: "requires memory to be set"},
+		{Field: "steps[0].resources.cpuQuota", Message: "must be at least 1000"},
+		{Field: "steps[0].resources.ulimits[0].name", Message: "required"},
+		{Field: "steps[0].resources.ulimits[0].soft", Message: "must not exceed hard"},
+		{Field: "steps[1].resources.pidsLimit", Message: "must not be negative"},
+		{Field: "steps[1].resources.memorySwap", Message: "must not be less than memory"},
+	}, j.Validate())
+}
+
+func TestResourcePolicyApplyDefaults(t *testing.T) {
+	p := ResourcePolicy{
+		Defaults: Resources{Memory: 100, Ulimits: []Ulimit{{Name: "nofile", Soft: 10, Hard: 10}}},
+		Maximums: Resources{Memory: 200, PidsLimit: 50, Ulimits: []Ulimit{{Name: "nproc", Soft: 5, Hard: 5}}},
+	}
+	custom := &Resources{Memory: 150, Ulimits: []Ulimit{{Name: "nproc", Soft: 1, Hard: 2}}}
+	j := &Job{Steps: []JobStep{{}, {Resources: custom}}, Services: []Service{{}}}
+
+	p.applyDefaults(j)
+
+	assert.Equal(t, &Resources{
+		Memory:    100,
+		PidsLimit: 50,
+		Ulimits:   []Ulimit{{Name: "nofile", Soft: 10, Hard: 10}, {Name: "nproc", Soft: 5, Hard: 5}},
+	}, j.Steps[0].Resources)
+	assert.Equal(t, &Resources{
+		Memory:    150,
+		PidsLimit: 50,
+		Ulimits:   []Ulimit{{Name: "nproc", Soft: 1, Hard: 2}, {Name: "nofile", Soft: 10, Hard: 10}},
+	}, j.Steps[1].Resources)
+	assert.Equal(t, j.Steps[0].Resources, j.Services[0].Resources)
+	assert.Len(t, custom.Ulimits, 1)
+}
+
+func TestResourcePolicyApplyDefaultsNone(t *testing.T) {
+	j := &Job{Steps: []JobStep{{}}}
+
+	ResourcePolicy{}.applyDefaults(j)
+
+	assert.Nil(t, j.Steps[0].Resources)
+}
+
+func TestResourcePolicyValidate(t *testing.T) {
+	p := ResourcePolicy{
+		Maximums: Resources{MemorySwap: 200, CPUQuota: 50000, Ulimits: []Ulimit{{Name: "nofile", Hard: 100}}},
+	}
+	j := &Job{
+		Steps: []JobStep{
+			{Resources: &Resources{MemorySwap: 200, CPUQuota: 50000}},
+			{Resources: &Resources{MemorySwap: -1, CPUQuota: 60000, Ulimits: []Ulimit{{Name: "nofile", Hard: 101}}}},
+		},
+		Services: []Service{{Resources: &Resources{MemorySwap: 300, CPUQuota: 50000}}},
+	}
+	v := &validator{}
+
+	p.validate(v, j)
+
+	assert.Equal(t, ValidationError{
+		{Field: "steps[1].resources.memorySwap", Message: "must not exceed 200"},
+		{Field: "steps[1].resources.cpuQuota", Message: "must not exceed 50000"},
+		{Field: "steps[1].resources.ulimits[0].hard", Message: "must not exceed 100"},
+		{Field: "services[0].resources.memorySwap", Message: "must not exceed 200"},
+	}, v.err())
+}
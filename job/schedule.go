@@ -0,0 +1,139 @@
+package job
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	concurrencyAllow   = "allow"
+	concurrencyForbid  = "forbid"
+	concurrencyReplace = "replace"
+)
+
+// ScheduleNotFoundError is an error returned when a referenced Schedule
+// cannot be found.
+type ScheduleNotFoundError string
+
+// Error returns the error string for the ScheduleNotFoundError
+func (s ScheduleNotFoundError) Error() string {
+	return fmt.Sprintf("Cannot find schedule with ID %s", string(s))
+}
+
+// Schedule describes a job which should be created and executed periodically.
+// The job to be executed is either the named Template (instantiated with the
+// given Parameters) or the inline Job definition. The Cron field is a standard
+// five-field cron expression which is evaluated in the specified Timezone
+// (UTC by default).
+//
+// The ConcurrencyPolicy field controls what happens when a run is due while
+// the job from the previous run is still executing: "allow" (the default)
+// starts the new job anyway, "forbid" skips the new run and "replace" cancels
+// the running job before starting the new one.
+//
+// Jobs are created in the schedule's Namespace (or the DefaultNamespace if it
+// is empty).
+type Schedule struct {
+	ID                string            `json:"id,omitempty"`
+	Name              string            `json:"name,omitempty"`
+	Cron              string            `json:"cron"`
+	Timezone          string            `json:"timezone,omitempty"`
+	ConcurrencyPolicy string            `json:"concurrencyPolicy,omitempty"`
+	Template          string            `json:"template,omitempty"`
+	Parameters        map[string]string `json:"parameters,omitempty"`
+	Job               *Job              `json:"job,omitempty"`
+	Namespace         string            `json:"namespace,omitempty"`
+	LastRun           *time.Time        `json:"lastRun,omitempty"`
+	LastJobID         string            `json:"lastJobId,omitempty"`
+	NextRun           *time.Time        `json:"nextRun,omitempty"`
+}
+
+// Validate checks that the cron expression, timezone and concurrency policy
+// are valid and that exactly one of Template or Job has been specified. A
+// cron expression which never matches a date (such as "0 0 30 2 *") is
+// rejected.
+func (s Schedule) Validate() error {
+	v := &validator{}
+
+	if spec, err := parseCron(s.Cron); err != nil {
+		v.add("cron", "%s", err)
+	} else if spec.next(time.Now()).IsZero() {
+		v.add("cron", "never matches a date")
+	}
+
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		v.add("timezone", "unknown timezone %s", s.Timezone)
+	}
+
+	switch s.ConcurrencyPolicy {
+	case "", concurrencyAllow, concurrencyForbid, concurrencyReplace:
+	default:
+		v.add("concurrencyPolicy", "must be \"allow\", \"forbid\" or \"replace\"")
+	}
+
+	if len(s.Namespace) > 0 && !ValidNamespace(s.Namespace) {
+		v.add("namespace", "must consist of lowercase letters, digits and hyphens")
+	}
+
+	if (len(s.Template) > 0) == (s.Job != nil) {
+		v.add("template", "either template or job is required")
+	}
+
+	if s.Job != nil {
+		v.nest("job", s.Job.Validate())
+	}
+
+	return v.err()
+}
+
+// nextRunAfter returns the first time after t at which the schedule should
+// fire. The schedule must be valid.
+func (s Schedule) nextRunAfter(t time.Time) time.Time {
+	spec, _ := parseCron(s.Cron)
+	loc, _ := time.LoadLocation(s.Timezone)
+
+	return spec.next(t.In(loc)).UTC()
+}
+
+// run returns the state of the schedule's runs, as recorded by the scheduler.
+func (s Schedule) run() map[string]string {
+	run := map[string]string{}
+
+	if s.LastRun != nil {
+		run["lastRun"] = s.LastRun.Format(time.RFC3339Nano)
+	}
+
+	if s.NextRun != nil {
+		run["nextRun"] = s.NextRun.Format(time.RFC3339Nano)
+	}
+
+	if len(s.LastJobID) > 0 {
+		run["lastJobId"] = s.LastJobID
+	}
+
+	return run
+}
+
+// applyRun overwrites the state of the schedule's runs with that recorded by
+// the scheduler.
+func (s *Schedule) applyRun(run map[string]string) error {
+	for field, value := range run {
+		switch field {
+		case "lastRun", "nextRun":
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return err
+			}
+
+			if field == "lastRun" {
+				s.LastRun = &t
+			} else {
+				s.NextRun = &t
+			}
+		case "lastJobId":
+			s.LastJobID = value
+		}
+	}
+
+	return nil
+}
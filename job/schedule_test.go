@@ -0,0 +1,37 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	s := Schedule{Cron: "@daily", Template: "foo"}
+	assert.NoError(t, s.Validate())
+
+	s = Schedule{Cron: "* * *", Timezone: "Nowhere/Special", ConcurrencyPolicy: "sometimes", Job: &Job{}}
+	assert.Equal(t, ValidationError{
+		{Field: "cron", Message: "Expected 5 fields in cron expression, found 3"},
+		{Field: "timezone", Message: "unknown timezone Nowhere/Special"},
+		{Field: "concurrencyPolicy", Message: "must be \"allow\", \"forbid\" or \"replace\""},
+		{Field: "job.steps", Message: "required"},
+	}, s.Validate())
+
+	s = Schedule{Cron: "@daily"}
+	assert.Equal(t, NewValidationError("template", "either template or job is required"), s.Validate())
+
+	s = Schedule{Cron: "0 0 30 2 *", Template: "foo"}
+	assert.Equal(t, NewValidationError("cron", "never matches a date"), s.Validate())
+
+	s = Schedule{Cron: "@daily", Template: "foo", Namespace: "-team"}
+	assert.Equal(t, NewValidationError("namespace", "must consist of lowercase letters, digits and hyphens"), s.Validate())
+}
+
+func TestScheduleNextRunAfter(t *testing.T) {
+	s := Schedule{Cron: "0 2 * * *", Timezone: "UTC"}
+	now := time.Date(2015, time.March, 19, 10, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2015, time.March, 20, 2, 0, 0, 0, time.UTC), s.nextRunAfter(now))
+}
@@ -0,0 +1,172 @@
+package job
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const schedulerInterval = time.Second
+
+// Scheduler periodically creates and executes the jobs described by the
+// persisted schedules.
+type Scheduler interface {
+	Start()
+	Stop()
+}
+
+type jobScheduler struct {
+	repository JobRepository
+	jobManager JobManager
+	now        func() time.Time
+	done       chan struct{}
+}
+
+// NewScheduler returns a Scheduler which reads schedules from the specified
+// JobRepository and uses the JobManager to create and execute jobs.
+func NewScheduler(r JobRepository, jm JobManager) Scheduler {
+	return &jobScheduler{
+		repository: r,
+		jobManager: jm,
+		now:        time.Now,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start begins checking for due schedules in the background.
+func (s *jobScheduler) Start() {
+	log.Info("Scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.runDue()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the scheduler. Jobs which have already been started are not
+// affected.
+func (s *jobScheduler) Stop() {
+	close(s.done)
+}
+
+func (s *jobScheduler) runDue() {
+	schedules, err := s.repository.AllSchedules()
+	if err != nil {
+		log.Errorf("Error retrieving schedules: %s", err)
+		return
+	}
+
+	now := s.now()
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		// A schedule whose cron expression can no longer match has a zero
+		// next run, which must not count as due
+		if schedule.NextRun == nil || schedule.NextRun.IsZero() || schedule.NextRun.After(now) {
+			continue
+		}
+
+		if err := s.run(schedule, now); err != nil {
+			log.Errorf("Error running schedule %s: %s", schedule.ID, err)
+		}
+	}
+}
+
+// Fires the schedule for the run time recorded in its NextRun field. Runs
+// which were missed while Dray was not running are collapsed into a single
+// run. The run is claimed in the repository first so that a run which was
+// already fired before a restart is not fired again. Only the run state of
+// the schedule is saved, so that changes made to it in the meantime are kept.
+func (s *jobScheduler) run(schedule *Schedule, now time.Time) error {
+	runTime := *schedule.NextRun
+
+	claimed, err := s.repository.ClaimScheduleRun(schedule.ID, runTime)
+	if err != nil {
+		return err
+	}
+
+	if claimed {
+		if err := s.fire(schedule); err != nil {
+			log.Errorf("Error starting job for schedule %s: %s", schedule.ID, err)
+		}
+
+		schedule.LastRun = &runTime
+	}
+
+	next := schedule.nextRunAfter(now)
+	schedule.NextRun = &next
+
+	return s.repository.SaveScheduleRun(schedule)
+}
+
+func (s *jobScheduler) fire(schedule *Schedule) error {
+	if len(schedule.LastJobID) > 0 && schedule.ConcurrencyPolicy != concurrencyAllow && schedule.ConcurrencyPolicy != "" {
+		previous, err := s.jobManager.GetByID(schedule.Namespace, schedule.LastJobID)
+
+		if err == nil && previous.active() {
+			if schedule.ConcurrencyPolicy == concurrencyForbid {
+				log.Infof("Skipping schedule %s, job %s is still running", schedule.ID, previous.ID)
+				return nil
+			}
+
+			err := s.jobManager.Cancel(previous)
+			if _, ok := err.(NotRunningError); ok {
+				log.Warnf("Unable to replace job %s, it is not running in this instance", previous.ID)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+
+	j, err := s.newJob(schedule)
+	if err != nil {
+		return err
+	}
+
+	if err := s.jobManager.Create(j); err != nil {
+		return err
+	}
+
+	log.Infof("Schedule %s started job %s", schedule.ID, j.ID)
+	schedule.LastJobID = j.ID
+
+	go func() {
+		if err := s.jobManager.Execute(j); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *jobScheduler) newJob(schedule *Schedule) (*Job, error) {
+	var j *Job
+
+	if len(schedule.Template) > 0 {
+		t, err := s.jobManager.GetTemplate(schedule.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		if j, err = t.Instantiate(schedule.Parameters); err != nil {
+			return nil, err
+		}
+	} else {
+		inline := *schedule.Job
+		inline.Steps = append([]JobStep{}, schedule.Job.Steps...)
+		j = &inline
+	}
+
+	j.Schedule = schedule.ID
+	j.Namespace = schedule.Namespace
+	return j, nil
+}
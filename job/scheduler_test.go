@@ -0,0 +1,212 @@
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockJobManager struct {
+	mock.Mock
+	JobManager
+
+	executed chan *Job
+}
+
+func (m *mockJobManager) GetByID(namespace, jobID string) (*Job, error) {
+	args := m.Mock.Called(namespace, jobID)
+	return args.Get(0).(*Job), args.Error(1)
+}
+
+func (m *mockJobManager) Create(job *Job) error {
+	args := m.Mock.Called(job)
+	job.ID = "456"
+	return args.Error(0)
+}
+
+// Execute signals each call on the executed channel, as jobs are executed in
+// the background.
+func (m *mockJobManager) Execute(job *Job) error {
+	args := m.Mock.Called(job)
+	m.executed <- job
+	return args.Error(0)
+}
+
+func (m *mockJobManager) Cancel(job *Job) error {
+	args := m.Mock.Called(job)
+	return args.Error(0)
+}
+
+func (m *mockJobManager) GetTemplate(name string) (*JobTemplate, error) {
+	args := m.Mock.Called(name)
+	return args.Get(0).(*JobTemplate), args.Error(1)
+}
+
+type SchedulerTestSuite struct {
+	suite.Suite
+
+	now      time.Time
+	schedule Schedule
+	s        *jobScheduler
+	r        *mockRepository
+	jm       *mockJobManager
+}
+
+func (suite *SchedulerTestSuite) SetupTest() {
+	suite.now = time.Date(2015, time.March, 19, 10, 30, 0, 0, time.UTC)
+	nextRun := time.Date(2015, time.March, 19, 10, 0, 0, 0, time.UTC)
+
+	suite.schedule = Schedule{
+		ID:        "abc",
+		Cron:      "0 * * * *",
+		Job:       &Job{Name: "foo", Steps: []JobStep{{Source: "foo/bar"}}},
+		NextRun:   &nextRun,
+		LastJobID: "123",
+	}
+
+	suite.r = &mockRepository{}
+	suite.jm = &mockJobManager{executed: make(chan *Job, 1)}
+	suite.s = &jobScheduler{
+		repository: suite.r,
+		jobManager: suite.jm,
+		now:        func() time.Time { return suite.now },
+	}
+}
+
+func (suite *SchedulerTestSuite) TearDownTest() {
+	suite.r.Mock.AssertExpectations(suite.T())
+	suite.jm.Mock.AssertExpectations(suite.T())
+}
+
+func (suite *SchedulerTestSuite) TestRunDueFires() {
+	runTime := *suite.schedule.NextRun
+	schedules := []Schedule{suite.schedule}
+
+	suite.r.On("AllSchedules").Return(schedules, nil)
+	suite.r.On("ClaimScheduleRun", "abc", runTime).Return(true, nil)
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.jm.On("Execute", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.r.On("SaveScheduleRun", &schedules[0]).Return(nil)
+
+	suite.s.runDue()
+	<-suite.jm.executed
+
+	suite.Equal(runTime, *schedules[0].LastRun)
+	suite.Equal("456", schedules[0].LastJobID)
+	suite.Equal(time.Date(2015, time.March, 19, 11, 0, 0, 0, time.UTC), *schedules[0].NextRun)
+}
+
+func (suite *SchedulerTestSuite) TestRunDueNotDue() {
+	nextRun := suite.now.Add(time.Minute)
+	suite.schedule.NextRun = &nextRun
+
+	suite.r.On("AllSchedules").Return([]Schedule{suite.schedule}, nil)
+
+	suite.s.runDue()
+}
+
+func (suite *SchedulerTestSuite) TestRunDueNeverMatches() {
+	suite.schedule.NextRun = &time.Time{}
+
+	suite.r.On("AllSchedules").Return([]Schedule{suite.schedule}, nil)
+
+	suite.s.runDue()
+}
+
+func (suite *SchedulerTestSuite) TestRunDueAlreadyClaimed() {
+	schedules := []Schedule{suite.schedule}
+
+	suite.r.On("AllSchedules").Return(schedules, nil)
+	suite.r.On("ClaimScheduleRun", "abc", *suite.schedule.NextRun).Return(false, nil)
+	suite.r.On("SaveScheduleRun", &schedules[0]).Return(nil)
+
+	suite.s.runDue()
+
+	suite.Nil(schedules[0].LastRun)
+	suite.Equal("123", schedules[0].LastJobID)
+	suite.Equal(time.Date(2015, time.March, 19, 11, 0, 0, 0, time.UTC), *schedules[0].NextRun)
+}
+
+func (suite *SchedulerTestSuite) TestRunDueClaimError() {
+	suite.r.On("AllSchedules").Return([]Schedule{suite.schedule}, nil)
+	suite.r.On("ClaimScheduleRun", "abc", *suite.schedule.NextRun).Return(false, errors.New("oops"))
+
+	suite.s.runDue()
+}
+
+func (suite *SchedulerTestSuite) TestFireForbid() {
+	suite.schedule.ConcurrencyPolicy = "forbid"
+
+	suite.jm.On("GetByID", "", "123").Return(&Job{ID: "123", Status: "running"}, nil)
+
+	err := suite.s.fire(&suite.schedule)
+
+	suite.NoError(err)
+	suite.Equal("123", suite.schedule.LastJobID)
+}
+
+func (suite *SchedulerTestSuite) TestFireReplace() {
+	previous := &Job{ID: "123", Status: "running"}
+	suite.schedule.ConcurrencyPolicy = "replace"
+
+	suite.jm.On("GetByID", "", "123").Return(previous, nil)
+	suite.jm.On("Cancel", previous).Return(nil)
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.jm.On("Execute", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	err := suite.s.fire(&suite.schedule)
+	<-suite.jm.executed
+
+	suite.NoError(err)
+	suite.Equal("456", suite.schedule.LastJobID)
+}
+
+func (suite *SchedulerTestSuite) TestFireTemplate() {
+	suite.schedule.Job = nil
+	suite.schedule.LastJobID = ""
+	suite.schedule.Template = "words"
+	suite.schedule.Parameters = map[string]string{"COUNT": "5"}
+	template := &JobTemplate{
+		Name:       "words",
+		Version:    4,
+		Parameters: []TemplateParameter{{Name: "COUNT"}},
+	}
+
+	suite.jm.On("GetTemplate", "words").Return(template, nil)
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.jm.On("Execute", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	err := suite.s.fire(&suite.schedule)
+	<-suite.jm.executed
+
+	suite.NoError(err)
+
+	j := suite.jm.Calls[1].Arguments.Get(0).(*Job)
+	suite.Equal("abc", j.Schedule)
+	suite.Equal("", j.Namespace)
+	suite.Equal("words", j.Template)
+	suite.Equal(4, j.TemplateVersion)
+	suite.Equal(Environment{{Variable: "COUNT", Value: "5"}}, j.Environment)
+}
+
+func (suite *SchedulerTestSuite) TestFireNamespace() {
+	suite.schedule.Namespace = "team-a"
+	suite.schedule.ConcurrencyPolicy = "forbid"
+
+	suite.jm.On("GetByID", "team-a", "123").Return(&Job{ID: "123", Status: "complete"}, nil)
+	suite.jm.On("Create", mock.AnythingOfType("*job.Job")).Return(nil)
+	suite.jm.On("Execute", mock.AnythingOfType("*job.Job")).Return(nil)
+
+	err := suite.s.fire(&suite.schedule)
+	<-suite.jm.executed
+
+	suite.NoError(err)
+	suite.Equal("team-a", suite.jm.Calls[1].Arguments.Get(0).(*Job).Namespace)
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
@@ -0,0 +1,141 @@
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	defaultHealthcheckInterval = 1
+	defaultHealthcheckRetries  = 30
+)
+
+// Service describes a container (such as a database) which is started before
+// the first step of a job and kept running until the job finishes. Services
+// join the job's network and can be reached from every step using the
+// service's Name. The service's image is pulled according to its PullPolicy
+// and its container is limited by its Resources and runs as its User, in the
+// same way as a step's.
+type Service struct {
+	Name        string       `json:"name,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	Environment Environment  `json:"environment,omitempty"`
+	Healthcheck *Healthcheck `json:"healthcheck,omitempty"`
+	PullPolicy  string       `json:"pullPolicy,omitempty"`
+	Resources   *Resources   `json:"resources,omitempty"`
+	User        string       `json:"user,omitempty"`
+
+	id string
+}
+
+// Healthcheck is the command used to determine whether a service is ready.
+// The command is run every Interval seconds until it succeeds; the job fails
+// if it has not succeeded after the given number of Retries.
+type Healthcheck struct {
+	Command  []string `json:"command,omitempty"`
+	Interval int      `json:"interval,omitempty"`
+	Retries  int      `json:"retries,omitempty"`
+}
+
+func (s Service) validate(v *validator, path string, names map[string]bool) {
+	if len(s.Name) == 0 {
+		v.add(path+".name", msgRequired)
+	} else if !networkNamePattern.MatchString(s.Name) {
+		v.add(path+".name", "must contain only letters, digits, '_', '.' and '-'")
+	} else if names[s.Name] {
+		v.add(path+".name", "must be unique")
+	}
+	names[s.Name] = true
+
+	if len(s.Source) == 0 {
+		v.add(path+".source", msgRequired)
+	}
+
+	validateImage(v, path+".source", s.Source)
+
+	s.Environment.validate(v, path+".environment")
+	validatePullPolicy(v, path+".pullPolicy", s.PullPolicy)
+
+	if s.Resources != nil {
+		s.Resources.validate(v, path+".resources")
+	}
+
+	if hc := s.Healthcheck; hc != nil {
+		if len(hc.Command) == 0 {
+			v.add(path+".healthcheck.command", msgRequired)
+		}
+
+		if hc.Interval < 0 {
+			v.add(path+".healthcheck.interval", "must not be negative")
+		}
+
+		if hc.Retries < 0 {
+			v.add(path+".healthcheck.retries", "must not be negative")
+		}
+	}
+}
+
+// Returns the service's pull policy.
+func (s Service) pullPolicy() string {
+	if len(s.PullPolicy) > 0 {
+		return s.PullPolicy
+	}
+
+	return pullIfNotPresent
+}
+
+// Returns the Docker healthcheck configuration for the service, with the
+// interval converted to nanoseconds.
+func (hc Healthcheck) config() *dockerHealthcheck {
+	interval := hc.Interval
+	if interval == 0 {
+		interval = defaultHealthcheckInterval
+	}
+
+	retries := hc.Retries
+	if retries == 0 {
+		retries = defaultHealthcheckRetries
+	}
+
+	return &dockerHealthcheck{
+		Test:     append([]string{"CMD"}, hc.Command...),
+		Interval: int64(interval) * 1e9,
+		Retries:  retries,
+	}
+}
+
+// Returns true if the job needs a network of its own, either because one was
+// requested or so that its steps can reach its services.
+func (j Job) usesNetwork() bool {
+	return j.CreateNetwork || len(j.Services) > 0
+}
+
+// Writes every line read from r to w, prefixed with the name of the service
+// which produced it. Each line is written with a single call to Write.
+func prefixLines(w io.Writer, name string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fmt.Fprintf(w, "%s: %s\n", name, scanner.Text())
+	}
+}
+
+// Returns a writer whose lines are written to w, prefixed with the name of
+// the service. The returned channel is closed once the writer has been
+// closed and every line has been written.
+func prefixWriter(w io.Writer, name string) (io.WriteCloser, <-chan struct{}) {
+	r, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		prefixLines(w, name, r)
+
+		// Writes must not block if a line was too long to be scanned
+		io.Copy(ioutil.Discard, r)
+	}()
+
+	return pw, done
+}
@@ -0,0 +1,41 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServicesValidate(t *testing.T) {
+	j := Job{
+		Steps: []JobStep{{Source: "foo", NetworkAliases: []string{"foo"}}},
+		Services: []Service{
+			{Name: "db", Source: "postgres"},
+			{Name: "db", Healthcheck: &Healthcheck{Interval: -1, Retries: -1}},
+			{Name: "my cache", Source: "redis", Environment: Environment{{Value: "x"}}},
+			{Name: "queue", Source: "rabbitmq", PullPolicy: "sometimes", Resources: &Resources{Memory: -1}},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "services[1].name", Message: "must be unique"},
+		{Field: "services[1].source", Message: "required"},
+		{Field: "services[1].healthcheck.command", Message: "required"},
+		{Field: "services[1].healthcheck.interval", Message: "must not be negative"},
+		{Field: "services[1].healthcheck.retries", Message: "must not be negative"},
+		{Field: "services[2].name", Message: "must contain only letters, digits, '_', '.' and '-'"},
+		{Field: "services[2].environment[0].variable", Message: "required"},
+		{Field: "services[3].pullPolicy", Message: "must be \"always\", \"if-not-present\" or \"never\""},
+		{Field: "services[3].resources.memory", Message: "must not be negative"},
+	}, j.Validate())
+}
+
+func TestHealthcheckConfig(t *testing.T) {
+	hc := Healthcheck{Command: []string{"redis-cli", "ping"}, Interval: 5, Retries: 3}
+
+	assert.Equal(t, &dockerHealthcheck{
+		Test:     []string{"CMD", "redis-cli", "ping"},
+		Interval: 5000000000,
+		Retries:  3,
+	}, hc.config())
+}
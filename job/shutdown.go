@@ -0,0 +1,85 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// interruptWait is the time Shutdown gives interrupted jobs to record their
+// status and remove their containers.
+var interruptWait = 30 * time.Second
+
+// ShuttingDownError is an error returned when a job is submitted while the
+// JobManager is shutting down.
+type ShuttingDownError struct{}
+
+// Error returns the error string for the ShuttingDownError
+func (ShuttingDownError) Error() string {
+	return "Dray is shutting down and is not accepting new jobs"
+}
+
+// Shutdown stops the JobManager from accepting new jobs and waits for the
+// running jobs to finish. If the context is done before they have finished,
+// the remaining jobs are stopped and marked as "interrupted", and Shutdown
+// waits a little longer for them to finish and remove their containers. The
+// context's error is returned if any job was interrupted.
+func (jm *jobManager) Shutdown(ctx context.Context) error {
+	jm.mu.Lock()
+	jm.draining = true
+	jm.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		jm.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	for jobID, containerID := range jm.interruptAll() {
+		log.Infof("Interrupting job %s", jobID)
+
+		if err := jm.stopContainer(containerID); err != nil {
+			log.Errorf("Error stopping job %s: %s", jobID, err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(interruptWait):
+		log.Warnf("Interrupted jobs did not finish within %s", interruptWait)
+	}
+
+	return ctx.Err()
+}
+
+func (jm *jobManager) shuttingDown() bool {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	return jm.draining
+}
+
+// Marks every running job as interrupted, returning the IDs of the jobs
+// which had not already been stopped along with the containers of their
+// current steps.
+func (jm *jobManager) interruptAll() map[string]string {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	interrupted := map[string]string{}
+	for id, rj := range jm.running {
+		if len(rj.stopStatus) == 0 {
+			rj.stopStatus = statusInterrupted
+			interrupted[id] = rj.containerID
+		}
+	}
+
+	return interrupted
+}
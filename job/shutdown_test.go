@@ -0,0 +1,119 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *JobManagerTestSuite) TestShutdownIdle() {
+	resultErr := suite.jm.Shutdown(context.Background())
+
+	suite.NoError(resultErr)
+	suite.Equal(ShuttingDownError{}, suite.jm.Create(suite.job))
+}
+
+func (suite *JobManagerTestSuite) TestShutdownRejectsRerunAndResume() {
+	suite.jm.Shutdown(context.Background())
+
+	_, rerunErr := suite.jm.Rerun(suite.job)
+	_, resumeErr := suite.jm.Resume(suite.job)
+
+	suite.Equal(ShuttingDownError{}, rerunErr)
+	suite.Equal(ShuttingDownError{}, resumeErr)
+}
+
+func (suite *JobManagerTestSuite) TestShutdownWaitsForRunningJobs() {
+	suite.jm.track(suite.job)
+
+	result := make(chan error)
+	go func() {
+		result <- suite.jm.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-result:
+		suite.Fail("Shutdown returned while a job was running")
+	default:
+	}
+
+	suite.jm.untrack(suite.job)
+
+	suite.NoError(<-result)
+	suite.Empty(suite.jm.running)
+}
+
+func (suite *JobManagerTestSuite) TestShutdownInterruptsRunningJobs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	suite.jm.track(suite.job)
+	suite.jm.setContainer(suite.job, "abc123")
+	suite.e.On("Stop", "abc123").Return(nil)
+
+	// Simulate the job finishing once it has been stopped
+	suite.e.onStop = func(containerID string) {
+		suite.Equal(statusInterrupted, suite.jm.stopStatus(suite.job))
+		suite.jm.untrack(suite.job)
+	}
+
+	resultErr := suite.jm.Shutdown(ctx)
+
+	suite.Equal(context.Canceled, resultErr)
+	suite.Empty(suite.jm.running)
+}
+
+func (suite *JobManagerTestSuite) TestShutdownStopsWaitingForInterruptedJobs() {
+	defer func(d time.Duration) { interruptWait = d }(interruptWait)
+	interruptWait = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	suite.jm.track(suite.job)
+	suite.jm.setContainer(suite.job, "abc123")
+	suite.e.On("Stop", "abc123").Return(nil)
+
+	resultErr := suite.jm.Shutdown(ctx)
+
+	suite.Equal(context.Canceled, resultErr)
+	suite.Equal(statusInterrupted, suite.jm.stopStatus(suite.job))
+	suite.jm.untrack(suite.job)
+}
+
+func (suite *JobManagerTestSuite) TestShutdownKeepsCancelledStatus() {
+	defer func(d time.Duration) { interruptWait = d }(interruptWait)
+	interruptWait = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	suite.jm.track(suite.job)
+	suite.jm.stop(suite.job.ID, statusCancelled)
+
+	suite.jm.Shutdown(ctx)
+
+	suite.Equal(statusCancelled, suite.jm.stopStatus(suite.job))
+	suite.e.AssertNotCalled(suite.T(), "Stop", mock.Anything)
+	suite.jm.untrack(suite.job)
+}
+
+func (suite *JobManagerTestSuite) TestExecuteWhileShuttingDown() {
+	suite.jm.Shutdown(context.Background())
+	suite.r.On("Update", suite.job.ID, "status", "interrupted").Return(nil)
+
+	resultErr := suite.jm.Execute(suite.job)
+
+	suite.Equal(ShuttingDownError{}, resultErr)
+	suite.e.AssertNotCalled(suite.T(), "Setup", suite.job, suite.job)
+}
+
+func (suite *JobManagerTestSuite) TestReadinessWhileShuttingDown() {
+	suite.jm.Shutdown(context.Background())
+	suite.r.On("Ping").Return(nil)
+	suite.e.On("Ping").Return(nil)
+
+	readiness := suite.jm.Readiness()
+
+	suite.False(readiness.Ready())
+	suite.Equal(WorkerHealth{Status: "unavailable", Draining: true}, readiness.Workers)
+}
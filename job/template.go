@@ -0,0 +1,171 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	paramTypeString  = "string"
+	paramTypeNumber  = "number"
+	paramTypeBoolean = "boolean"
+)
+
+// TemplateNotFoundError is an error returned when a referenced JobTemplate
+// cannot be found.
+type TemplateNotFoundError string
+
+// Error returns the error string for the TemplateNotFoundError
+func (s TemplateNotFoundError) Error() string {
+	return fmt.Sprintf("Cannot find template with name %s", string(s))
+}
+
+// JobTemplate is a named, reusable job definition. The parameters declared by
+// the template are turned into job-level environment variables each time a
+// new Job is instantiated from the template. The Version field is assigned
+// by the repository and is incremented every time a template with the same
+// name is saved.
+type JobTemplate struct {
+	Name        string              `json:"name,omitempty"`
+	Version     int                 `json:"version,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  []TemplateParameter `json:"parameters,omitempty"`
+	Job         Job                 `json:"job"`
+}
+
+// TemplateParameter describes a single value which can be supplied when a Job
+// is instantiated from a JobTemplate. The Type field must be one of "string"
+// (the default), "number" or "boolean".
+type TemplateParameter struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Validate checks that the template has a name, that all of its parameters
+// are well-formed and that its job definition is valid.
+func (t JobTemplate) Validate() error {
+	v := &validator{}
+
+	if len(t.Name) == 0 {
+		v.add("name", msgRequired)
+	}
+
+	seen := map[string]bool{}
+	for i, p := range t.Parameters {
+		path := fmt.Sprintf("parameters[%d]", i)
+
+		if len(p.Name) == 0 {
+			v.add(path+".name", msgRequired)
+		} else if seen[p.Name] {
+			v.add(path+".name", "duplicate parameter %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		switch p.Type {
+		case "", paramTypeString, paramTypeNumber, paramTypeBoolean:
+			if len(p.Default) > 0 && !p.accepts(p.Default) {
+				v.add(path+".default", "must be a %s", p.Type)
+			}
+		default:
+			v.add(path+".type", "must be \"string\", \"number\" or \"boolean\"")
+		}
+	}
+
+	v.nest("job", t.Job.Validate())
+
+	return v.err()
+}
+
+// Instantiate returns a new Job built from the template definition. The
+// supplied values are checked against the declared parameters and then
+// appended to the job's environment, replacing any variable of the same
+// name. Parameters which are not supplied fall back to their default value.
+func (t JobTemplate) Instantiate(values map[string]string) (*Job, error) {
+	v := &validator{prefix: "parameters."}
+	params := map[string]TemplateParameter{}
+	for _, p := range t.Parameters {
+		params[p.Name] = p
+	}
+
+	for name := range values {
+		if _, ok := params[name]; !ok {
+			v.add(name, "unknown parameter")
+		}
+	}
+
+	env := Environment{}
+	for _, p := range t.Parameters {
+		value, ok := values[p.Name]
+
+		if !ok {
+			if p.Required {
+				v.add(p.Name, msgRequired)
+				continue
+			}
+
+			if len(p.Default) == 0 {
+				continue
+			}
+
+			value = p.Default
+		}
+
+		if !p.accepts(value) {
+			v.add(p.Name, "must be a %s", p.Type)
+			continue
+		}
+
+		env = append(env, EnvVar{Variable: p.Name, Value: value})
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	j := t.Job
+	j.ID = ""
+	j.Status = ""
+	j.StepsCompleted = 0
+	j.Template = t.Name
+	j.TemplateVersion = t.Version
+	j.Steps = append([]JobStep{}, t.Job.Steps...)
+	j.Environment = mergeEnvironment(t.Job.Environment, env)
+
+	return &j, nil
+}
+
+// Returns true if the value can be parsed as the parameter's type.
+func (p TemplateParameter) accepts(value string) bool {
+	var err error
+
+	switch p.Type {
+	case paramTypeNumber:
+		_, err = strconv.ParseFloat(value, 64)
+	case paramTypeBoolean:
+		_, err = strconv.ParseBool(value)
+	}
+
+	return err == nil
+}
+
+// Returns a new Environment containing the variables in base with any
+// variables of the same name replaced by those in overrides.
+func mergeEnvironment(base, overrides Environment) Environment {
+	merged := Environment{}
+	replaced := map[string]bool{}
+
+	for _, v := range overrides {
+		replaced[v.Variable] = true
+	}
+
+	for _, v := range base {
+		if !replaced[v.Variable] {
+			merged = append(merged, v)
+		}
+	}
+
+	return append(merged, overrides...)
+}
@@ -0,0 +1,94 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTemplate() JobTemplate {
+	return JobTemplate{
+		Name:    "word",
+		Version: 3,
+		Parameters: []TemplateParameter{
+			{Name: "WORD_COUNT", Type: "number", Default: "5"},
+			{Name: "REGION", Required: true},
+			{Name: "VERBOSE", Type: "boolean"},
+		},
+		Job: Job{
+			Name:        "Word Job",
+			Environment: Environment{{Variable: "REGION", Value: "none"}, {Variable: "x", Value: "1"}},
+			Steps:       []JobStep{{Source: "centurylink/randword"}},
+		},
+	}
+}
+
+func TestJobTemplateValidate(t *testing.T) {
+	template := newTestTemplate()
+	assert.NoError(t, template.Validate())
+
+	template = JobTemplate{
+		Parameters: []TemplateParameter{
+			{Name: "a"},
+			{Name: "a"},
+			{Name: "b", Type: "number", Default: "x"},
+			{Name: "c", Type: "list"},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "name", Message: "required"},
+		{Field: "parameters[1].name", Message: "duplicate parameter a"},
+		{Field: "parameters[2].default", Message: "must be a number"},
+		{Field: "parameters[3].type", Message: "must be \"string\", \"number\" or \"boolean\""},
+		{Field: "job.steps", Message: "required"},
+	}, template.Validate())
+}
+
+func TestJobTemplateInstantiate(t *testing.T) {
+	template := newTestTemplate()
+
+	j, err := template.Instantiate(map[string]string{"REGION": "us-west-2", "VERBOSE": "true"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Word Job", j.Name)
+	assert.Equal(t, "word", j.Template)
+	assert.Equal(t, 3, j.TemplateVersion)
+	assert.Equal(t, template.Job.Steps, j.Steps)
+	assert.Equal(t, Environment{
+		{Variable: "x", Value: "1"},
+		{Variable: "WORD_COUNT", Value: "5"},
+		{Variable: "REGION", Value: "us-west-2"},
+		{Variable: "VERBOSE", Value: "true"},
+	}, j.Environment)
+
+	// Template definition must not be modified
+	assert.Len(t, template.Job.Environment, 2)
+}
+
+func TestJobTemplateInstantiateMissingRequired(t *testing.T) {
+	template := newTestTemplate()
+
+	_, err := template.Instantiate(map[string]string{})
+
+	assert.Equal(t, NewValidationError("parameters.REGION", "required"), err)
+}
+
+func TestJobTemplateInstantiateUnknownParameter(t *testing.T) {
+	template := newTestTemplate()
+
+	_, err := template.Instantiate(map[string]string{"REGION": "a", "FOO": "b"})
+
+	assert.Equal(t, NewValidationError("parameters.FOO", "unknown parameter"), err)
+}
+
+func TestJobTemplateInstantiateInvalidType(t *testing.T) {
+	template := newTestTemplate()
+
+	_, err := template.Instantiate(map[string]string{"REGION": "a", "VERBOSE": "maybe", "WORD_COUNT": "x"})
+
+	assert.Equal(t, ValidationError{
+		{Field: "parameters.WORD_COUNT", Message: "must be a number"},
+		{Field: "parameters.VERBOSE", Message: "must be a boolean"},
+	}, err)
+}
@@ -0,0 +1,71 @@
+package job
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+)
+
+// DockerTLS configures the TLS connection to a Docker daemon listening on
+// TCP, following the conventions of the Docker CLI: CertPath is a directory
+// holding the client's cert.pem and key.pem along with the ca.pem used to
+// verify the daemon's certificate. The daemon's certificate is only verified
+// if Verify is true. TLS is not used unless Enabled is true.
+type DockerTLS struct {
+	Enabled  bool
+	Verify   bool
+	CertPath string
+}
+
+// Returns the paths of the CA certificate, client certificate and client key.
+// The CA path is empty if the daemon's certificate is not to be verified.
+func (t DockerTLS) files() (ca, cert, key string) {
+	if t.Verify {
+		ca = filepath.Join(t.CertPath, "ca.pem")
+	}
+
+	return ca, filepath.Join(t.CertPath, "cert.pem"), filepath.Join(t.CertPath, "key.pem")
+}
+
+func (t DockerTLS) config() (*tls.Config, error) {
+	ca, cert, key := t.files()
+
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tls.Config{Certificates: []tls.Certificate{pair}}
+
+	if !t.Verify {
+		c.InsecureSkipVerify = true
+		return c, nil
+	}
+
+	pem, err := ioutil.ReadFile(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RootCAs = x509.NewCertPool()
+	if !c.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates found in %s", ca)
+	}
+
+	return c, nil
+}
+
+// Returns the endpoint with a "tcp" scheme replaced by "https", since
+// go-dockerclient only assumes TLS for the daemon's default TLS port.
+func tlsEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "tcp" {
+		return endpoint
+	}
+
+	u.Scheme = "https"
+	return u.String()
+}
@@ -0,0 +1,93 @@
+package job
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes a self-signed certificate to the directory as ca.pem and cert.pem,
+// along with its key as key.pem.
+func writeTestCerts(t *testing.T, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dray"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	for name, data := range map[string][]byte{"ca.pem": certPEM, "cert.pem": certPEM, "key.pem": keyPEM} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDockerTLSFiles(t *testing.T) {
+	ca, cert, key := DockerTLS{Enabled: true, Verify: true, CertPath: "/certs"}.files()
+	assert.Equal(t, "/certs/ca.pem", ca)
+	assert.Equal(t, "/certs/cert.pem", cert)
+	assert.Equal(t, "/certs/key.pem", key)
+
+	ca, _, _ = DockerTLS{Enabled: true, CertPath: "/certs"}.files()
+	assert.Empty(t, ca)
+}
+
+func TestDockerTLSConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dray-tls")
+	defer os.RemoveAll(dir)
+	writeTestCerts(t, dir)
+
+	c, err := DockerTLS{Enabled: true, Verify: true, CertPath: dir}.config()
+	assert.NoError(t, err)
+	assert.Len(t, c.Certificates, 1)
+	assert.NotNil(t, c.RootCAs)
+	assert.False(t, c.InsecureSkipVerify)
+
+	c, err = DockerTLS{Enabled: true, CertPath: dir}.config()
+	assert.NoError(t, err)
+	assert.Nil(t, c.RootCAs)
+	assert.True(t, c.InsecureSkipVerify)
+}
+
+func TestDockerTLSConfigMissingFiles(t *testing.T) {
+	_, err := DockerTLS{Enabled: true, Verify: true, CertPath: "/does/not/exist"}.config()
+	assert.Error(t, err)
+}
+
+func TestTLSEndpoint(t *testing.T) {
+	assert.Equal(t, "https://10.0.0.1:2375", tlsEndpoint("tcp://10.0.0.1:2375"))
+	assert.Equal(t, "https://10.0.0.1:2376", tlsEndpoint("https://10.0.0.1:2376"))
+	assert.Equal(t, "unix:///var/run/docker.sock", tlsEndpoint("unix:///var/run/docker.sock"))
+}
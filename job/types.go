@@ -1,43 +1,94 @@
 package job // import "github.com/CenturyLinkLabs/dray/job"
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 // JobManager is the interface to used to represent all of the use cases which
-// are necessary to manage the lifecyle of a job.
+// are necessary to manage the lifecyle of a job. Jobs are listed and
+// retrieved from within a namespace.
 type JobManager interface {
-	ListAll() ([]Job, error)
-	GetByID(string) (*Job, error)
+	ListAll(namespace string) ([]Job, error)
+	GetByID(namespace, jobID string) (*Job, error)
 	Create(*Job) error
 	Execute(*Job) error
-	GetLog(*Job, int) (*JobLog, error)
+	GetLog(*Job, LogQuery) (*JobLog, error)
+	GetServiceLog(*Job, LogQuery) (*JobLog, error)
 	Delete(*Job) error
+	ListTemplates() ([]JobTemplate, error)
+	GetTemplate(string) (*JobTemplate, error)
+	CreateTemplate(*JobTemplate) error
+	DeleteTemplate(*JobTemplate) error
+	ListSchedules() ([]Schedule, error)
+	GetSchedule(string) (*Schedule, error)
+	CreateSchedule(*Schedule) error
+	UpdateSchedule(*Schedule) error
+	DeleteSchedule(*Schedule) error
+	Cancel(*Job) error
+	Rerun(*Job) (*Job, error)
+	Resume(*Job) (*Job, error)
+	Readiness() Readiness
+	Shutdown(context.Context) error
 }
 
 // JobRepository is the interface that wraps all of the persistence operations
 // related to a job. The JobManager uses the JobRepository to maintain state
-// about jobs that are submitted.
+// about jobs that are submitted. The job operations act on the jobs of a
+// single namespace (the DefaultNamespace unless a view of another namespace
+// is obtained with InNamespace), while templates and schedules are shared by
+// all namespaces.
 type JobRepository interface {
+	Ping() error
+	InNamespace(namespace string) JobRepository
 	All() ([]Job, error)
 	Get(jobID string) (*Job, error)
 	Create(job *Job) error
 	Delete(jobID string) error
 	Update(jobID, attr, value string) error
 	GetJobLog(jobID string, index int) (*JobLog, error)
-	AppendLogLine(jobID, logLine string) error
+	AppendLogEntry(jobID string, entry LogEntry) error
+	GetServiceLog(jobID string, index int) (*JobLog, error)
+	AppendServiceLogEntry(jobID string, entry LogEntry) error
+	GetStepOutput(jobID string) ([]byte, error)
+	SaveStepOutput(jobID string, output []byte) error
+	AllTemplates() ([]JobTemplate, error)
+	GetTemplate(name string) (*JobTemplate, error)
+	SaveTemplate(template *JobTemplate) error
+	DeleteTemplate(name string) error
+	AllSchedules() ([]Schedule, error)
+	GetSchedule(scheduleID string) (*Schedule, error)
+	SaveSchedule(schedule *Schedule) error
+	SaveScheduleRun(schedule *Schedule) error
+	DeleteSchedule(scheduleID string) error
+	ClaimScheduleRun(scheduleID string, runTime time.Time) (bool, error)
 }
 
 // JobStepExecutor is the interface that wraps the methods necessary to turn
 // a job step into a running Docker container and then clean-up after the
-// container has stopped.
+// container has stopped. Pull makes the step's image available before the
+// container is started, writing its progress to the supplied writer. Setup
+// is called before the first step of a job is started in order to create any
+// resources shared by all of the job's steps (including its service
+// containers, whose output is written to the serviceLog) while TearDown
+// removes those resources once the job has finished. ResolveImage returns a
+// reference to the image which pins it to the digest its tag currently
+// refers to. Stop stops a step's container, and may be called while the job
+// is being executed. Ping checks that the Docker daemon can be reached.
 type JobStepExecutor interface {
+	Ping() error
+	ResolveImage(js *Job, image string) (string, error)
+	Setup(js *Job, serviceLog io.Writer) error
+	Pull(js *Job, progress io.Writer) error
 	Start(js *Job, stdIn io.Reader, stdOut, stdErr io.WriteCloser) error
 	Inspect(js *Job) error
+	Stop(containerID string) error
 	CleanUp(js *Job) error
+	TearDown(js *Job) error
 }
 
 // Job describes the data necessary for Dray to process a job.
@@ -48,6 +99,42 @@ type Job struct {
 	Environment    Environment `json:"environment,omitempty"`
 	StepsCompleted int         `json:"stepsCompleted,omitempty"`
 	Status         string      `json:"status,omitempty"`
+	Workspace      string      `json:"workspace,omitempty"`
+	Services       []Service   `json:"services,omitempty"`
+
+	// CreateNetwork requests a user-defined network for the job which is
+	// joined by every step that does not name its own network, allowing the
+	// containers in the job to reach each other by name. A network is always
+	// created for jobs with services.
+	CreateNetwork bool `json:"createNetwork,omitempty"`
+
+	// LockImages replaces the tag of every step and service image with the
+	// digest it refers to when the job is submitted, so that the job (and
+	// any rerun of it) always executes the same images.
+	LockImages bool `json:"lockImages,omitempty"`
+
+	// RegistryCredentials names the set of registry credentials configured
+	// on the server which should be used to pull the images for the job.
+	RegistryCredentials string `json:"registryCredentials,omitempty"`
+
+	// SubmittedBy is the name of the authenticated principal which
+	// submitted the job. It is set by the server and cannot be supplied by
+	// the client.
+	SubmittedBy string `json:"submittedBy,omitempty"`
+
+	// Namespace isolates the job from those submitted by other teams. It
+	// is set by the server from the request which submitted the job.
+	Namespace string `json:"namespace,omitempty"`
+
+	Template        string `json:"template,omitempty"`
+	TemplateVersion int    `json:"templateVersion,omitempty"`
+	Schedule        string `json:"schedule,omitempty"`
+	ParentID        string `json:"parentId,omitempty"`
+}
+
+// active returns true if the job has not yet finished executing.
+func (j Job) active() bool {
+	return j.Status == statusRunning || j.Status == ""
 }
 
 // CurrentStep returns the first JobStep from the list which has not yet
@@ -75,6 +162,23 @@ type JobStep struct {
 	BeginDelimiter string      `json:"beginDelimiter,omitempty"`
 	EndDelimiter   string      `json:"endDelimiter,omitempty"`
 	Refresh        bool        `json:"refresh,omitempty"`
+	PullPolicy     string      `json:"pullPolicy,omitempty"`
+	Resources      *Resources  `json:"resources,omitempty"`
+	Volumes        []Volume    `json:"volumes,omitempty"`
+	Command        []string    `json:"command,omitempty"`
+	Entrypoint     []string    `json:"entrypoint,omitempty"`
+	WorkingDir     string      `json:"workingDir,omitempty"`
+	User           string      `json:"user,omitempty"`
+	Network        string      `json:"network,omitempty"`
+	ExtraHosts     []string    `json:"extraHosts,omitempty"`
+	DNS            []string    `json:"dns,omitempty"`
+	NetworkAliases []string    `json:"networkAliases,omitempty"`
+
+	// Status and ResolvedImage record the progress of the step and the
+	// image used to execute it. They are set by Dray while the job executes
+	// and are ignored when a job is submitted.
+	Status        string    `json:"status,omitempty"`
+	ResolvedImage *ImageRef `json:"resolvedImage,omitempty"`
 
 	id string
 }
@@ -99,14 +203,6 @@ func (js JobStep) usesDelimitedOutput() bool {
 	return len(js.BeginDelimiter) > 0 && len(js.EndDelimiter) > 0
 }
 
-// JobLog represents the log output of a job. The Index field contains the
-// starting index for the list of log lines returned while the Lines field is
-// a list of log lines generated by the job.
-type JobLog struct {
-	Index int      `json:"index,omitempty"`
-	Lines []string `json:"lines"`
-}
-
 // Environment is an array of EnvVar structs and represents the set of
 // environment variables to be injected into a Docker container.
 type Environment []EnvVar
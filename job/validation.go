@@ -0,0 +1,151 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+)
+
+const msgRequired = "required"
+
+// FieldError describes a single problem with a submitted request. The Field
+// is the path to the offending value (e.g. "steps[1].source") and may be
+// empty for problems which do not relate to a specific field.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if len(e.Field) == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError is an error returned when a submitted job, template or
+// schedule is malformed or when a request cannot be satisfied given the
+// current state of a job. It lists every problem which was found.
+type ValidationError []FieldError
+
+// Error returns the error string for the ValidationError
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+
+	for i, fe := range e {
+		msgs[i] = fe.String()
+	}
+
+	return strings.Join(msgs, ", ")
+}
+
+// NewValidationError returns a ValidationError describing a single problem.
+func NewValidationError(field, message string) ValidationError {
+	return ValidationError{{Field: field, Message: message}}
+}
+
+// validator accumulates the problems found while validating a request.
+type validator struct {
+	prefix string
+	errs   ValidationError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Field: v.prefix + field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Adds the problems reported by a nested validation, prefixing each field
+// with the given path (if any).
+func (v *validator) nest(field string, err error) {
+	if errs, ok := err.(ValidationError); ok {
+		for _, fe := range errs {
+			if len(field) > 0 {
+				fe.Field = field + "." + fe.Field
+			}
+			v.add(fe.Field, "%s", fe.Message)
+		}
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return v.errs
+}
+
+// Validate checks that the job has at least one step and that all of its
+// steps and environment variables are well-formed.
+func (j Job) Validate() error {
+	v := &validator{}
+
+	if len(j.Steps) == 0 {
+		v.add("steps", msgRequired)
+	}
+
+	j.Environment.validate(v, "environment")
+
+	if len(j.Workspace) > 0 && !strings.HasPrefix(j.Workspace, "/") {
+		v.add("workspace", "must be an absolute path")
+	}
+
+	if len(j.Namespace) > 0 && !ValidNamespace(j.Namespace) {
+		v.add("namespace", "must consist of lowercase letters, digits and hyphens")
+	}
+
+	for i, step := range j.Steps {
+		step.validate(v, fmt.Sprintf("steps[%d]", i))
+		step.validateNetwork(v, fmt.Sprintf("steps[%d]", i), j.usesNetwork())
+	}
+
+	names := map[string]bool{}
+	for i, s := range j.Services {
+		s.validate(v, fmt.Sprintf("services[%d]", i), names)
+	}
+
+	return v.err()
+}
+
+func (js JobStep) validate(v *validator, path string) {
+	if len(js.Source) == 0 {
+		v.add(path+".source", msgRequired)
+	}
+
+	validateImage(v, path+".source", js.Source)
+	js.validatePullPolicy(v, path)
+
+	if !js.usesStdOutPipe() && !js.usesStdErrPipe() && !js.usesFilePipe() {
+		v.add(path+".output", "must be \"stdout\", \"stderr\" or an absolute file path")
+	}
+
+	if len(js.BeginDelimiter) > 0 && len(js.EndDelimiter) == 0 {
+		v.add(path+".endDelimiter", "required when beginDelimiter is set")
+	}
+
+	if len(js.EndDelimiter) > 0 && len(js.BeginDelimiter) == 0 {
+		v.add(path+".beginDelimiter", "required when endDelimiter is set")
+	}
+
+	if len(js.WorkingDir) > 0 && !strings.HasPrefix(js.WorkingDir, "/") {
+		v.add(path+".workingDir", "must be an absolute path")
+	}
+
+	js.Environment.validate(v, path+".environment")
+
+	if js.Resources != nil {
+		js.Resources.validate(v, path+".resources")
+	}
+
+	for i, vol := range js.Volumes {
+		vol.validate(v, fmt.Sprintf("%s.volumes[%d]", path, i))
+	}
+}
+
+func (e Environment) validate(v *validator, path string) {
+	for i, envVar := range e {
+		if len(envVar.Variable) == 0 {
+			v.add(fmt.Sprintf("%s[%d].variable", path, i), msgRequired)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrorError(t *testing.T) {
+	err := ValidationError{
+		{Field: "steps[0].source", Message: "required"},
+		{Message: "oops"},
+	}
+
+	assert.EqualError(t, err, "steps[0].source: required, oops")
+}
+
+func TestJobValidate(t *testing.T) {
+	j := Job{
+		Environment: Environment{{Value: "x"}},
+		Steps: []JobStep{
+			{Source: "foo"},
+			{Output: "out.txt", BeginDelimiter: "---"},
+			{Source: "bar", Output: "/out.txt", WorkingDir: "tmp", Environment: Environment{{Variable: "a"}, {Value: "b"}}},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "environment[0].variable", Message: "required"},
+		{Field: "steps[1].source", Message: "required"},
+		{Field: "steps[1].output", Message: "must be \"stdout\", \"stderr\" or an absolute file path"},
+		{Field: "steps[1].endDelimiter", Message: "required when beginDelimiter is set"},
+		{Field: "steps[2].workingDir", Message: "must be an absolute path"},
+		{Field: "steps[2].environment[1].variable", Message: "required"},
+	}, j.Validate())
+}
+
+func TestJobValidateNoSteps(t *testing.T) {
+	j := Job{}
+
+	assert.Equal(t, NewValidationError("steps", "required"), j.Validate())
+}
+
+func TestJobValidateSuccess(t *testing.T) {
+	j := Job{Steps: []JobStep{{Source: "foo", Output: "stderr"}}}
+
+	assert.NoError(t, j.Validate())
+}
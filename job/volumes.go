@@ -0,0 +1,91 @@
+package job
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Volume describes a named Docker volume or host path which should be mounted
+// into a step's container at the Target path.
+type Volume struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+// VolumePolicy holds the server-wide list of volumes which job steps are
+// allowed to mount. Each entry is either a volume name or an absolute host
+// path; a host path also allows any of the paths beneath it.
+type VolumePolicy struct {
+	Allowed []string
+}
+
+func (vol Volume) bind() string {
+	b := fmt.Sprintf("%s:%s", vol.Source, vol.Target)
+	if vol.ReadOnly {
+		b += ":ro"
+	}
+
+	return b
+}
+
+func (vol Volume) isHostPath() bool {
+	return strings.HasPrefix(vol.Source, "/")
+}
+
+func (vol Volume) validate(v *validator, field string) {
+	if len(vol.Source) == 0 {
+		v.add(field+".source", msgRequired)
+	} else if !vol.isHostPath() && !volumeNamePattern.MatchString(vol.Source) {
+		v.add(field+".source", "must be a volume name or an absolute path")
+	}
+
+	if len(vol.Target) == 0 {
+		v.add(field+".target", msgRequired)
+	} else if !path.IsAbs(vol.Target) {
+		v.add(field+".target", "must be an absolute path")
+	}
+}
+
+// Returns true if the volume's source is on the allow-list.
+func (p VolumePolicy) allows(vol Volume) bool {
+	for _, allowed := range p.Allowed {
+		if !vol.isHostPath() {
+			if vol.Source == allowed {
+				return true
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(allowed, "/") {
+			continue
+		}
+
+		source, allowed := path.Clean(vol.Source), path.Clean(allowed)
+		if source == allowed || allowed == "/" || strings.HasPrefix(source, allowed+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks that every volume mounted by the job's steps is on the allow-list.
+func (p VolumePolicy) validate(v *validator, j *Job) {
+	for i, step := range j.Steps {
+		for n, vol := range step.Volumes {
+			if len(vol.Source) > 0 && !p.allows(vol) {
+				v.add(fmt.Sprintf("steps[%d].volumes[%d].source", i, n), "%s is not an allowed volume", vol.Source)
+			}
+		}
+	}
+}
+
+// Returns the name of the Docker volume used as the job's workspace.
+func workspaceVolume(j *Job) string {
+	return "dray-workspace-" + j.ID
+}
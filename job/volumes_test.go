@@ -0,0 +1,44 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeBind(t *testing.T) {
+	assert.Equal(t, "data:/data", Volume{Source: "data", Target: "/data"}.bind())
+	assert.Equal(t, "/srv:/srv:ro", Volume{Source: "/srv", Target: "/srv", ReadOnly: true}.bind())
+}
+
+func TestVolumeValidate(t *testing.T) {
+	j := Job{
+		Workspace: "workspace",
+		Steps: []JobStep{
+			{
+				Source:  "foo",
+				Volumes: []Volume{{}, {Source: "../etc", Target: "etc"}, {Source: "data", Target: "/data"}},
+			},
+		},
+	}
+
+	assert.Equal(t, ValidationError{
+		{Field: "workspace", Message: "must be an absolute path"},
+		{Field: "steps[0].volumes[0].source", Message: "required"},
+		{Field: "steps[0].volumes[0].target", Message: "required"},
+		{Field: "steps[0].volumes[1].source", Message: "must be a volume name or an absolute path"},
+		{Field: "steps[0].volumes[1].target", Message: "must be an absolute path"},
+	}, j.Validate())
+}
+
+func TestVolumePolicyAllows(t *testing.T) {
+	p := VolumePolicy{Allowed: []string{"data", "/srv/shared/"}}
+
+	assert.True(t, p.allows(Volume{Source: "data"}))
+	assert.True(t, p.allows(Volume{Source: "/srv/shared"}))
+	assert.True(t, p.allows(Volume{Source: "/srv/shared/foo"}))
+	assert.False(t, p.allows(Volume{Source: "/srv/shared/../secret"}))
+	assert.False(t, p.allows(Volume{Source: "/srv/sharedfoo"}))
+	assert.False(t, p.allows(Volume{Source: "other"}))
+	assert.False(t, VolumePolicy{}.allows(Volume{Source: "data"}))
+}
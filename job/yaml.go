@@ -0,0 +1,56 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// UnmarshalYAML allows a Job to be decoded from a YAML document using the
+// same field names as the JSON representation (e.g. "beginDelimiter"). The
+// document is converted to JSON and decoded with the standard JSON rules so
+// that the two formats cannot drift apart.
+func (j *Job) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var doc interface{}
+	if err := unmarshal(&doc); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(jsonCompatible(doc))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, j)
+}
+
+// MarshalYAML renders a Job as YAML using the same field names as the JSON
+// representation.
+func (j Job) MarshalYAML() (interface{}, error) {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	err = json.Unmarshal(b, &doc)
+	return doc, err
+}
+
+// Converts the maps produced by the YAML decoder, which may have keys of any
+// type, into maps with string keys which can be encoded as JSON.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonCompatible(value)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,70 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const jobYAML = `name: Word Job
+environment:
+- variable: FOO
+  value: bar
+steps:
+- source: centurylink/randword
+  output: /out.txt
+  beginDelimiter: "---"
+  endDelimiter: "==="
+- source: centurylink/upper
+  refresh: true
+`
+
+func TestJobUnmarshalYAML(t *testing.T) {
+	j := Job{}
+
+	err := yaml.Unmarshal([]byte(jobYAML), &j)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Job{
+		Name:        "Word Job",
+		Environment: Environment{{Variable: "FOO", Value: "bar"}},
+		Steps: []JobStep{
+			{Source: "centurylink/randword", Output: "/out.txt", BeginDelimiter: "---", EndDelimiter: "==="},
+			{Source: "centurylink/upper", Refresh: true},
+		},
+	}, j)
+}
+
+func TestJobUnmarshalYAMLWrongType(t *testing.T) {
+	j := Job{}
+
+	err := yaml.Unmarshal([]byte("steps: foo"), &j)
+
+	assert.Error(t, err)
+}
+
+func TestJobMarshalYAML(t *testing.T) {
+	j := Job{
+		ID:             "123",
+		Steps:          []JobStep{{Source: "foo", BeginDelimiter: "---", EndDelimiter: "==="}},
+		StepsCompleted: 1,
+		Status:         "running",
+	}
+
+	b, err := yaml.Marshal(j)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `id: "123"
+status: running
+steps:
+- beginDelimiter: '---'
+  endDelimiter: ===
+  source: foo
+stepsCompleted: 1
+`, string(b))
+
+	decoded := Job{}
+	yaml.Unmarshal(b, &decoded)
+	assert.Equal(t, j, decoded)
+}
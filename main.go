@@ -1,78 +1,106 @@
 package main // import "github.com/CenturyLinkLabs/dray"
 
 import (
+	"context"
 	"flag"
-	"net/url"
+	"fmt"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/CenturyLinkLabs/dray/api"
+	"github.com/CenturyLinkLabs/dray/config"
 	"github.com/CenturyLinkLabs/dray/job"
 	log "github.com/Sirupsen/logrus"
 )
 
-const (
-	defaultDockerEndpoint = "unix:///var/run/docker.sock"
-	defaultLogLevel       = log.InfoLevel
-)
-
 func init() {
 	log.SetOutput(os.Stdout)
-	log.SetLevel(defaultLogLevel)
+	log.SetLevel(log.InfoLevel)
 }
 
 func main() {
-	log.SetLevel(logLevel())
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		log.Errorf("Invalid configuration: %s", err)
+		os.Exit(2)
+	}
 
-	port := flag.Int("p", 3000, "port on which the server will run")
-	flag.Parse()
+	if cfg.PrintConfig {
+		printConfig(cfg)
+		return
+	}
 
-	r := job.NewJobRepository(redisHost())
-	e := job.NewExecutor(dockerEndpoint())
-	jm := job.NewJobManager(r, e)
+	log.SetLevel(cfg.Level())
 
-	s := api.NewServer(jm)
-	s.Start(*port)
-}
+	c := cfg.Job()
+	if c.Registry, err = cfg.RegistryAuth(); err != nil {
+		log.Error(err)
+		panic(err)
+	}
 
-func redisHost() string {
-	redisPort := os.Getenv("REDIS_PORT")
+	a, err := cfg.Authenticator()
+	if err != nil {
+		log.Error(err)
+		panic(err)
+	}
 
-	if len(redisPort) == 0 {
-		log.Error("Missing required REDIS_PORT environment variable")
+	if !a.Enabled() {
+		log.Warn("API authentication is disabled, set API_TOKENS_FILE or JWT_SECRET to enable it")
 	}
 
-	u, err := url.Parse(redisPort)
+	rc, err := cfg.Redis()
 	if err != nil {
-		log.Errorf("Invalid Redis URL: %s", err)
+		log.Error(err)
 		panic(err)
 	}
 
-	return u.Host
-}
+	r := job.NewJobRepository(rc)
+	e := job.NewExecutor(cfg.Docker.Host, c)
+	jm := job.NewJobManager(r, e, c)
+
+	sched := job.NewScheduler(r, jm)
+	sched.Start()
 
-func dockerEndpoint() string {
-	endpoint := os.Getenv("DOCKER_HOST")
+	s := api.NewTLSServer(jm, a, cfg.ServerTLS())
+	go shutdownOnSignal(s, sched, jm, cfg.GracePeriod())
+	s.Start(cfg.Listen)
+}
 
-	if len(endpoint) == 0 {
-		endpoint = defaultDockerEndpoint
+func printConfig(cfg *config.Config) {
+	b, err := cfg.YAML()
+	if err != nil {
+		log.Error(err)
+		panic(err)
 	}
 
-	return endpoint
+	fmt.Print(string(b))
 }
 
-func logLevel() log.Level {
-	levelString := os.Getenv("LOG_LEVEL")
+// Waits for a SIGTERM (or SIGINT) and then shuts down gracefully: no new jobs
+// are accepted while the running jobs are given the grace period to finish,
+// after which any remaining jobs are interrupted. The API server continues
+// to respond to other requests until all of the jobs have finished.
+func shutdownOnSignal(s api.Server, sched job.Scheduler, jm job.JobManager, grace time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
 
-	if len(levelString) == 0 {
-		return defaultLogLevel
-	}
+	sig := <-signals
+	log.Infof("Received %s, shutting down (grace period %s)", sig, grace)
 
-	level, err := log.ParseLevel(strings.ToLower(levelString))
-	if err != nil {
-		log.Errorf("Invalid log level: %s", levelString)
-		return defaultLogLevel
+	sched.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := jm.Shutdown(ctx); err != nil {
+		log.Warnf("Running jobs were interrupted: %s", err)
 	}
 
-	return level
+	if err := s.Shutdown(context.Background()); err != nil {
+		log.Errorf("Error shutting down server: %s", err)
+	}
 }
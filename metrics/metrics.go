@@ -0,0 +1,360 @@
+/*
+Package metrics implements the counters, gauges and histograms which Dray
+exposes for monitoring. Metrics are registered with a Registry and rendered in
+the Prometheus text exposition format by the Registry's Handler.
+
+	var jobsCreated = metrics.NewCounter("dray_jobs_created_total", "Number of jobs created.")
+
+	jobsCreated.Inc()
+
+Metrics may be partitioned by a fixed set of labels, in which case a value
+must be given for every label whenever the metric is updated.
+*/
+package metrics // import "github.com/CenturyLinkLabs/dray/metrics"
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType = "text/plain; version=0.0.4"
+
+// DefaultBuckets are the upper bounds (in seconds) of the buckets used by
+// histograms which do not specify their own.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// DefaultRegistry is the Registry used by the package-level constructors.
+var DefaultRegistry = NewRegistry()
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metric is implemented by each type of metric so that it can be rendered by
+// the Registry.
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every registered metric to w in the order in which they
+// were registered.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler returns an http.Handler which responds with the current value of
+// every registered metric.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.Write(w)
+	})
+}
+
+// Handler returns the http.Handler for the DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// desc holds the name, help text and label names shared by every type of
+// metric.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) writeHeader(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.Replace(d.help, "\n", " ", -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+// Returns the key under which the values for a set of label values are held.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// Renders the label set for the key, along with any extra label (such as a
+// histogram's "le").
+func (d desc) labelString(key string, extra ...string) string {
+	pairs := []string{}
+
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, labelPair(d.labels[i], v))
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, labelPair(extra[i], extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric whose value only ever increases.
+type Counter struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates a Counter and registers it with the DefaultRegistry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewCounter creates a Counter and registers it with the Registry.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, labels}, values: map[string]float64{}}
+	r.register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by v, which must not
+// be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s cannot be decreased", c.name))
+	}
+
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+	writeValues(w, c.desc, &c.mu, c.values)
+}
+
+// Gauge is a metric whose value can go up and down.
+type Gauge struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge creates a Gauge and registers it with the DefaultRegistry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+// NewGauge creates a Gauge and registers it with the Registry.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name, help, labels}, values: map[string]float64{}}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge for the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[key] = v
+}
+
+// Add adds v (which may be negative) to the gauge for the given label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[key] += v
+}
+
+// Inc increments the gauge for the given label values by one.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec decrements the gauge for the given label values by one.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.writeHeader(w, "gauge")
+	writeValues(w, g.desc, &g.mu, g.values)
+}
+
+// Histogram is a metric which counts observations (such as durations) in
+// buckets of increasing size.
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a Histogram and registers it with the
+// DefaultRegistry. If buckets is nil the DefaultBuckets are used.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram creates a Histogram and registers it with the Registry. If
+// buckets is nil the DefaultBuckets are used.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	h := &Histogram{
+		desc:    desc{name, help, labels},
+		buckets: append([]float64{}, buckets...),
+		values:  map[string]*histogramValue{},
+	}
+	sort.Float64s(h.buckets)
+
+	r.register(h)
+	return h
+}
+
+// Observe adds a single observation to the histogram for the given label
+// values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// ObserveSince adds the number of seconds which have elapsed since start to
+// the histogram for the given label values.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", formatFloat(b)), hv.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(key), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(key), hv.count)
+	}
+}
+
+func writeValues(w io.Writer, d desc, mu *sync.Mutex, values map[string]float64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	// A metric without labels always has a value, even before it is updated
+	if len(d.labels) == 0 && len(values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", d.name)
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", d.name, d.labelString(key), formatFloat(values[key]))
+	}
+}
+
+func sortedKeys(values map[string]*histogramValue) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func labelPair(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
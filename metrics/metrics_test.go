@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func render(r *Registry) string {
+	buf := &bytes.Buffer{}
+	r.Write(buf)
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("jobs_total", "Number of jobs.", "status")
+
+	c.Inc("error")
+	c.Inc("complete")
+	c.Add(2, "complete")
+
+	assert.Equal(t, `# HELP jobs_total Number of jobs.
+# TYPE jobs_total counter
+jobs_total{status="complete"} 3
+jobs_total{status="error"} 1
+`, render(r))
+}
+
+func TestCounterNegative(t *testing.T) {
+	c := NewRegistry().NewCounter("jobs_total", "Number of jobs.")
+
+	assert.Panics(t, func() { c.Add(-1) })
+}
+
+func TestCounterWrongLabels(t *testing.T) {
+	c := NewRegistry().NewCounter("jobs_total", "Number of jobs.", "status")
+
+	assert.Panics(t, func() { c.Inc() })
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("jobs_running", "Number of running jobs.")
+	r.NewGauge("jobs_queued", "Number of queued jobs.")
+
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	assert.Equal(t, `# HELP jobs_running Number of running jobs.
+# TYPE jobs_running gauge
+jobs_running 1
+# HELP jobs_queued Number of queued jobs.
+# TYPE jobs_queued gauge
+jobs_queued 0
+`, render(r))
+}
+
+func TestGaugeSet(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("temperature", "Temperature.", "room")
+
+	g.Set(21.5, "hall")
+
+	assert.Contains(t, render(r), "temperature{room=\"hall\"} 21.5\n")
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("duration_seconds", "Duration.", []float64{1, 0.5}, "cmd")
+
+	h.Observe(0.2, "get")
+	h.Observe(0.7, "get")
+	h.Observe(3, "get")
+
+	assert.Equal(t, `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{cmd="get",le="0.5"} 1
+duration_seconds_bucket{cmd="get",le="1"} 2
+duration_seconds_bucket{cmd="get",le="+Inf"} 3
+duration_seconds_sum{cmd="get"} 3.9
+duration_seconds_count{cmd="get"} 3
+`, render(r))
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "route")
+
+	c.Inc("/a\"b\\c\n")
+
+	assert.Contains(t, render(r), `requests_total{route="/a\"b\\c\n"} 1`)
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("jobs_total", "Number of jobs.").Inc()
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; version=0.0.4", res.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), "jobs_total 1\n")
+}